@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	anonymizeFlag       bool
+	anonymizeMACsFlag   bool
+	truncatePayloadFlag bool
+)
+
+// addAnonymizeFlags adds the flags asking the server for anonymized captures
+// to a command that downloads them.
+func addAnonymizeFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&anonymizeFlag, "anonymize", false, "Rewrite IP addresses with the server's prefix-preserving mapping (needs anonymization_key on the server)")
+	cmd.Flags().BoolVar(&anonymizeMACsFlag, "anonymize-macs", false, "With --anonymize, also replace MAC addresses")
+	cmd.Flags().BoolVar(&truncatePayloadFlag, "truncate-payload", false, "With --anonymize, cut packets after the transport header")
+}
+
+func setAnonymizeParams(params url.Values) {
+	if !anonymizeFlag {
+		return
+	}
+	params.Set("anonymize", "prefix-preserving")
+	if anonymizeMACsFlag {
+		params.Set("anonymize_macs", "true")
+	}
+	if truncatePayloadFlag {
+		params.Set("truncate_payload", "true")
+	}
+}
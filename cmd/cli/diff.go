@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffBaseline         bool
+	diffBaselineScenario string
+	diffThreshold        float64
+	diffLimit            int
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> [b]",
+	Short: "Compare two captures, or a capture with its baseline",
+	Long:  `Lists the hosts, ports, protocols and conversations that are new in b, missing from it or changed in volume compared to a. With --baseline the capture is compared with the per-capture average of all captures of the same hostname in the baseline scenario.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int64, len(args))
+		for i, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid file ID: %w", err)
+			}
+			ids[i] = id
+		}
+
+		params := url.Values{}
+		switch {
+		case diffBaseline && len(ids) == 1:
+			params.Set("b", strconv.FormatInt(ids[0], 10))
+			params.Set("baseline", diffBaselineScenario)
+		case !diffBaseline && len(ids) == 2:
+			params.Set("a", strconv.FormatInt(ids[0], 10))
+			params.Set("b", strconv.FormatInt(ids[1], 10))
+		default:
+			return fmt.Errorf("give two file IDs, or one with --baseline")
+		}
+		if diffThreshold > 0 {
+			params.Set("threshold", strconv.FormatFloat(diffThreshold, 'f', -1, 64))
+		}
+		if diffLimit > 0 {
+			params.Set("limit", strconv.Itoa(diffLimit))
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		diff, err := c.GetDiff(params)
+		if err != nil {
+			return fmt.Errorf("failed to diff files: %w", err)
+		}
+
+		return outputJSON(diff)
+	},
+}
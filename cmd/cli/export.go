@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -12,7 +13,7 @@ import (
 var exportCmd = &cobra.Command{
 	Use:   "export [output]",
 	Short: "Export the entire store to a gzip archive",
-	Long:  `Exports the database and all capture files to a tar.gz archive. If output path is not specified, defaults to pcapstore-export-YYYYMMDD-HHMMSS.tar.gz in the current directory.`,
+	Long:  `Exports the database and all capture files to a tar.gz archive. If output path is not specified, defaults to pcapstore-export-YYYYMMDD-HHMMSS.tar.gz in the current directory. With --anonymize the archive holds anonymized pcap copies of the captures and no database.`,
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outputPath := ""
@@ -30,7 +31,9 @@ var exportCmd = &cobra.Command{
 		}
 
 		fmt.Printf("Exporting store to %s...\n", outputPath)
-		if err := c.ExportStore(outputPath); err != nil {
+		params := url.Values{}
+		setAnonymizeParams(params)
+		if err := c.ExportStore(params, outputPath); err != nil {
 			return fmt.Errorf("failed to export store: %w", err)
 		}
 
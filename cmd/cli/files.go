@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -81,7 +82,9 @@ var filesDownloadCmd = &cobra.Command{
 			return err
 		}
 
-		if err := c.DownloadFile(id, outputPath); err != nil {
+		params := url.Values{}
+		setAnonymizeParams(params)
+		if err := c.DownloadFile(id, params, outputPath); err != nil {
 			return fmt.Errorf("failed to download file: %w", err)
 		}
 
@@ -134,7 +137,28 @@ var filesStatsCmd = &cobra.Command{
 			return fmt.Errorf("failed to get file stats: %w", err)
 		}
 
-		return outputJSON(stats)
+		if err := outputJSON(stats); err != nil {
+			return err
+		}
+		if RawFlag {
+			return nil
+		}
+
+		var durationSeconds int64
+		if statsMap, ok := stats.(map[string]any); ok {
+			if duration, ok := statsMap["duration_seconds"].(float64); ok {
+				durationSeconds = int64(duration)
+			}
+		}
+
+		// The stats are already out, the sparkline is only extra.
+		timeline, err := c.GetFileTimeline(id, sparklineBucket(durationSeconds))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to get file timeline, skipping sparkline: %v\n", err)
+			return nil
+		}
+
+		return outputSparkline(timeline)
 	},
 }
 
@@ -175,3 +199,336 @@ var filesByScenarioCmd = &cobra.Command{
 		return outputJSON(files)
 	},
 }
+
+var filesUploadCmd = &cobra.Command{
+	Use:   "upload <path...>",
+	Short: "Upload capture files",
+	Long:  `Uploads one or more capture files to the server. Filenames must follow the same naming format as files placed in the watch dir.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		results := make([]any, 0, len(args))
+		failed := 0
+		for _, path := range args {
+			result, err := c.UploadFile(path)
+			if err != nil {
+				failed++
+				results = append(results, map[string]string{
+					"filename": filepath.Base(path),
+					"status":   "error",
+					"error":    err.Error(),
+				})
+				continue
+			}
+			results = append(results, result)
+		}
+
+		if err := outputJSON(results); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d uploads failed", failed, len(args))
+		}
+		return nil
+	},
+}
+
+var (
+	flowsSort     string
+	flowsOrder    string
+	flowsProtocol string
+	flowsIP       string
+	flowsPort     int
+	flowsLimit    int
+)
+
+var filesFlowsCmd = &cobra.Command{
+	Use:   "flows <id>",
+	Short: "List the conversations in a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		params := url.Values{}
+		if flowsSort != "" {
+			params.Set("sort", flowsSort)
+		}
+		if flowsOrder != "" {
+			params.Set("order", flowsOrder)
+		}
+		if flowsProtocol != "" {
+			params.Set("protocol", flowsProtocol)
+		}
+		if flowsIP != "" {
+			params.Set("ip", flowsIP)
+		}
+		if cmd.Flags().Changed("port") {
+			params.Set("port", strconv.Itoa(flowsPort))
+		}
+		if flowsLimit > 0 {
+			params.Set("limit", strconv.Itoa(flowsLimit))
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		flows, err := c.GetFileFlows(id, params)
+		if err != nil {
+			return fmt.Errorf("failed to get file flows: %w", err)
+		}
+
+		return outputJSON(flows)
+	},
+}
+
+var (
+	httpMethod string
+	httpHost   string
+	httpURI    string
+	httpStatus int
+)
+
+var filesHTTPCmd = &cobra.Command{
+	Use:   "http <id>",
+	Short: "List the HTTP requests in a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		params := url.Values{}
+		if httpMethod != "" {
+			params.Set("method", httpMethod)
+		}
+		if httpHost != "" {
+			params.Set("host", httpHost)
+		}
+		if httpURI != "" {
+			params.Set("uri", httpURI)
+		}
+		if httpStatus != 0 {
+			params.Set("status", strconv.Itoa(httpStatus))
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		transactions, err := c.GetFileHTTP(id, params)
+		if err != nil {
+			return fmt.Errorf("failed to get HTTP transactions: %w", err)
+		}
+
+		return outputJSON(transactions)
+	},
+}
+
+var (
+	extractFilter string
+	extractFrom   string
+	extractTo     string
+	extractOutput string
+)
+
+var filesExtractCmd = &cobra.Command{
+	Use:   "extract <id>",
+	Short: "Download only the matching packets of a file",
+	Long:  `Saves the packets of a stored file that match a BPF filter and/or fall into a time window as a new pcap. Filtering happens on the server, so only the matching packets are transferred.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		params := url.Values{}
+		if extractFilter != "" {
+			params.Set("bpf", extractFilter)
+		}
+		if extractFrom != "" {
+			params.Set("from", extractFrom)
+		}
+		if extractTo != "" {
+			params.Set("to", extractTo)
+		}
+		setAnonymizeParams(params)
+
+		outputPath := extractOutput
+		if outputPath == "" {
+			cwd, _ := os.Getwd()
+			outputPath = filepath.Join(cwd, fmt.Sprintf("file-%d-extract.pcap", id))
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		if err := c.ExtractFile(id, params, outputPath); err != nil {
+			return fmt.Errorf("failed to extract file: %w", err)
+		}
+
+		fmt.Printf("Extracted packets to: %s\n", outputPath)
+		return nil
+	},
+}
+
+var (
+	mergeHostname   string
+	mergeScenario   string
+	mergeFormat     string
+	mergeOutput     string
+	mergeIngest     bool
+	mergeAsHostname string
+	mergeAsScenario string
+)
+
+var filesMergeCmd = &cobra.Command{
+	Use:   "merge [id...]",
+	Short: "Merge files into one time-ordered capture",
+	Long:  `Merges the given files, and/or all files matching --hostname/--scenario, into one capture ordered by timestamp. The result is saved locally, or stored on the server as a new capture with --ingest.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := map[string]any{}
+
+		ids := make([]int64, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid file ID %q: %w", arg, err)
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			req["ids"] = ids
+		}
+
+		search := map[string]string{}
+		if mergeHostname != "" {
+			search["hostname"] = mergeHostname
+		}
+		if mergeScenario != "" {
+			search["scenario"] = mergeScenario
+		}
+		if len(search) > 0 {
+			req["search"] = search
+		}
+		if len(ids) == 0 && len(search) == 0 {
+			return fmt.Errorf("give file IDs or --hostname/--scenario to pick the files to merge")
+		}
+		if mergeFormat != "" {
+			req["format"] = mergeFormat
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		if mergeIngest {
+			if mergeAsHostname != "" {
+				req["hostname"] = mergeAsHostname
+			}
+			if mergeAsScenario != "" {
+				req["scenario"] = mergeAsScenario
+			}
+			result, err := c.MergeAndIngest(req)
+			if err != nil {
+				return fmt.Errorf("failed to merge files: %w", err)
+			}
+			return outputJSON(result)
+		}
+
+		outputPath := mergeOutput
+		if outputPath == "" {
+			format := mergeFormat
+			if format == "" {
+				format = "pcapng"
+			}
+			cwd, _ := os.Getwd()
+			outputPath = filepath.Join(cwd, "merged."+format)
+		}
+
+		if err := c.MergeFiles(req, outputPath); err != nil {
+			return fmt.Errorf("failed to merge files: %w", err)
+		}
+
+		fmt.Printf("Merged files to: %s\n", outputPath)
+		return nil
+	},
+}
+
+var timelineBucket string
+
+var filesTimelineCmd = &cobra.Command{
+	Use:   "timeline <id>",
+	Short: "Get packet and byte counts over time",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		timeline, err := c.GetFileTimeline(id, timelineBucket)
+		if err != nil {
+			return fmt.Errorf("failed to get file timeline: %w", err)
+		}
+
+		return outputJSON(timeline)
+	},
+}
+
+var reanalyzeVersionLt int64
+
+var filesReanalyzeCmd = &cobra.Command{
+	Use:   "reanalyze [id...]",
+	Short: "Re-run analysis on stored files",
+	Long:  `Re-runs the analyzer on the given files and replaces their stats. Without IDs, queues every file whose stats were produced by an analyzer older than --version-lt (defaults to the server's current analyzer version).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			result, err := c.Reanalyze(reanalyzeVersionLt)
+			if err != nil {
+				return fmt.Errorf("failed to trigger reanalysis: %w", err)
+			}
+			return outputJSON(result)
+		}
+
+		results := make([]any, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid file ID: %w", err)
+			}
+
+			result, err := c.ReanalyzeFile(id)
+			if err != nil {
+				return fmt.Errorf("failed to reanalyze file %d: %w", id, err)
+			}
+			results = append(results, result)
+		}
+
+		return outputJSON(results)
+	},
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	findingsRule      string
+	findingsSeverity  string
+	findingsCaptureID int64
+	findingsHostname  string
+	findingsScenario  string
+)
+
+var findingsCmd = &cobra.Command{
+	Use:   "findings",
+	Short: "Detector findings",
+	Long:  `Commands for the port scans, floods and other anomalies flagged during analysis`,
+}
+
+var findingsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List findings",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		params := url.Values{}
+		if findingsRule != "" {
+			params.Set("rule", findingsRule)
+		}
+		if findingsSeverity != "" {
+			params.Set("severity", findingsSeverity)
+		}
+		if findingsCaptureID != 0 {
+			params.Set("capture_id", strconv.FormatInt(findingsCaptureID, 10))
+		}
+		if findingsHostname != "" {
+			params.Set("hostname", findingsHostname)
+		}
+		if findingsScenario != "" {
+			params.Set("scenario", findingsScenario)
+		}
+
+		findings, err := c.GetFindings(params)
+		if err != nil {
+			return fmt.Errorf("failed to get findings: %w", err)
+		}
+
+		return outputJSON(findings)
+	},
+}
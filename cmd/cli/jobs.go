@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsStatus      string
+	jobsRetryFailed bool
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Ingest jobs",
+	Long:  `Commands for the queue of watched files waiting to be ingested`,
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List ingest jobs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		params := url.Values{}
+		if jobsStatus != "" {
+			params.Set("status", jobsStatus)
+		}
+
+		jobs, err := c.GetJobs(params)
+		if err != nil {
+			return fmt.Errorf("failed to get jobs: %w", err)
+		}
+
+		return outputJSON(jobs)
+	},
+}
+
+var jobsRetryCmd = &cobra.Command{
+	Use:   "retry [id]",
+	Short: "Retry a failed ingest job",
+	Long:  `Puts a failed ingest job back in the queue with a fresh set of attempts. With --failed, retries every failed job.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if jobsRetryFailed == (len(args) == 1) {
+			return fmt.Errorf("give either a job ID or --failed")
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		if jobsRetryFailed {
+			result, err := c.RetryFailedJobs()
+			if err != nil {
+				return fmt.Errorf("failed to retry jobs: %w", err)
+			}
+			return outputJSON(result)
+		}
+
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid job ID: %w", err)
+		}
+
+		result, err := c.RetryJob(id)
+		if err != nil {
+			return fmt.Errorf("failed to retry job: %w", err)
+		}
+
+		return outputJSON(result)
+	},
+}
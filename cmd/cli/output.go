@@ -30,3 +30,68 @@ func outputJSON(data any) error {
 	}
 	return nil
 }
+
+// sparklineWidth is the number of buckets requested when rendering a
+// timeline in the terminal.
+const sparklineWidth = 60
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+func sparkline(values []int64) (string, int64) {
+	var peak int64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+
+	line := make([]rune, 0, len(values))
+	for _, v := range values {
+		idx := 0
+		if peak > 0 {
+			idx = int(v * int64(len(sparkTicks)-1) / peak)
+		}
+		line = append(line, sparkTicks[idx])
+	}
+	return string(line), peak
+}
+
+// sparklineBucket picks a bucket size that fits a capture of the given
+// duration into sparklineWidth buckets.
+func sparklineBucket(durationSeconds int64) string {
+	seconds := (durationSeconds + sparklineWidth) / sparklineWidth
+	return fmt.Sprintf("%ds", max(seconds, 1))
+}
+
+func outputSparkline(timeline any) error {
+	jsonBytes, err := json.Marshal(timeline)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeline: %w", err)
+	}
+
+	var parsed struct {
+		BucketSeconds int64 `json:"bucket_seconds"`
+		Buckets       []struct {
+			Packets int64 `json:"packets"`
+			Bytes   int64 `json:"bytes"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal(jsonBytes, &parsed); err != nil {
+		return fmt.Errorf("failed to parse timeline: %w", err)
+	}
+	if len(parsed.Buckets) == 0 {
+		return nil
+	}
+
+	packets := make([]int64, 0, len(parsed.Buckets))
+	bytes := make([]int64, 0, len(parsed.Buckets))
+	for _, bucket := range parsed.Buckets {
+		packets = append(packets, bucket.Packets)
+		bytes = append(bytes, bucket.Bytes)
+	}
+
+	packetLine, packetPeak := sparkline(packets)
+	byteLine, bytePeak := sparkline(bytes)
+	fmt.Printf("\nTimeline (%ds buckets)\n", parsed.BucketSeconds)
+	fmt.Printf("packets %s  peak %d\n", packetLine, packetPeak)
+	fmt.Printf("bytes   %s  peak %d\n", byteLine, bytePeak)
+	return nil
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Reconcile the database with the capture directories",
+	Long:  `Queues captures waiting in the watch dir, registers stored files the database doesn't know and marks files that have gone missing. Prints every action taken. The server also does this on startup.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		result, err := c.Reconcile()
+		if err != nil {
+			return fmt.Errorf("failed to reconcile: %w", err)
+		}
+
+		return outputJSON(result)
+	},
+}
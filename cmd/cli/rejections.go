@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rejectionsStatus string
+	rejectionsReason string
+)
+
+var rejectionsCmd = &cobra.Command{
+	Use:   "rejections",
+	Short: "Rejected files",
+	Long:  `Commands for the files the server moved to its quarantine dir: invalid names, invalid sidecars and duplicates`,
+}
+
+var rejectionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List rejected files",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		params := url.Values{}
+		if rejectionsStatus != "" {
+			params.Set("status", rejectionsStatus)
+		}
+		if rejectionsReason != "" {
+			params.Set("reason", rejectionsReason)
+		}
+
+		rejections, err := c.GetRejections(params)
+		if err != nil {
+			return fmt.Errorf("failed to get rejections: %w", err)
+		}
+
+		return outputJSON(rejections)
+	},
+}
+
+var rejectionsRetryCmd = &cobra.Command{
+	Use:   "retry <id>",
+	Short: "Resubmit a rejected file as it is",
+	Long:  `Moves a quarantined file back into the watch dir it came from, e.g. after adding a filename pattern or changing the duplicate policy`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return retryRejection(args[0], "")
+	},
+}
+
+var rejectionsRenameCmd = &cobra.Command{
+	Use:   "rename <id> <new-name>",
+	Short: "Resubmit a rejected file under a new name",
+	Long:  `Moves a quarantined file back into the watch dir it came from under a new name. The name is checked first, so a name that would be rejected again is refused.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return retryRejection(args[0], args[1])
+	},
+}
+
+func retryRejection(idArg, name string) error {
+	id, err := strconv.ParseInt(idArg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid rejection ID: %w", err)
+	}
+
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := c.RetryRejection(id, name)
+	if err != nil {
+		return fmt.Errorf("failed to resubmit file: %w", err)
+	}
+
+	return outputJSON(result)
+}
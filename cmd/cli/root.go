@@ -22,11 +22,43 @@ func AddAllCommands(rootCmd *cobra.Command) {
 	// Files group
 	filesCmd.AddCommand(filesListCmd)
 	filesCmd.AddCommand(filesGetCmd)
+	addAnonymizeFlags(filesDownloadCmd)
 	filesCmd.AddCommand(filesDownloadCmd)
+	filesExtractCmd.Flags().StringVar(&extractFilter, "filter", "", "BPF filter expression, e.g. 'tcp port 22'")
+	filesExtractCmd.Flags().StringVar(&extractFrom, "from", "", "Only packets at or after this time (RFC 3339)")
+	filesExtractCmd.Flags().StringVar(&extractTo, "to", "", "Only packets before this time (RFC 3339)")
+	filesExtractCmd.Flags().StringVarP(&extractOutput, "output", "o", "", "Output path (default: file-<id>-extract.pcap in the current directory)")
+	addAnonymizeFlags(filesExtractCmd)
+	filesCmd.AddCommand(filesExtractCmd)
+	filesMergeCmd.Flags().StringVar(&mergeHostname, "hostname", "", "Also merge all files from this hostname")
+	filesMergeCmd.Flags().StringVar(&mergeScenario, "scenario", "", "Also merge all files from this scenario")
+	filesMergeCmd.Flags().StringVar(&mergeFormat, "format", "", "Output format: pcapng (one interface per file) or pcap (default: pcapng)")
+	filesMergeCmd.Flags().StringVarP(&mergeOutput, "output", "o", "", "Output path (default: merged.<format> in the current directory)")
+	filesMergeCmd.Flags().BoolVar(&mergeIngest, "ingest", false, "Store the result on the server as a new capture instead of downloading it")
+	filesMergeCmd.Flags().StringVar(&mergeAsHostname, "as-hostname", "", "Hostname of the ingested capture (default: the shared hostname, or \"merged\")")
+	filesMergeCmd.Flags().StringVar(&mergeAsScenario, "as-scenario", "", "Scenario of the ingested capture (default: <scenario>-merged, or \"merged\")")
+	filesCmd.AddCommand(filesMergeCmd)
 	filesCmd.AddCommand(filesDeleteCmd)
 	filesCmd.AddCommand(filesStatsCmd)
 	filesCmd.AddCommand(filesByHostnameCmd)
 	filesCmd.AddCommand(filesByScenarioCmd)
+	filesCmd.AddCommand(filesUploadCmd)
+	filesFlowsCmd.Flags().StringVar(&flowsSort, "sort", "", "Sort by bytes, packets, duration, rtt, first_seen or last_seen (default: first_seen)")
+	filesFlowsCmd.Flags().StringVar(&flowsOrder, "order", "", "Sort order: asc or desc")
+	filesFlowsCmd.Flags().StringVar(&flowsProtocol, "protocol", "", "Only show flows of this protocol (TCP, UDP, ICMP, ...)")
+	filesFlowsCmd.Flags().StringVar(&flowsIP, "ip", "", "Only show flows with this IP on either side")
+	filesFlowsCmd.Flags().IntVar(&flowsPort, "port", 0, "Only show flows with this port on either side")
+	filesFlowsCmd.Flags().IntVar(&flowsLimit, "limit", 0, "Maximum number of flows to return")
+	filesCmd.AddCommand(filesFlowsCmd)
+	filesHTTPCmd.Flags().StringVar(&httpMethod, "method", "", "Only show requests with this method")
+	filesHTTPCmd.Flags().StringVar(&httpHost, "host", "", "Only show requests to this Host")
+	filesHTTPCmd.Flags().StringVar(&httpURI, "uri", "", "Only show requests whose URI contains this string")
+	filesHTTPCmd.Flags().IntVar(&httpStatus, "status", 0, "Only show requests answered with this status code")
+	filesCmd.AddCommand(filesHTTPCmd)
+	filesTimelineCmd.Flags().StringVar(&timelineBucket, "bucket", "", "Bucket size in whole seconds, e.g. 1s, 10s, 1m (default: 1s)")
+	filesCmd.AddCommand(filesTimelineCmd)
+	filesReanalyzeCmd.Flags().Int64Var(&reanalyzeVersionLt, "version-lt", 0, "Only reanalyze files with an analyzer version below this (default: current version)")
+	filesCmd.AddCommand(filesReanalyzeCmd)
 	rootCmd.AddCommand(filesCmd)
 
 	// Stats group
@@ -57,9 +89,56 @@ func AddAllCommands(rootCmd *cobra.Command) {
 	cleanupCmd.AddCommand(cleanupExecuteCmd)
 	rootCmd.AddCommand(cleanupCmd)
 
+	// Findings group
+	findingsListCmd.Flags().StringVar(&findingsRule, "rule", "", "Only show findings of this rule (port_scan_vertical, syn_flood, ...)")
+	findingsListCmd.Flags().StringVar(&findingsSeverity, "severity", "", "Minimum severity: low, medium or high")
+	findingsListCmd.Flags().Int64Var(&findingsCaptureID, "capture", 0, "Only show findings of this capture ID")
+	findingsListCmd.Flags().StringVar(&findingsHostname, "hostname", "", "Only show findings of captures from this hostname")
+	findingsListCmd.Flags().StringVar(&findingsScenario, "scenario", "", "Only show findings of captures from this scenario")
+	findingsCmd.AddCommand(findingsListCmd)
+	rootCmd.AddCommand(findingsCmd)
+
+	// Jobs group
+	jobsListCmd.Flags().StringVar(&jobsStatus, "status", "", "Only show jobs in this state: queued, running, done or failed")
+	jobsRetryCmd.Flags().BoolVar(&jobsRetryFailed, "failed", false, "Retry every failed job")
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsRetryCmd)
+	rootCmd.AddCommand(jobsCmd)
+
+	// Rejections group
+	rejectionsListCmd.Flags().StringVar(&rejectionsStatus, "status", "", "Only show rejections in this state: quarantined or resubmitted")
+	rejectionsListCmd.Flags().StringVar(&rejectionsReason, "reason", "", "Only show rejections for this reason: invalid_name, invalid_sidecar, duplicate, corrupt or orphaned_sidecar")
+	rejectionsCmd.AddCommand(rejectionsListCmd)
+	rejectionsCmd.AddCommand(rejectionsRetryCmd)
+	rejectionsCmd.AddCommand(rejectionsRenameCmd)
+	rootCmd.AddCommand(rejectionsCmd)
+
+	// Tag & note groups
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRmCmd)
+	rootCmd.AddCommand(tagCmd)
+	noteCmd.AddCommand(noteAddCmd)
+	noteCmd.AddCommand(noteListCmd)
+	rootCmd.AddCommand(noteCmd)
+
 	// Standalone commands
+	searchCmd.Flags().StringVar(&searchSNI, "sni", "", "Only files with a TLS connection to this server name (*.example.com for subdomains)")
+	searchCmd.Flags().StringVar(&searchHTTPHost, "http-host", "", "Only files with an HTTP request to this Host (*.example.com for subdomains)")
+	searchCmd.Flags().StringVar(&searchHTTPURI, "http-uri", "", "Only files with an HTTP request whose URI contains this string")
+	searchCmd.Flags().StringArrayVar(&searchMeta, "meta", nil, "Only files with this sidecar metadata, key=value or just key (repeatable)")
+	searchCmd.Flags().StringArrayVar(&searchTags, "tag", nil, "Only files with this tag (repeatable)")
+	searchCmd.Flags().BoolVar(&searchCorrupt, "corrupt", false, "Only files that were stored corrupt")
 	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(dnsCmd)
+	diffCmd.Flags().BoolVar(&diffBaseline, "baseline", false, "Compare the capture with the average of the baseline captures of its hostname")
+	diffCmd.Flags().StringVar(&diffBaselineScenario, "baseline-scenario", "baseline", "Scenario holding the baseline captures")
+	diffCmd.Flags().Float64Var(&diffThreshold, "threshold", 0, "Relative change in packets that counts as changed (default: 0.5)")
+	diffCmd.Flags().IntVar(&diffLimit, "limit", 0, "Maximum entries per list (default: 50)")
+	rootCmd.AddCommand(diffCmd)
+	addAnonymizeFlags(exportCmd)
 	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(reconcileCmd)
 	rootCmd.AddCommand(healthCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(versionCmd)
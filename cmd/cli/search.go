@@ -2,10 +2,20 @@ package cli
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	searchSNI      string
+	searchHTTPHost string
+	searchHTTPURI  string
+	searchMeta     []string
+	searchTags     []string
+	searchCorrupt  bool
+)
+
 var searchCmd = &cobra.Command{
 	Use:   "search [query]",
 	Short: "Search files",
@@ -22,7 +32,27 @@ var searchCmd = &cobra.Command{
 			query = args[0]
 		}
 
-		results, err := c.Search(query)
+		filters := url.Values{}
+		if searchSNI != "" {
+			filters.Set("sni", searchSNI)
+		}
+		if searchHTTPHost != "" {
+			filters.Set("http_host", searchHTTPHost)
+		}
+		if searchHTTPURI != "" {
+			filters.Set("http_uri", searchHTTPURI)
+		}
+		for _, meta := range searchMeta {
+			filters.Add("meta", meta)
+		}
+		for _, tag := range searchTags {
+			filters.Add("tag", tag)
+		}
+		if searchCorrupt {
+			filters.Set("corrupt", "true")
+		}
+
+		results, err := c.Search(query, filters)
 		if err != nil {
 			return fmt.Errorf("failed to search: %w", err)
 		}
@@ -30,3 +60,23 @@ var searchCmd = &cobra.Command{
 		return outputJSON(results)
 	},
 }
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns <name>",
+	Short: "Find captures that resolved a domain",
+	Long:  `Searches the DNS queries of all captures for a domain. Use "*.example.com" to match all subdomains.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		results, err := c.SearchDNS(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to search DNS queries: %w", err)
+		}
+
+		return outputJSON(results)
+	},
+}
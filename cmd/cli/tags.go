@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Tag files",
+	Long:  `Commands for marking files with tags like "graded" or "broken capture"`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <id> <tag>",
+	Short: "Add a tag to a file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		tags, err := c.AddTag(id, args[1])
+		if err != nil {
+			return fmt.Errorf("failed to add tag: %w", err)
+		}
+
+		return outputJSON(tags)
+	},
+}
+
+var tagRmCmd = &cobra.Command{
+	Use:   "rm <id> <tag>",
+	Short: "Remove a tag from a file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		tags, err := c.RemoveTag(id, args[1])
+		if err != nil {
+			return fmt.Errorf("failed to remove tag: %w", err)
+		}
+
+		return outputJSON(tags)
+	},
+}
+
+var noteCmd = &cobra.Command{
+	Use:   "note",
+	Short: "Notes on files",
+	Long:  `Commands for timestamped free-text notes on files`,
+}
+
+var noteAddCmd = &cobra.Command{
+	Use:   "add <id> <text...>",
+	Short: "Add a note to a file",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		note, err := c.AddNote(id, strings.Join(args[1:], " "))
+		if err != nil {
+			return fmt.Errorf("failed to add note: %w", err)
+		}
+
+		return outputJSON(note)
+	},
+}
+
+var noteListCmd = &cobra.Command{
+	Use:   "list <id>",
+	Short: "List the notes of a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file ID: %w", err)
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		notes, err := c.GetNotes(id)
+		if err != nil {
+			return fmt.Errorf("failed to get notes: %w", err)
+		}
+
+		return outputJSON(notes)
+	},
+}
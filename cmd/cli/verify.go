@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [id...]",
+	Short: "Verify stored files against their hashes",
+	Long:  `Re-hashes stored files, all of them or only the given IDs, and reports missing files, unreadable files and content that changed since ingest. Ends with an error message if any file fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int64, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid file ID: %w", err)
+			}
+			ids = append(ids, id)
+		}
+
+		c, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		result, err := c.Verify(ids)
+		if err != nil {
+			return fmt.Errorf("failed to verify files: %w", err)
+		}
+
+		if err := outputJSON(result); err != nil {
+			return err
+		}
+		if problems, ok := result["problems"].([]any); ok && len(problems) > 0 {
+			return fmt.Errorf("%d file(s) failed verification", len(problems))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,111 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+
+	"github.com/google/gopacket"
+)
+
+// Analyzer inspects every packet of a capture during the single pass over the
+// file. Once all packets are read Finalize writes its results into the stats.
+// Analyzers with tables of their own also implement Persister.
+type Analyzer interface {
+	HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo)
+	Finalize(stats *CaptureStats)
+}
+
+// Persister is implemented by analyzers that store results in their own
+// tables, like the built-in flow, DNS, TLS, HTTP, timeline and findings
+// analyzers or lab-specific decoders. Persist runs inside the transaction that
+// stores the capture, tx, and again on reanalysis, so it has to replace
+// whatever it wrote for the capture before.
+type Persister interface {
+	Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error
+}
+
+type registeredAnalyzer struct {
+	name     string
+	factory  func() Analyzer
+	requires []string
+}
+
+var analyzerRegistry []registeredAnalyzer
+
+// RegisterAnalyzer makes an analyzer available under name, which is the key
+// used to enable or disable it in the [analyzers] config table. Analyzers run
+// and finalize in registration order, so requires names analyzers registered
+// before it whose results its Finalize reads. It panics if name is already
+// taken or a required analyzer isn't registered yet.
+func RegisterAnalyzer(name string, factory func() Analyzer, requires ...string) {
+	for _, registered := range analyzerRegistry {
+		if registered.name == name {
+			panic(fmt.Sprintf("capture: analyzer %q registered twice", name))
+		}
+	}
+	for _, required := range requires {
+		if !slices.Contains(AnalyzerNames(), required) {
+			panic(fmt.Sprintf("capture: analyzer %q requires unregistered analyzer %q", name, required))
+		}
+	}
+	analyzerRegistry = append(analyzerRegistry, registeredAnalyzer{name: name, factory: factory, requires: requires})
+}
+
+// ValidateAnalyzers checks that no enabled analyzer requires a disabled one.
+func ValidateAnalyzers(enabled map[string]bool) error {
+	isOn := func(name string) bool {
+		on, ok := enabled[name]
+		return !ok || on
+	}
+	for _, registered := range analyzerRegistry {
+		if !isOn(registered.name) {
+			continue
+		}
+		for _, required := range registered.requires {
+			if !isOn(required) {
+				return fmt.Errorf("analyzer %s requires %s, which is disabled", registered.name, required)
+			}
+		}
+	}
+	return nil
+}
+
+// AnalyzerNames lists the registered analyzers in the order they run.
+func AnalyzerNames() []string {
+	names := make([]string, 0, len(analyzerRegistry))
+	for _, registered := range analyzerRegistry {
+		names = append(names, registered.name)
+	}
+	return names
+}
+
+// newAnalyzers creates a fresh instance of every analyzer not disabled in the
+// config. Analyzers missing from the config are enabled.
+func newAnalyzers(enabled map[string]bool) []Analyzer {
+	analyzers := make([]Analyzer, 0, len(analyzerRegistry))
+	for _, registered := range analyzerRegistry {
+		if on, ok := enabled[registered.name]; ok && !on {
+			continue
+		}
+		analyzers = append(analyzers, registered.factory())
+	}
+	return analyzers
+}
+
+func init() {
+	RegisterAnalyzer("ipv4", func() Analyzer { return newIPCounter("IPv4") })
+	RegisterAnalyzer("ipv6", func() Analyzer { return newIPCounter("IPv6") })
+	RegisterAnalyzer("tcp", func() Analyzer { return newPortCounter("TCP") })
+	RegisterAnalyzer("udp", func() Analyzer { return newPortCounter("UDP") })
+	RegisterAnalyzer("icmp", func() Analyzer { return &icmpCounter{} })
+	RegisterAnalyzer("flows", func() Analyzer { return newFlowTable() })
+	RegisterAnalyzer("dns", func() Analyzer { return newDNSTable() })
+	RegisterAnalyzer("tls", func() Analyzer { return newTLSTable() })
+	RegisterAnalyzer("http", func() Analyzer { return newHTTPTable() })
+	RegisterAnalyzer("timeline", func() Analyzer { return newTimelineTable() })
+	RegisterAnalyzer("tcp_health", func() Analyzer { return newTCPHealthTable() }, "flows")
+	RegisterAnalyzer("findings", func() Analyzer { return newFindingDetector() })
+}
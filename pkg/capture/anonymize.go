@@ -0,0 +1,260 @@
+package capture
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// AnonymizePrefixPreserving is the only anonymization mode so far: addresses
+// sharing a prefix keep sharing a prefix of the same length afterwards.
+const AnonymizePrefixPreserving = "prefix-preserving"
+
+// AnonymizeOptions selects what an Anonymizer rewrites besides IP addresses.
+type AnonymizeOptions struct {
+	// MACs replaces unicast Ethernet and ARP hardware addresses with keyed
+	// pseudonyms. The OUI is not kept.
+	MACs bool
+	// TruncatePayload cuts every packet after its transport header, which also
+	// drops addresses inside payloads such as DNS answers or ICMP errors.
+	TruncatePayload bool
+}
+
+// Anonymizer rewrites the addresses of packets with Crypto-PAn, so the same
+// key always gives the same mapping. It caches mappings and is not safe for
+// concurrent use.
+type Anonymizer struct {
+	opts   AnonymizeOptions
+	block  cipher.Block
+	pad    [aes.BlockSize]byte
+	macKey []byte
+
+	ips  map[string]net.IP
+	macs map[string]net.HardwareAddr
+}
+
+// NewAnonymizer derives the cipher key, pad and MAC key from key, which can be
+// any non-empty secret.
+func NewAnonymizer(key string, opts AnonymizeOptions) (*Anonymizer, error) {
+	if key == "" {
+		return nil, fmt.Errorf("anonymization key is empty")
+	}
+
+	secret := sha512.Sum512([]byte(key))
+	block, err := aes.NewCipher(secret[:16])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	a := &Anonymizer{
+		opts:   opts,
+		block:  block,
+		macKey: secret[32:],
+		ips:    make(map[string]net.IP),
+		macs:   make(map[string]net.HardwareAddr),
+	}
+	block.Encrypt(a.pad[:], secret[16:32])
+	return a, nil
+}
+
+// AnonymizeIP returns the pseudonym of ip. Unspecified, loopback, multicast
+// and broadcast addresses are kept, as they identify nobody and tell a lot
+// about the traffic.
+func (a *Anonymizer) AnonymizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsMulticast() || ip.Equal(net.IPv4bcast) {
+		return ip
+	}
+	if anon, ok := a.ips[string(ip)]; ok {
+		return anon
+	}
+	anon := a.cryptoPAn(ip)
+	a.ips[string(ip)] = anon
+	return anon
+}
+
+// cryptoPAn flips bit i of addr depending on the encryption of the bits in
+// front of it, which is what keeps prefixes intact (Xu et al., 2002).
+func (a *Anonymizer) cryptoPAn(addr []byte) net.IP {
+	var input, output [aes.BlockSize]byte
+	flips := make([]byte, len(addr))
+
+	for pos := 0; pos < len(addr)*8; pos++ {
+		input = a.pad
+		full := pos / 8
+		copy(input[:full], addr[:full])
+		if rem := pos % 8; rem > 0 {
+			mask := byte(0xff) << (8 - rem)
+			input[full] = addr[full]&mask | a.pad[full]&^mask
+		}
+		a.block.Encrypt(output[:], input[:])
+		flips[full] |= (output[0] >> 7) << (7 - pos%8)
+	}
+
+	anon := make(net.IP, len(addr))
+	for i := range addr {
+		anon[i] = addr[i] ^ flips[i]
+	}
+	return anon
+}
+
+// AnonymizeMAC returns the pseudonym of a unicast MAC address, marked as
+// locally administered. Group addresses such as broadcast are kept.
+func (a *Anonymizer) AnonymizeMAC(mac net.HardwareAddr) net.HardwareAddr {
+	if len(mac) == 0 || mac[0]&0x01 != 0 {
+		return mac
+	}
+	if anon, ok := a.macs[string(mac)]; ok {
+		return anon
+	}
+	h := hmac.New(sha256.New, a.macKey)
+	h.Write(mac)
+	anon := net.HardwareAddr(h.Sum(nil)[:len(mac)])
+	anon[0] = anon[0]&^0x01 | 0x02
+	a.macs[string(mac)] = anon
+	return anon
+}
+
+// Anonymize rewrites the addresses of a packet in place, fixes the checksums
+// that cover them and returns the packet, shorter if payloads are truncated.
+// Checksums are computed before truncating, so they still match the original
+// length on the wire. Truncated or fragmented packets keep their transport
+// checksum, as it can't be computed without the whole segment.
+func (a *Anonymizer) Anonymize(data []byte, linkType layers.LinkType) []byte {
+	packet := gopacket.NewPacket(data, linkType, gopacket.DecodeOptions{NoCopy: true})
+
+	// Decoded layers follow each other in data, so their offsets add up.
+	offset := 0
+	netOffset := -1
+	var netLayer gopacket.Layer
+	cut := len(data)
+	for _, layer := range packet.Layers() {
+		start := offset
+		offset += len(layer.LayerContents())
+		if offset > len(data) {
+			break
+		}
+
+		switch l := layer.(type) {
+		case *layers.Ethernet:
+			if a.opts.MACs {
+				copy(data[start:], a.AnonymizeMAC(l.DstMAC))
+				copy(data[start+6:], a.AnonymizeMAC(l.SrcMAC))
+			}
+		case *layers.ARP:
+			a.anonymizeARP(data[start:offset], l)
+		case *layers.IPv4:
+			copy(data[start+12:], a.AnonymizeIP(l.SrcIP))
+			copy(data[start+16:], a.AnonymizeIP(l.DstIP))
+			binary.BigEndian.PutUint16(data[start+10:], 0)
+			binary.BigEndian.PutUint16(data[start+10:], checksum(0, data[start:offset]))
+			netOffset, netLayer = start, l
+			cut = offset
+		case *layers.IPv6:
+			copy(data[start+8:], a.AnonymizeIP(l.SrcIP))
+			copy(data[start+24:], a.AnonymizeIP(l.DstIP))
+			netOffset, netLayer = start, l
+			cut = offset
+		case *layers.TCP:
+			fixTransportChecksum(data, netOffset, netLayer, start, 16, layers.IPProtocolTCP)
+			cut = offset
+		case *layers.UDP:
+			// A zero checksum is optional over IPv4 and stays off.
+			if _, ipv4 := netLayer.(*layers.IPv4); !ipv4 || l.Checksum != 0 {
+				fixTransportChecksum(data, netOffset, netLayer, start, 6, layers.IPProtocolUDP)
+			}
+			cut = offset
+		case *layers.ICMPv4:
+			cut = offset
+		case *layers.ICMPv6:
+			fixTransportChecksum(data, netOffset, netLayer, start, 2, layers.IPProtocolICMPv6)
+			cut = offset
+		}
+	}
+
+	if a.opts.TruncatePayload {
+		return data[:cut]
+	}
+	return data
+}
+
+func (a *Anonymizer) anonymizeARP(data []byte, arp *layers.ARP) {
+	hw, prot := int(arp.HwAddressSize), int(arp.ProtAddressSize)
+	if len(data) < 8+2*hw+2*prot {
+		return
+	}
+	if a.opts.MACs && arp.AddrType == layers.LinkTypeEthernet {
+		copy(data[8:], a.AnonymizeMAC(arp.SourceHwAddress))
+		copy(data[8+hw+prot:], a.AnonymizeMAC(arp.DstHwAddress))
+	}
+	if arp.Protocol == layers.EthernetTypeIPv4 && prot == net.IPv4len {
+		copy(data[8+hw:], a.AnonymizeIP(arp.SourceProtAddress))
+		copy(data[8+2*hw+prot:], a.AnonymizeIP(arp.DstProtAddress))
+	}
+}
+
+// fixTransportChecksum recomputes the transport checksum at start+field, which covers
+// a pseudo header with the rewritten addresses of the network layer.
+func fixTransportChecksum(data []byte, netOffset int, netLayer gopacket.Layer, start, field int, proto layers.IPProtocol) {
+	var pseudo []byte
+	var end int
+	switch ip := netLayer.(type) {
+	case *layers.IPv4:
+		if ip.Flags&layers.IPv4MoreFragments != 0 || ip.FragOffset != 0 {
+			return
+		}
+		end = netOffset + int(ip.Length)
+		pseudo = make([]byte, 12)
+		copy(pseudo, data[netOffset+12:netOffset+20])
+		pseudo[9] = byte(proto)
+		binary.BigEndian.PutUint16(pseudo[10:], uint16(end-start))
+	case *layers.IPv6:
+		end = netOffset + 40 + int(ip.Length)
+		pseudo = make([]byte, 40)
+		copy(pseudo, data[netOffset+8:netOffset+40])
+		binary.BigEndian.PutUint32(pseudo[32:], uint32(end-start))
+		pseudo[39] = byte(proto)
+	default:
+		return
+	}
+	if end > len(data) || end < start+field+2 {
+		return
+	}
+
+	binary.BigEndian.PutUint16(data[start+field:], 0)
+	sum := checksum(checksumAdd(0, pseudo), data[start:end])
+	if sum == 0 && proto == layers.IPProtocolUDP {
+		// Zero would mean the checksum is not set.
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(data[start+field:], sum)
+}
+
+func checksumAdd(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+// checksum is the Internet checksum (RFC 1071) of data, continuing sum.
+func checksum(sum uint32, data []byte) uint16 {
+	sum = checksumAdd(sum, data)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
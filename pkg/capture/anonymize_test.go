@@ -0,0 +1,228 @@
+package capture
+
+import (
+	"bytes"
+	"math/bits"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// commonPrefix is the number of leading bits a and b share.
+func commonPrefix(a, b net.IP) int {
+	n := 0
+	for i := range a {
+		x := a[i] ^ b[i]
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
+
+func TestAnonymizeIPPreservesPrefixes(t *testing.T) {
+	a, err := NewAnonymizer("secret", AnonymizeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"same /24", "10.1.2.3", "10.1.2.200"},
+		{"same /16", "10.1.2.3", "10.1.99.3"},
+		{"same /8", "10.1.2.3", "10.200.2.3"},
+		{"differ in last bit", "192.168.0.2", "192.168.0.3"},
+		{"differ in first bit", "10.0.0.1", "200.0.0.1"},
+		{"ipv6 same /64", "2001:db8::1", "2001:db8::ffff:1"},
+		{"ipv6 same /32", "2001:db8:1::1", "2001:db8:2::1"},
+		{"ipv6 differ in first bit", "2001:db8::1", "fd00::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipA, ipB := net.ParseIP(tt.a), net.ParseIP(tt.b)
+			if v4 := ipA.To4(); v4 != nil {
+				ipA, ipB = v4, ipB.To4()
+			}
+			anonA, anonB := a.AnonymizeIP(ipA), a.AnonymizeIP(ipB)
+			if len(anonA) != len(ipA) {
+				t.Fatalf("AnonymizeIP(%s) has length %d, want %d", tt.a, len(anonA), len(ipA))
+			}
+			if anonA.Equal(ipA) && anonB.Equal(ipB) {
+				t.Errorf("addresses were not changed")
+			}
+			want := commonPrefix(ipA, ipB)
+			if got := commonPrefix(anonA, anonB); got != want {
+				t.Errorf("%s and %s share %d bits, want %d (%s, %s)", anonA, anonB, got, want, tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestAnonymizeIPIsKeyed(t *testing.T) {
+	ip := net.ParseIP("10.1.2.3").To4()
+	first, _ := NewAnonymizer("secret", AnonymizeOptions{})
+	again, _ := NewAnonymizer("secret", AnonymizeOptions{})
+	other, _ := NewAnonymizer("other", AnonymizeOptions{})
+
+	if !first.AnonymizeIP(ip).Equal(again.AnonymizeIP(ip)) {
+		t.Errorf("same key gave different mappings")
+	}
+	if first.AnonymizeIP(ip).Equal(other.AnonymizeIP(ip)) {
+		t.Errorf("different keys gave the same mapping")
+	}
+	if _, err := NewAnonymizer("", AnonymizeOptions{}); err == nil {
+		t.Errorf("empty key was accepted")
+	}
+}
+
+func TestAnonymizeIPKeepsSpecialAddresses(t *testing.T) {
+	a, _ := NewAnonymizer("secret", AnonymizeOptions{})
+	for _, addr := range []string{"0.0.0.0", "127.0.0.1", "224.0.0.251", "255.255.255.255", "::", "::1", "ff02::1"} {
+		ip := net.ParseIP(addr)
+		if got := a.AnonymizeIP(ip); !got.Equal(ip) {
+			t.Errorf("AnonymizeIP(%s) = %s, want it kept", addr, got)
+		}
+	}
+}
+
+func TestAnonymizeMAC(t *testing.T) {
+	a, _ := NewAnonymizer("secret", AnonymizeOptions{MACs: true})
+	mac := net.HardwareAddr{0x00, 0x1b, 0x21, 0x3a, 0x4b, 0x5c}
+	anon := a.AnonymizeMAC(mac)
+	if bytes.Equal(anon, mac) {
+		t.Errorf("MAC was not changed")
+	}
+	if anon[0]&0x01 != 0 || anon[0]&0x02 == 0 {
+		t.Errorf("AnonymizeMAC(%s) = %s, want a locally administered unicast address", mac, anon)
+	}
+	broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if got := a.AnonymizeMAC(broadcast); !bytes.Equal(got, broadcast) {
+		t.Errorf("AnonymizeMAC(%s) = %s, want it kept", broadcast, got)
+	}
+}
+
+// serializePacket builds a packet from the given layers with valid lengths
+// and checksums.
+func serializePacket(t *testing.T, all ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+	var netLayer gopacket.NetworkLayer
+	for _, l := range all {
+		if n, ok := l.(gopacket.NetworkLayer); ok {
+			netLayer = n
+		}
+		if l, ok := l.(interface {
+			SetNetworkLayerForChecksum(gopacket.NetworkLayer) error
+		}); ok && netLayer != nil {
+			if err := l.SetNetworkLayerForChecksum(netLayer); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, all...); err != nil {
+		t.Fatalf("failed to serialize packet: %v", err)
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+// onesSum is the folded one's complement sum of chunks, 0xffff over data
+// that includes a valid Internet checksum.
+func onesSum(chunks ...[]byte) uint16 {
+	var sum uint32
+	for _, chunk := range chunks {
+		for i := 0; i < len(chunk); i += 2 {
+			word := uint32(chunk[i]) << 8
+			if i+1 < len(chunk) {
+				word |= uint32(chunk[i+1])
+			}
+			sum += word
+		}
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return uint16(sum)
+}
+
+// verifyChecksums checks the IPv4 header checksum and the transport checksum
+// of data, which covers a pseudo header with the addresses.
+func verifyChecksums(t *testing.T, data []byte) {
+	t.Helper()
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	offset := 0
+	var pseudo []byte
+	var end int
+	for _, layer := range packet.Layers() {
+		start := offset
+		offset += len(layer.LayerContents())
+		switch l := layer.(type) {
+		case *layers.IPv4:
+			if sum := onesSum(data[start:offset]); sum != 0xffff {
+				t.Errorf("invalid IPv4 header checksum %#04x", l.Checksum)
+			}
+			end = start + int(l.Length)
+			pseudo = append(append([]byte{}, data[start+12:start+20]...), 0, byte(l.Protocol), byte((end-offset)>>8), byte(end-offset))
+		case *layers.IPv6:
+			end = offset + int(l.Length)
+			pseudo = append(append([]byte{}, data[start+8:start+40]...), 0, 0, byte(l.Length>>8), byte(l.Length), 0, 0, 0, byte(l.NextHeader))
+		case *layers.TCP, *layers.UDP, *layers.ICMPv6:
+			if sum := onesSum(pseudo, data[start:end]); sum != 0xffff {
+				t.Errorf("invalid %s checksum", layer.LayerType())
+			}
+		case *layers.ICMPv4:
+			if sum := onesSum(data[start:end]); sum != 0xffff {
+				t.Errorf("invalid ICMPv4 checksum")
+			}
+		}
+	}
+}
+
+func TestAnonymizeKeepsChecksumsValid(t *testing.T) {
+	ip4 := func(proto layers.IPProtocol) *layers.IPv4 {
+		return &layers.IPv4{Version: 4, TTL: 64, Protocol: proto, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	}
+	ip6 := func(next layers.IPProtocol) *layers.IPv6 {
+		return &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: next, SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")}
+	}
+	eth4 := &layers.Ethernet{SrcMAC: testSrcMAC, DstMAC: testDstMAC, EthernetType: layers.EthernetTypeIPv4}
+	eth6 := &layers.Ethernet{SrcMAC: testSrcMAC, DstMAC: testDstMAC, EthernetType: layers.EthernetTypeIPv6}
+	payload := gopacket.Payload("hello, world")
+
+	tests := []struct {
+		name   string
+		layers []gopacket.SerializableLayer
+	}{
+		{"ipv4 tcp", []gopacket.SerializableLayer{eth4, ip4(layers.IPProtocolTCP), &layers.TCP{SrcPort: 40000, DstPort: 80, ACK: true, Window: 1024}, payload}},
+		{"ipv4 udp", []gopacket.SerializableLayer{eth4, ip4(layers.IPProtocolUDP), &layers.UDP{SrcPort: 40000, DstPort: 9999}, payload}},
+		{"ipv4 icmp", []gopacket.SerializableLayer{eth4, ip4(layers.IPProtocolICMPv4), &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1}, payload}},
+		{"ipv6 tcp", []gopacket.SerializableLayer{eth6, ip6(layers.IPProtocolTCP), &layers.TCP{SrcPort: 40000, DstPort: 443, SYN: true, Window: 1024}}},
+		{"ipv6 udp", []gopacket.SerializableLayer{eth6, ip6(layers.IPProtocolUDP), &layers.UDP{SrcPort: 40000, DstPort: 9999}, payload}},
+		{"ipv6 icmp6", []gopacket.SerializableLayer{eth6, ip6(layers.IPProtocolICMPv6), &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeEchoRequest, 0)}, &layers.ICMPv6Echo{Identifier: 1, SeqNumber: 1}, payload}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := serializePacket(t, tt.layers...)
+			a, _ := NewAnonymizer("secret", AnonymizeOptions{MACs: true})
+			anon := a.Anonymize(append([]byte(nil), original...), layers.LinkTypeEthernet)
+
+			if bytes.Equal(anon, original) {
+				t.Fatalf("packet was not changed")
+			}
+			src, dst, _ := packetAddrs(gopacket.NewPacket(anon, layers.LayerTypeEthernet, gopacket.Default))
+			wantSrc, wantDst, _ := packetAddrs(gopacket.NewPacket(original, layers.LayerTypeEthernet, gopacket.Default))
+			if !src.Equal(a.AnonymizeIP(wantSrc)) || !dst.Equal(a.AnonymizeIP(wantDst)) {
+				t.Errorf("addresses are %s > %s, want %s > %s", src, dst, a.AnonymizeIP(wantSrc), a.AnonymizeIP(wantDst))
+			}
+			verifyChecksums(t, original)
+			verifyChecksums(t, anon)
+		})
+	}
+}
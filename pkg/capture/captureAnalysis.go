@@ -1,17 +1,20 @@
 package capture
 
 import (
-	"fmt"
+	"errors"
+	"io"
 	"sort"
 	"time"
 
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
 
 	"github.com/google/gopacket"
-	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcap"
 )
 
+// AnalyzerVersion is stored with every stats row. Bump it whenever the analysis
+// output changes so older rows can be found and re-analyzed.
+const AnalyzerVersion = 9
+
 type CaptureStats struct {
 	TotalPackets         int            `json:"total_packets"`
 	ProtocolDistribution map[string]int `json:"protocol_distribution"`
@@ -28,17 +31,44 @@ type CaptureStats struct {
 	DurationSeconds int64     `json:"duration_seconds"`
 	FirstPacketTime time.Time `json:"first_packet_time"`
 	LastPacketTime  time.Time `json:"last_packet_time"`
+
+	Flows       []Flow            `json:"flows"`
+	DNSQueries  []DNSQuery        `json:"dns_queries"`
+	TLSSessions []TLSSession      `json:"tls_sessions"`
+	HTTP        []HTTPTransaction `json:"http"`
+	Timeline    []TimelineBucket  `json:"timeline"`
+	TCPHealth   *TCPHealth        `json:"tcp_health,omitempty"`
+	Findings    []Finding         `json:"findings"`
+
+	// Persisters are the analyzers that store their own results.
+	Persisters []Persister `json:"-"`
 }
 
+// AnalyzeCaptureFile analyzes a capture file. If it is corrupt after the
+// header, the stats of the packets before the damage are returned together
+// with the *CorruptError.
 func AnalyzeCaptureFile(cfg config.Config, filePath string) (CaptureStats, error) {
-	_ = cfg
+	src, closer, err := OpenFile(filePath)
+	if err != nil {
+		return CaptureStats{}, err
+	}
+	defer closer.Close()
+
+	return analyze(cfg, src)
+}
 
-	handle, err := pcap.OpenOffline(filePath)
+// AnalyzeCapture analyzes a pcap or pcapng stream, gzip compressed or not,
+// like AnalyzeCaptureFile.
+func AnalyzeCapture(cfg config.Config, r io.Reader) (CaptureStats, error) {
+	src, err := NewReader(r)
 	if err != nil {
-		return CaptureStats{}, fmt.Errorf("failed to open pcap: %w", err)
+		return CaptureStats{}, err
 	}
-	defer handle.Close()
 
+	return analyze(cfg, src)
+}
+
+func analyze(cfg config.Config, src PacketReader) (CaptureStats, error) {
 	stats := CaptureStats{
 		ProtocolDistribution: make(map[string]int),
 		TopSrcIPs:            make(map[string]int),
@@ -49,13 +79,24 @@ func AnalyzeCaptureFile(cfg config.Config, filePath string) (CaptureStats, error
 		TopUDPDstPorts:       make(map[uint16]int),
 	}
 
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
 	var totalPackets int
 	var totalBytes int64
 	var firstTime, lastTime time.Time
+	analyzers := newAnalyzers(cfg.Analyzers)
+
+	// A read error ends the capture; what was read before is still analyzed.
+	var readErr error
+	for {
+		data, ci, err := readRecord(src)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = corruptAt(totalPackets+1, err)
+			break
+		}
+		packet := gopacket.NewPacket(data, packetLinkType(src, ci), gopacket.Default)
 
-	for packet := range packetSource.Packets() {
-		ci := packet.Metadata().CaptureInfo
 		if firstTime.IsZero() {
 			firstTime = ci.Timestamp
 		}
@@ -63,45 +104,19 @@ func AnalyzeCaptureFile(cfg config.Config, filePath string) (CaptureStats, error
 
 		totalPackets++
 		totalBytes += int64(ci.Length)
-
-		if ipv4Layer := packet.Layer(layers.LayerTypeIPv4); ipv4Layer != nil {
-			ipv4 := ipv4Layer.(*layers.IPv4)
-			stats.ProtocolDistribution["IPv4"]++
-			stats.TopSrcIPs[ipv4.SrcIP.String()]++
-			stats.TopDstIPs[ipv4.DstIP.String()]++
-		}
-
-		if ipv6Layer := packet.Layer(layers.LayerTypeIPv6); ipv6Layer != nil {
-			ipv6 := ipv6Layer.(*layers.IPv6)
-			stats.ProtocolDistribution["IPv6"]++
-			stats.TopSrcIPs[ipv6.SrcIP.String()]++
-			stats.TopDstIPs[ipv6.DstIP.String()]++
-		}
-
-		if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-			tcp := tcpLayer.(*layers.TCP)
-			stats.ProtocolDistribution["TCP"]++
-			stats.TopTCPDstPorts[uint16(tcp.DstPort)]++
-			stats.TopTCPSrcPorts[uint16(tcp.SrcPort)]++
-		}
-
-		if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-			udp := udpLayer.(*layers.UDP)
-			stats.ProtocolDistribution["UDP"]++
-			stats.TopUDPDstPorts[uint16(udp.DstPort)]++
-			stats.TopUDPSrcPorts[uint16(udp.SrcPort)]++
-		}
-
-		if packet.Layer(layers.LayerTypeICMPv4) != nil {
-			stats.ProtocolDistribution["ICMP"]++
-		}
-		if packet.Layer(layers.LayerTypeICMPv6) != nil {
-			stats.ProtocolDistribution["ICMPv6"]++
+		for _, analyzer := range analyzers {
+			analyzer.HandlePacket(packet, ci)
 		}
 	}
 
 	stats.FirstPacketTime = firstTime
 	stats.LastPacketTime = lastTime
+	for _, analyzer := range analyzers {
+		analyzer.Finalize(&stats)
+		if persister, ok := analyzer.(Persister); ok {
+			stats.Persisters = append(stats.Persisters, persister)
+		}
+	}
 
 	dur := lastTime.Sub(firstTime)
 	dur = max(dur, 0)
@@ -118,12 +133,7 @@ func AnalyzeCaptureFile(cfg config.Config, filePath string) (CaptureStats, error
 		stats.PacketRate = float64(totalPackets)
 	}
 
-	stats.TopTCPSrcPorts = limitTopPorts(stats.TopTCPSrcPorts, 10)
-	stats.TopTCPDstPorts = limitTopPorts(stats.TopTCPDstPorts, 10)
-	stats.TopUDPSrcPorts = limitTopPorts(stats.TopUDPSrcPorts, 10)
-	stats.TopUDPDstPorts = limitTopPorts(stats.TopUDPDstPorts, 10)
-
-	return stats, nil
+	return stats, readErr
 }
 
 func limitTopPorts(src map[uint16]int, limit int) map[uint16]int {
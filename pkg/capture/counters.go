@@ -0,0 +1,121 @@
+package capture
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ipCounter counts IPv4 or IPv6 packets and their source and destination
+// addresses.
+type ipCounter struct {
+	protocol string
+	packets  int
+	src      map[string]int
+	dst      map[string]int
+}
+
+func newIPCounter(protocol string) *ipCounter {
+	return &ipCounter{protocol: protocol, src: make(map[string]int), dst: make(map[string]int)}
+}
+
+func (c *ipCounter) HandlePacket(packet gopacket.Packet, _ gopacket.CaptureInfo) {
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		if c.protocol != "IPv4" {
+			return
+		}
+		c.src[ip.SrcIP.String()]++
+		c.dst[ip.DstIP.String()]++
+	case *layers.IPv6:
+		if c.protocol != "IPv6" {
+			return
+		}
+		c.src[ip.SrcIP.String()]++
+		c.dst[ip.DstIP.String()]++
+	default:
+		return
+	}
+	c.packets++
+}
+
+func (c *ipCounter) Finalize(stats *CaptureStats) {
+	if c.packets == 0 {
+		return
+	}
+	stats.ProtocolDistribution[c.protocol] += c.packets
+	for ip, count := range c.src {
+		stats.TopSrcIPs[ip] += count
+	}
+	for ip, count := range c.dst {
+		stats.TopDstIPs[ip] += count
+	}
+}
+
+// portCounter counts TCP or UDP packets and their top ports.
+type portCounter struct {
+	protocol string
+	packets  int
+	src      map[uint16]int
+	dst      map[uint16]int
+}
+
+func newPortCounter(protocol string) *portCounter {
+	return &portCounter{protocol: protocol, src: make(map[uint16]int), dst: make(map[uint16]int)}
+}
+
+func (c *portCounter) HandlePacket(packet gopacket.Packet, _ gopacket.CaptureInfo) {
+	switch transport := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		if c.protocol != "TCP" {
+			return
+		}
+		c.src[uint16(transport.SrcPort)]++
+		c.dst[uint16(transport.DstPort)]++
+	case *layers.UDP:
+		if c.protocol != "UDP" {
+			return
+		}
+		c.src[uint16(transport.SrcPort)]++
+		c.dst[uint16(transport.DstPort)]++
+	default:
+		return
+	}
+	c.packets++
+}
+
+func (c *portCounter) Finalize(stats *CaptureStats) {
+	if c.packets > 0 {
+		stats.ProtocolDistribution[c.protocol] += c.packets
+	}
+	switch c.protocol {
+	case "TCP":
+		stats.TopTCPSrcPorts = limitTopPorts(c.src, 10)
+		stats.TopTCPDstPorts = limitTopPorts(c.dst, 10)
+	case "UDP":
+		stats.TopUDPSrcPorts = limitTopPorts(c.src, 10)
+		stats.TopUDPDstPorts = limitTopPorts(c.dst, 10)
+	}
+}
+
+type icmpCounter struct {
+	icmpv4 int
+	icmpv6 int
+}
+
+func (c *icmpCounter) HandlePacket(packet gopacket.Packet, _ gopacket.CaptureInfo) {
+	if packet.Layer(layers.LayerTypeICMPv4) != nil {
+		c.icmpv4++
+	}
+	if packet.Layer(layers.LayerTypeICMPv6) != nil {
+		c.icmpv6++
+	}
+}
+
+func (c *icmpCounter) Finalize(stats *CaptureStats) {
+	if c.icmpv4 > 0 {
+		stats.ProtocolDistribution["ICMP"] += c.icmpv4
+	}
+	if c.icmpv6 > 0 {
+		stats.ProtocolDistribution["ICMPv6"] += c.icmpv6
+	}
+}
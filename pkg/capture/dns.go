@@ -0,0 +1,178 @@
+package capture
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// DNSQuery is one question seen in the capture, paired with its response when
+// the response was captured too.
+type DNSQuery struct {
+	QueryTime     time.Time `json:"query_time"`
+	ResponseTime  time.Time `json:"response_time,omitzero"`
+	ClientIP      string    `json:"client_ip"`
+	ServerIP      string    `json:"server_ip"`
+	TransactionID uint16    `json:"transaction_id"`
+	Name          string    `json:"name"`
+	RecordType    string    `json:"record_type"`
+	Answered      bool      `json:"answered"`
+	ResponseCode  string    `json:"response_code,omitempty"`
+	Answers       []string  `json:"answers,omitempty"`
+}
+
+type dnsKey struct {
+	clientIP   string
+	clientPort uint16
+	serverIP   string
+	id         uint16
+}
+
+type dnsTable struct {
+	pending map[dnsKey][]*DNSQuery
+	queries []*DNSQuery
+	// result is what Finalize reported, for Persist.
+	result []DNSQuery
+}
+
+func newDNSTable() *dnsTable {
+	return &dnsTable{pending: make(map[dnsKey][]*DNSQuery)}
+}
+
+// normalizeDNSName lowercases a name and strips the trailing root dot so
+// lookups match regardless of how the name was written.
+func normalizeDNSName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func (t *dnsTable) HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo) {
+	dnsLayer := packet.Layer(layers.LayerTypeDNS)
+	if dnsLayer == nil {
+		return
+	}
+	dns := dnsLayer.(*layers.DNS)
+
+	key, ok := packetFlowKey(packet)
+	if !ok {
+		return
+	}
+
+	if !dns.QR {
+		k := dnsKey{clientIP: key.srcIP, clientPort: key.srcPort, serverIP: key.dstIP, id: dns.ID}
+		for _, q := range dns.Questions {
+			query := &DNSQuery{
+				QueryTime:     ci.Timestamp,
+				ClientIP:      key.srcIP,
+				ServerIP:      key.dstIP,
+				TransactionID: dns.ID,
+				Name:          normalizeDNSName(string(q.Name)),
+				RecordType:    q.Type.String(),
+			}
+			t.pending[k] = append(t.pending[k], query)
+			t.queries = append(t.queries, query)
+		}
+		return
+	}
+
+	k := dnsKey{clientIP: key.dstIP, clientPort: key.dstPort, serverIP: key.srcIP, id: dns.ID}
+	answers := formatDNSAnswers(dns.Answers)
+	rcode := formatDNSResponseCode(dns.ResponseCode)
+
+	matched, found := t.pending[k]
+	if found {
+		delete(t.pending, k)
+		for _, query := range matched {
+			query.ResponseTime = ci.Timestamp
+			query.Answered = true
+			query.ResponseCode = rcode
+			query.Answers = answers
+		}
+		return
+	}
+
+	// Response without a captured query, e.g. the capture started mid-exchange.
+	for _, q := range dns.Questions {
+		t.queries = append(t.queries, &DNSQuery{
+			QueryTime:     ci.Timestamp,
+			ResponseTime:  ci.Timestamp,
+			ClientIP:      key.dstIP,
+			ServerIP:      key.srcIP,
+			TransactionID: dns.ID,
+			Name:          normalizeDNSName(string(q.Name)),
+			RecordType:    q.Type.String(),
+			Answered:      true,
+			ResponseCode:  rcode,
+			Answers:       answers,
+		})
+	}
+}
+
+// formatDNSResponseCode uses the mnemonic names analysts know from dig and
+// Wireshark instead of gopacket's prose descriptions.
+func formatDNSResponseCode(code layers.DNSResponseCode) string {
+	switch code {
+	case layers.DNSResponseCodeNoErr:
+		return "NOERROR"
+	case layers.DNSResponseCodeFormErr:
+		return "FORMERR"
+	case layers.DNSResponseCodeServFail:
+		return "SERVFAIL"
+	case layers.DNSResponseCodeNXDomain:
+		return "NXDOMAIN"
+	case layers.DNSResponseCodeNotImp:
+		return "NOTIMP"
+	case layers.DNSResponseCodeRefused:
+		return "REFUSED"
+	default:
+		return code.String()
+	}
+}
+
+func formatDNSAnswers(records []layers.DNSResourceRecord) []string {
+	answers := make([]string, 0, len(records))
+	for _, rr := range records {
+		var value string
+		switch rr.Type {
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			value = rr.IP.String()
+		case layers.DNSTypeCNAME:
+			value = normalizeDNSName(string(rr.CNAME))
+		case layers.DNSTypeNS:
+			value = normalizeDNSName(string(rr.NS))
+		case layers.DNSTypePTR:
+			value = normalizeDNSName(string(rr.PTR))
+		case layers.DNSTypeMX:
+			value = fmt.Sprintf("%d %s", rr.MX.Preference, normalizeDNSName(string(rr.MX.Name)))
+		case layers.DNSTypeSRV:
+			value = fmt.Sprintf("%d %d %d %s", rr.SRV.Priority, rr.SRV.Weight, rr.SRV.Port, normalizeDNSName(string(rr.SRV.Name)))
+		case layers.DNSTypeTXT:
+			parts := make([]string, 0, len(rr.TXTs))
+			for _, txt := range rr.TXTs {
+				parts = append(parts, string(txt))
+			}
+			value = strings.Join(parts, " ")
+		case layers.DNSTypeSOA:
+			value = fmt.Sprintf("%s %s %d", normalizeDNSName(string(rr.SOA.MName)), normalizeDNSName(string(rr.SOA.RName)), rr.SOA.Serial)
+		default:
+			value = fmt.Sprintf("%d bytes", len(rr.Data))
+		}
+		answers = append(answers, rr.Type.String()+" "+value)
+	}
+	return answers
+}
+
+func (t *dnsTable) Finalize(stats *CaptureStats) {
+	t.result = t.list()
+	stats.DNSQueries = t.result
+}
+
+func (t *dnsTable) list() []DNSQuery {
+	queries := make([]DNSQuery, 0, len(t.queries))
+	for _, query := range t.queries {
+		queries = append(queries, *query)
+	}
+	return queries
+}
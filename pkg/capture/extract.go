@@ -0,0 +1,75 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// extractSnaplen is written into the header of extracted files. It only has
+// to be at least as large as the biggest packet copied.
+const extractSnaplen = 262144
+
+// ExtractOptions selects the packets Extract copies. A nil Filter matches
+// every packet; zero From/To leave that side of the [From, To) window open.
+// A non-nil Anonymizer rewrites the packets on the way out.
+type ExtractOptions struct {
+	Filter     Filter
+	From       time.Time
+	To         time.Time
+	Anonymizer *Anonymizer
+}
+
+// Extract copies the matching packets of src into w as a classic pcap file
+// and returns how many were written. A pcap file has a single link type, so
+// packets of pcapng interfaces with a different one than the first are left
+// out.
+func Extract(src PacketReader, w io.Writer, opts ExtractOptions) (int, error) {
+	linkType := src.LinkType()
+	writer := pcapgo.NewWriterNanos(w)
+	if err := writer.WriteFileHeader(extractSnaplen, linkType); err != nil {
+		return 0, fmt.Errorf("failed to write pcap header: %w", err)
+	}
+
+	written := 0
+	for read := 1; ; read++ {
+		data, ci, err := src.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to read packet %d: %w", read, err)
+		}
+
+		if !opts.From.IsZero() && ci.Timestamp.Before(opts.From) {
+			continue
+		}
+		if !opts.To.IsZero() && !ci.Timestamp.Before(opts.To) {
+			continue
+		}
+		if packetLinkType(src, ci) != linkType {
+			continue
+		}
+		if opts.Filter != nil {
+			packet := gopacket.NewPacket(data, linkType, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+			packet.Metadata().CaptureInfo = ci
+			if !opts.Filter.Match(packet) {
+				continue
+			}
+		}
+
+		if opts.Anonymizer != nil {
+			data = opts.Anonymizer.Anonymize(data, linkType)
+			ci.CaptureLength = len(data)
+		}
+
+		if err := writer.WritePacket(ci, data); err != nil {
+			return written, fmt.Errorf("failed to write packet: %w", err)
+		}
+		written++
+	}
+}
@@ -0,0 +1,406 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Filter is a parsed packet filter expression.
+type Filter interface {
+	Match(packet gopacket.Packet) bool
+}
+
+type filterFunc func(packet gopacket.Packet) bool
+
+func (f filterFunc) Match(packet gopacket.Packet) bool {
+	return f(packet)
+}
+
+// ParseFilter parses the commonly used subset of the BPF filter syntax (see
+// pcap-filter(7)), so filters work without libpcap:
+//
+//	[proto] [src|dst] host|net|port|portrange <value>
+//	ip, ip6, arp, tcp, udp, icmp, icmp6
+//	less <len>, greater <len>
+//	and/&&, or/||, not/!, parentheses
+//
+// Like tcpdump, a value right after and/or reuses the previous qualifiers, so
+// "port 80 or 443" works. Host names are not resolved.
+func ParseFilter(expr string) (Filter, error) {
+	tokens := tokenizeFilter(expr)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter expression", p.tokens[p.pos])
+	}
+	return filter, nil
+}
+
+func tokenizeFilter(expr string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		case c == '(' || c == ')' || c == '!':
+			flush()
+			tokens = append(tokens, string(c))
+		case (c == '&' || c == '|') && i+1 < len(expr) && expr[i+1] == c:
+			flush()
+			tokens = append(tokens, expr[i:i+2])
+			i++
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+var (
+	filterProtos = map[string]bool{"ether": true, "ip": true, "ip6": true, "arp": true, "tcp": true, "udp": true, "icmp": true, "icmp6": true}
+	filterDirs   = map[string]bool{"src": true, "dst": true}
+	filterTypes  = map[string]bool{"host": true, "net": true, "port": true, "portrange": true}
+)
+
+func isFilterKeyword(tok string) bool {
+	switch tok {
+	case "and", "&&", "or", "||", "not", "!", "(", ")", "less", "greater":
+		return true
+	}
+	return filterProtos[tok] || filterDirs[tok] || filterTypes[tok]
+}
+
+// filterQualifiers are the keywords in front of a primitive's value.
+type filterQualifiers struct {
+	proto string
+	dir   string
+	typ   string
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+	last   *filterQualifiers
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = filterFunc(func(packet gopacket.Packet) bool { return l.Match(packet) || r.Match(packet) })
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = filterFunc(func(packet gopacket.Packet) bool { return l.Match(packet) && r.Match(packet) })
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (Filter, error) {
+	if p.peek() == "not" || p.peek() == "!" {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return filterFunc(func(packet gopacket.Packet) bool { return !inner.Match(packet) }), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (Filter, error) {
+	switch tok := p.peek(); tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	case "(":
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in filter expression")
+		}
+		return inner, nil
+	case ")":
+		return nil, fmt.Errorf("unexpected ) in filter expression")
+	case "less", "greater":
+		p.next()
+		value := p.next()
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid length %q after %s", value, tok)
+		}
+		if tok == "less" {
+			return filterFunc(func(packet gopacket.Packet) bool { return packetLength(packet) <= length }), nil
+		}
+		return filterFunc(func(packet gopacket.Packet) bool { return packetLength(packet) >= length }), nil
+	}
+	return p.parsePrimitive()
+}
+
+func (p *filterParser) parsePrimitive() (Filter, error) {
+	var qual filterQualifiers
+	if !isFilterKeyword(p.peek()) {
+		// Bare value, as in "port 80 or 443".
+		if p.last == nil {
+			return nil, fmt.Errorf("unexpected %q in filter expression", p.peek())
+		}
+		qual = *p.last
+	} else {
+		if filterProtos[p.peek()] {
+			qual.proto = p.next()
+		}
+		if filterDirs[p.peek()] {
+			qual.dir = p.next()
+		}
+		if filterTypes[p.peek()] {
+			qual.typ = p.next()
+		}
+
+		value := p.peek()
+		if qual.dir == "" && qual.typ == "" && (value == "" || isFilterKeyword(value)) {
+			if qual.proto == "" {
+				return nil, fmt.Errorf("unexpected %q in filter expression", value)
+			}
+			return protoFilter(qual.proto), nil
+		}
+		if qual.typ == "" {
+			qual.typ = "host"
+		}
+	}
+
+	value := p.next()
+	if value == "" || isFilterKeyword(value) {
+		return nil, fmt.Errorf("missing value after %s", qual.typ)
+	}
+	p.last = &qual
+
+	var match filterFunc
+	var err error
+	switch qual.typ {
+	case "host":
+		match, err = hostFilter(qual, value)
+	case "net":
+		match, err = netFilter(qual, value)
+	case "port", "portrange":
+		match, err = portFilter(qual, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if qual.proto != "" && qual.proto != "ether" {
+		proto := protoFilter(qual.proto)
+		inner := match
+		match = func(packet gopacket.Packet) bool { return proto.Match(packet) && inner(packet) }
+	}
+	return match, nil
+}
+
+func protoFilter(proto string) filterFunc {
+	var layerType gopacket.LayerType
+	switch proto {
+	case "ether":
+		layerType = layers.LayerTypeEthernet
+	case "ip":
+		layerType = layers.LayerTypeIPv4
+	case "ip6":
+		layerType = layers.LayerTypeIPv6
+	case "arp":
+		layerType = layers.LayerTypeARP
+	case "tcp":
+		layerType = layers.LayerTypeTCP
+	case "udp":
+		layerType = layers.LayerTypeUDP
+	case "icmp":
+		layerType = layers.LayerTypeICMPv4
+	case "icmp6":
+		layerType = layers.LayerTypeICMPv6
+	}
+	return func(packet gopacket.Packet) bool { return packet.Layer(layerType) != nil }
+}
+
+// packetAddrs returns the network addresses of IP and ARP packets.
+func packetAddrs(packet gopacket.Packet) (src, dst net.IP, ok bool) {
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		return ip.SrcIP, ip.DstIP, true
+	case *layers.IPv6:
+		return ip.SrcIP, ip.DstIP, true
+	}
+	if arpLayer := packet.Layer(layers.LayerTypeARP); arpLayer != nil {
+		arp := arpLayer.(*layers.ARP)
+		return net.IP(arp.SourceProtAddress), net.IP(arp.DstProtAddress), true
+	}
+	return nil, nil, false
+}
+
+func matchDir(dir string, src, dst bool) bool {
+	switch dir {
+	case "src":
+		return src
+	case "dst":
+		return dst
+	default:
+		return src || dst
+	}
+}
+
+func hostFilter(qual filterQualifiers, value string) (filterFunc, error) {
+	if qual.proto == "ether" {
+		mac, err := net.ParseMAC(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAC address %q", value)
+		}
+		return func(packet gopacket.Packet) bool {
+			ethLayer := packet.Layer(layers.LayerTypeEthernet)
+			if ethLayer == nil {
+				return false
+			}
+			eth := ethLayer.(*layers.Ethernet)
+			return matchDir(qual.dir, bytes.Equal(eth.SrcMAC, mac), bytes.Equal(eth.DstMAC, mac))
+		}, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid host %q, host names are not resolved", value)
+	}
+	return func(packet gopacket.Packet) bool {
+		src, dst, ok := packetAddrs(packet)
+		return ok && matchDir(qual.dir, ip.Equal(src), ip.Equal(dst))
+	}, nil
+}
+
+func netFilter(qual filterQualifiers, value string) (filterFunc, error) {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid network %q, expected CIDR notation", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	return func(packet gopacket.Packet) bool {
+		src, dst, ok := packetAddrs(packet)
+		return ok && matchDir(qual.dir, network.Contains(src), network.Contains(dst))
+	}, nil
+}
+
+func parseFilterPort(value string) (uint16, error) {
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	return uint16(port), nil
+}
+
+func portFilter(qual filterQualifiers, value string) (filterFunc, error) {
+	var low, high uint16
+	var err error
+	if qual.typ == "portrange" {
+		from, to, found := strings.Cut(value, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid port range %q, expected low-high", value)
+		}
+		if low, err = parseFilterPort(from); err != nil {
+			return nil, err
+		}
+		if high, err = parseFilterPort(to); err != nil {
+			return nil, err
+		}
+		if low > high {
+			low, high = high, low
+		}
+	} else {
+		if low, err = parseFilterPort(value); err != nil {
+			return nil, err
+		}
+		high = low
+	}
+
+	return func(packet gopacket.Packet) bool {
+		var src, dst uint16
+		switch transport := packet.TransportLayer().(type) {
+		case *layers.TCP:
+			src, dst = uint16(transport.SrcPort), uint16(transport.DstPort)
+		case *layers.UDP:
+			src, dst = uint16(transport.SrcPort), uint16(transport.DstPort)
+		default:
+			return false
+		}
+		return matchDir(qual.dir, src >= low && src <= high, dst >= low && dst <= high)
+	}, nil
+}
+
+// packetLength is the original length on the wire, as used by less/greater.
+func packetLength(packet gopacket.Packet) int {
+	if md := packet.Metadata(); md != nil && md.Length > 0 {
+		return md.Length
+	}
+	return len(packet.Data())
+}
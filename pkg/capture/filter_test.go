@@ -0,0 +1,137 @@
+package capture
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	testSrcMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	testDstMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+)
+
+// buildPacket serializes an Ethernet/IPv4 packet with the given transport
+// layers and decodes it again, like a packet read from a capture.
+func buildPacket(t *testing.T, src, dst string, proto layers.IPProtocol, transport ...gopacket.SerializableLayer) gopacket.Packet {
+	t.Helper()
+	eth := &layers.Ethernet{SrcMAC: testSrcMAC, DstMAC: testDstMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: proto, SrcIP: net.ParseIP(src).To4(), DstIP: net.ParseIP(dst).To4()}
+	for _, l := range transport {
+		switch l := l.(type) {
+		case *layers.TCP:
+			l.SetNetworkLayerForChecksum(ip)
+		case *layers.UDP:
+			l.SetNetworkLayerForChecksum(ip)
+		}
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, append([]gopacket.SerializableLayer{eth, ip}, transport...)...); err != nil {
+		t.Fatalf("failed to serialize packet: %v", err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestParseFilterMatch(t *testing.T) {
+	packets := map[string]gopacket.Packet{
+		"http": buildPacket(t, "10.0.0.1", "10.0.0.2", layers.IPProtocolTCP,
+			&layers.TCP{SrcPort: 40000, DstPort: 80, SYN: true}),
+		"dns": buildPacket(t, "10.0.0.1", "192.168.1.1", layers.IPProtocolUDP,
+			&layers.UDP{SrcPort: 5353, DstPort: 53}, gopacket.Payload(make([]byte, 200))),
+		"ping": buildPacket(t, "10.0.0.3", "10.0.0.1", layers.IPProtocolICMPv4,
+			&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0)}),
+	}
+
+	tests := []struct {
+		expr  string
+		match []string
+	}{
+		{"tcp", []string{"http"}},
+		{"udp or icmp", []string{"dns", "ping"}},
+		{"ip", []string{"http", "dns", "ping"}},
+		{"ip6", nil},
+		{"host 10.0.0.1", []string{"http", "dns", "ping"}},
+		{"src host 10.0.0.1", []string{"http", "dns"}},
+		{"dst 10.0.0.1", []string{"ping"}},
+		{"net 192.168.0.0/16", []string{"dns"}},
+		{"dst net 10.0.0.2", []string{"http"}},
+		{"port 53", []string{"dns"}},
+		{"tcp port 53", nil},
+		{"port 80 or 53", []string{"http", "dns"}},
+		{"src port 40000", []string{"http"}},
+		{"portrange 50-100", []string{"http", "dns"}},
+		{"portrange 100-50", []string{"http", "dns"}},
+		{"udp dst portrange 1-1024", []string{"dns"}},
+		{"not tcp", []string{"dns", "ping"}},
+		{"! tcp && ! udp", []string{"ping"}},
+		{"host 10.0.0.1 and (port 80 or icmp)", []string{"http", "ping"}},
+		{"not (tcp or udp)", []string{"ping"}},
+		{"greater 200", []string{"dns"}},
+		{"less 100", []string{"http", "ping"}},
+		{"ether src 02:00:00:00:00:01", []string{"http", "dns", "ping"}},
+		{"ether dst host 02:00:00:00:00:01", nil},
+		{"tcp and src 10.0.0.1 and dst port 80", []string{"http"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) failed: %v", tt.expr, err)
+			}
+			want := make(map[string]bool)
+			for _, name := range tt.match {
+				want[name] = true
+			}
+			for name, packet := range packets {
+				if got := filter.Match(packet); got != want[name] {
+					t.Errorf("Match(%s) = %v, want %v", name, got, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "empty filter expression"},
+		{"   ", "empty filter expression"},
+		{"80", `unexpected "80" in filter expression`},
+		{"tcp and", "unexpected end of filter expression"},
+		{"(tcp", "missing ) in filter expression"},
+		{"tcp)", `unexpected ")" in filter expression`},
+		{")", "unexpected ) in filter expression"},
+		{"port", "missing value after port"},
+		{"src", "missing value after host"},
+		{"port and tcp", "missing value after port"},
+		{"port 65536", `invalid port "65536"`},
+		{"port http", `invalid port "http"`},
+		{"portrange 80", `invalid port range "80", expected low-high`},
+		{"portrange 1-x", `invalid port "x"`},
+		{"host example.com", `invalid host "example.com", host names are not resolved`},
+		{"net 10.0.0.0/33", `invalid network "10.0.0.0/33", expected CIDR notation`},
+		{"ether host 10.0.0.1", `invalid MAC address "10.0.0.1"`},
+		{"less", `invalid length "" after less`},
+		{"greater -1", `invalid length "-1" after greater`},
+		{"tcp tcp", `unexpected "tcp" in filter expression`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseFilter(tt.expr)
+			if err == nil {
+				t.Fatalf("ParseFilter(%q) succeeded, want error %q", tt.expr, tt.err)
+			}
+			if err.Error() != tt.err {
+				t.Errorf("ParseFilter(%q) error = %q, want %q", tt.expr, err, tt.err)
+			}
+		})
+	}
+}
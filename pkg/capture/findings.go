@@ -0,0 +1,565 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Finding is something suspicious a detector noticed in the capture.
+type Finding struct {
+	Rule      string         `json:"rule"`
+	Severity  string         `json:"severity"`
+	Summary   string         `json:"summary"`
+	Evidence  map[string]any `json:"evidence"`
+	FirstSeen time.Time      `json:"first_seen"`
+}
+
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// SeverityRank orders severities so they can be compared; unknown values
+// rank below low.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+const (
+	// Scans are counted over the busiest window of this length.
+	scanWindow = time.Minute
+	// Distinct ports one source probes on one target.
+	verticalScanPorts = 50
+	// Distinct targets one source probes on the same port.
+	horizontalScanHosts = 20
+	// SYNs per second to a single service.
+	synFloodRate = 100
+	// Distinct targets one source pings.
+	icmpSweepHosts = 10
+	// Queries with a name this long hint at data encoded into labels.
+	dnsTunnelNameLength = 52
+	dnsTunnelLongNames  = 5
+	// Average entropy of the subdomains, in bits per character, that hints at
+	// encoded data.
+	dnsTunnelEntropy = 3.5
+	// Distinct subdomains queried under one domain.
+	dnsTunnelSubdomains = 50
+)
+
+// probeReply is how the target answered a probe, from least to most
+// conclusive.
+type probeReply int
+
+const (
+	probeUnanswered probeReply = iota
+	// A RST or ICMP unreachable: a closed port.
+	probeRefused
+	// ICMP time exceeded: a traceroute hop.
+	probeExpired
+	// A SYN-ACK or a UDP reply: a conversation with a service.
+	probeAnswered
+)
+
+// probe is the first packet of a TCP handshake or UDP conversation. Only
+// unanswered and refused probes count towards a scan.
+type probe struct {
+	sent  time.Time
+	reply probeReply
+}
+
+// probeHit is one counted probe: the port or target it adds to a scan.
+type probeHit struct {
+	value string
+	at    time.Time
+}
+
+type probeKey struct {
+	src, dst string
+}
+
+type servicePortKey struct {
+	src  string
+	port string
+}
+
+type synRateKey struct {
+	dst    string
+	port   uint16
+	second int64
+}
+
+type dnsDomainStats struct {
+	firstSeen  time.Time
+	subdomains map[string]bool
+	// entropy sums the entropy of the distinct subdomains.
+	entropy   float64
+	longNames int
+	maxLength int
+	sample    string
+}
+
+// findingDetector collects just enough per-packet state to run the detection
+// rules once the capture has been read.
+type findingDetector struct {
+	// Port scans: how every TCP and UDP flow was answered.
+	probes     map[flowKey]*probe
+	synRate    map[synRateKey]int
+	synFirst   map[synRateKey]time.Time
+	icmpTarget map[string]map[string]bool
+	icmpFirst  map[string]time.Time
+	arpMACs    map[string]map[string]bool
+	arpFirst   map[string]time.Time
+	dnsDomains map[string]*dnsDomainStats
+	// result is what Finalize reported, for Persist.
+	result []Finding
+}
+
+func newFindingDetector() *findingDetector {
+	return &findingDetector{
+		probes:     make(map[flowKey]*probe),
+		synRate:    make(map[synRateKey]int),
+		synFirst:   make(map[synRateKey]time.Time),
+		icmpTarget: make(map[string]map[string]bool),
+		icmpFirst:  make(map[string]time.Time),
+		arpMACs:    make(map[string]map[string]bool),
+		arpFirst:   make(map[string]time.Time),
+		dnsDomains: make(map[string]*dnsDomainStats),
+	}
+}
+
+func addToSet[K comparable](m map[K]map[string]bool, key K, value string) {
+	set, ok := m[key]
+	if !ok {
+		set = make(map[string]bool)
+		m[key] = set
+	}
+	set[value] = true
+}
+
+func setFirst[K comparable](m map[K]time.Time, key K, ts time.Time) {
+	if _, ok := m[key]; !ok {
+		m[key] = ts
+	}
+}
+
+func (d *findingDetector) HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo) {
+	if arpLayer := packet.Layer(layers.LayerTypeARP); arpLayer != nil {
+		arp := arpLayer.(*layers.ARP)
+		ip := net.IP(arp.SourceProtAddress).String()
+		addToSet(d.arpMACs, ip, net.HardwareAddr(arp.SourceHwAddress).String())
+		setFirst(d.arpFirst, ip, ci.Timestamp)
+		return
+	}
+
+	key, ok := packetFlowKey(packet)
+	if !ok {
+		return
+	}
+
+	switch transport := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		switch {
+		case transport.SYN && !transport.ACK:
+			d.addProbe(key, ci.Timestamp)
+
+			rateKey := synRateKey{dst: key.dstIP, port: key.dstPort, second: ci.Timestamp.Unix()}
+			d.synRate[rateKey]++
+			setFirst(d.synFirst, rateKey, ci.Timestamp)
+		case transport.SYN && transport.ACK:
+			d.answerProbe(key.reverse(), probeAnswered)
+		case transport.RST:
+			d.answerProbe(key.reverse(), probeRefused)
+		}
+	case *layers.UDP:
+		// A datagram back makes it a conversation, like DNS, QUIC or NTP.
+		if _, ok := d.probes[key.reverse()]; ok {
+			d.answerProbe(key.reverse(), probeAnswered)
+		} else {
+			d.addProbe(key, ci.Timestamp)
+		}
+	}
+
+	if icmpLayer := packet.Layer(layers.LayerTypeICMPv4); icmpLayer != nil {
+		icmp := icmpLayer.(*layers.ICMPv4)
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv4TypeEchoRequest:
+			addToSet(d.icmpTarget, key.srcIP, key.dstIP)
+			setFirst(d.icmpFirst, key.srcIP, ci.Timestamp)
+		case layers.ICMPv4TypeDestinationUnreachable:
+			d.answerQuoted(icmp.Payload, layers.LayerTypeIPv4, probeRefused)
+		case layers.ICMPv4TypeTimeExceeded:
+			d.answerQuoted(icmp.Payload, layers.LayerTypeIPv4, probeExpired)
+		}
+	}
+	if icmpLayer := packet.Layer(layers.LayerTypeICMPv6); icmpLayer != nil {
+		icmp := icmpLayer.(*layers.ICMPv6)
+		// Errors quote the datagram after 4 unused bytes.
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv6TypeEchoRequest:
+			addToSet(d.icmpTarget, key.srcIP, key.dstIP)
+			setFirst(d.icmpFirst, key.srcIP, ci.Timestamp)
+		case layers.ICMPv6TypeDestinationUnreachable:
+			if len(icmp.Payload) > 4 {
+				d.answerQuoted(icmp.Payload[4:], layers.LayerTypeIPv6, probeRefused)
+			}
+		case layers.ICMPv6TypeTimeExceeded:
+			if len(icmp.Payload) > 4 {
+				d.answerQuoted(icmp.Payload[4:], layers.LayerTypeIPv6, probeExpired)
+			}
+		}
+	}
+
+	if dnsLayer := packet.Layer(layers.LayerTypeDNS); dnsLayer != nil {
+		dns := dnsLayer.(*layers.DNS)
+		if !dns.QR {
+			for _, q := range dns.Questions {
+				d.addDNSName(normalizeDNSName(string(q.Name)), ci.Timestamp)
+			}
+		}
+	}
+}
+
+func (d *findingDetector) addProbe(key flowKey, ts time.Time) {
+	if _, ok := d.probes[key]; !ok {
+		d.probes[key] = &probe{sent: ts}
+	}
+}
+
+// answerProbe records a reply to the probe key, unless it already got a more
+// conclusive one.
+func (d *findingDetector) answerProbe(key flowKey, reply probeReply) {
+	if p, ok := d.probes[key]; ok && reply > p.reply {
+		p.reply = reply
+	}
+}
+
+// answerQuoted answers the probe an ICMP error quotes.
+func (d *findingDetector) answerQuoted(quoted []byte, first gopacket.LayerType, reply probeReply) {
+	if key, ok := quotedFlowKey(quoted, first); ok {
+		d.answerProbe(key, reply)
+	}
+}
+
+// quotedFlowKey is the flow of the datagram an ICMP error quotes. Only the
+// first 8 bytes after its IP header are guaranteed to be there, which hold the
+// ports of TCP and UDP alike.
+func quotedFlowKey(quoted []byte, first gopacket.LayerType) (flowKey, bool) {
+	var key flowKey
+	var ipProto layers.IPProtocol
+
+	inner := gopacket.NewPacket(quoted, first, gopacket.NoCopy)
+	switch ip := inner.NetworkLayer().(type) {
+	case *layers.IPv4:
+		key.srcIP = ip.SrcIP.String()
+		key.dstIP = ip.DstIP.String()
+		ipProto = ip.Protocol
+	case *layers.IPv6:
+		key.srcIP = ip.SrcIP.String()
+		key.dstIP = ip.DstIP.String()
+		ipProto = ip.NextHeader
+	default:
+		return key, false
+	}
+
+	switch ipProto {
+	case layers.IPProtocolTCP:
+		key.protocol = "TCP"
+	case layers.IPProtocolUDP:
+		key.protocol = "UDP"
+	default:
+		return key, false
+	}
+	ports := inner.NetworkLayer().LayerPayload()
+	if len(ports) < 4 {
+		return key, false
+	}
+	key.srcPort = binary.BigEndian.Uint16(ports[0:2])
+	key.dstPort = binary.BigEndian.Uint16(ports[2:4])
+	return key, true
+}
+
+// baseDomain splits a name into its registrable domain, per the public suffix
+// list, and the subdomain below it. Names that are a public suffix themselves
+// have no subdomain.
+func baseDomain(name string) (string, string) {
+	base, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return name, ""
+	}
+	return base, strings.TrimSuffix(strings.TrimSuffix(name, base), ".")
+}
+
+func (d *findingDetector) addDNSName(name string, ts time.Time) {
+	base, sub := baseDomain(name)
+	stats, ok := d.dnsDomains[base]
+	if !ok {
+		stats = &dnsDomainStats{firstSeen: ts, subdomains: make(map[string]bool)}
+		d.dnsDomains[base] = stats
+	}
+	if sub != "" && !stats.subdomains[sub] {
+		stats.subdomains[sub] = true
+		stats.entropy += shannonEntropy(sub)
+	}
+	if len(name) >= dnsTunnelNameLength {
+		stats.longNames++
+	}
+	if len(name) > stats.maxLength {
+		stats.maxLength = len(name)
+		stats.sample = name
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedPorts orders "tcp/80" style keys by protocol, then numerically.
+func sortedPorts(set map[string]bool) []string {
+	keys := sortedKeys(set)
+	sort.SliceStable(keys, func(i, j int) bool {
+		pi, ni, _ := strings.Cut(keys[i], "/")
+		pj, nj, _ := strings.Cut(keys[j], "/")
+		if pi != pj {
+			return pi < pj
+		}
+		return len(ni) < len(nj) || (len(ni) == len(nj) && ni < nj)
+	})
+	return keys
+}
+
+// densestWindow finds the scanWindow with the most distinct values among
+// hits, which are sorted by time. It returns those values and when the window
+// starts.
+func densestWindow(hits []probeHit) (map[string]bool, time.Time) {
+	var best map[string]bool
+	var bestStart time.Time
+	counts := make(map[string]int)
+	start := 0
+	for _, hit := range hits {
+		counts[hit.value]++
+		for hit.at.Sub(hits[start].at) > scanWindow {
+			counts[hits[start].value]--
+			if counts[hits[start].value] == 0 {
+				delete(counts, hits[start].value)
+			}
+			start++
+		}
+		if len(counts) > len(best) {
+			best = make(map[string]bool, len(counts))
+			for value := range counts {
+				best[value] = true
+			}
+			bestStart = hits[start].at
+		}
+	}
+	return best, bestStart
+}
+
+func sortedHits(hits []probeHit) []probeHit {
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].at.Before(hits[j].at)
+	})
+	return hits
+}
+
+// sample trims long evidence lists so a single finding stays readable.
+func sample(values []string) []string {
+	const maxSample = 20
+	if len(values) > maxSample {
+		return values[:maxSample]
+	}
+	return values
+}
+
+// shannonEntropy of a string in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func (d *findingDetector) Finalize(stats *CaptureStats) {
+	d.result = d.list()
+	stats.Findings = d.result
+}
+
+func (d *findingDetector) list() []Finding {
+	var findings []Finding
+
+	// Ports per source/target pair and targets per source/port.
+	portHits := make(map[probeKey][]probeHit)
+	hostHits := make(map[servicePortKey][]probeHit)
+	for key, p := range d.probes {
+		if p.reply > probeRefused {
+			continue
+		}
+		port := fmt.Sprintf("%s/%d", strings.ToLower(key.protocol), key.dstPort)
+		pk := probeKey{src: key.srcIP, dst: key.dstIP}
+		portHits[pk] = append(portHits[pk], probeHit{value: port, at: p.sent})
+		sk := servicePortKey{src: key.srcIP, port: port}
+		hostHits[sk] = append(hostHits[sk], probeHit{value: key.dstIP, at: p.sent})
+	}
+
+	for key, hits := range portHits {
+		if len(hits) < verticalScanPorts {
+			continue
+		}
+		ports, first := densestWindow(sortedHits(hits))
+		if len(ports) < verticalScanPorts {
+			continue
+		}
+		severity := SeverityMedium
+		if len(ports) >= verticalScanPorts*10 {
+			severity = SeverityHigh
+		}
+		findings = append(findings, Finding{
+			Rule:      "port_scan_vertical",
+			Severity:  severity,
+			Summary:   fmt.Sprintf("%s probed %d ports on %s", key.src, len(ports), key.dst),
+			Evidence:  map[string]any{"src_ip": key.src, "dst_ip": key.dst, "port_count": len(ports), "ports": sample(sortedPorts(ports))},
+			FirstSeen: first,
+		})
+	}
+
+	for key, hits := range hostHits {
+		if len(hits) < horizontalScanHosts {
+			continue
+		}
+		hosts, first := densestWindow(sortedHits(hits))
+		if len(hosts) < horizontalScanHosts {
+			continue
+		}
+		severity := SeverityMedium
+		if len(hosts) >= horizontalScanHosts*10 {
+			severity = SeverityHigh
+		}
+		findings = append(findings, Finding{
+			Rule:      "port_scan_horizontal",
+			Severity:  severity,
+			Summary:   fmt.Sprintf("%s probed %s on %d hosts", key.src, key.port, len(hosts)),
+			Evidence:  map[string]any{"src_ip": key.src, "port": key.port, "host_count": len(hosts), "hosts": sample(sortedKeys(hosts))},
+			FirstSeen: first,
+		})
+	}
+
+	type floodKey struct {
+		dst  string
+		port uint16
+	}
+	floods := make(map[floodKey]int)
+	floodFirst := make(map[floodKey]time.Time)
+	for key, count := range d.synRate {
+		if count < synFloodRate {
+			continue
+		}
+		fk := floodKey{dst: key.dst, port: key.port}
+		floods[fk] = max(floods[fk], count)
+		if first, ok := floodFirst[fk]; !ok || d.synFirst[key].Before(first) {
+			floodFirst[fk] = d.synFirst[key]
+		}
+	}
+	for key, peak := range floods {
+		findings = append(findings, Finding{
+			Rule:      "syn_flood",
+			Severity:  SeverityHigh,
+			Summary:   fmt.Sprintf("%s:%d received up to %d SYNs per second", key.dst, key.port, peak),
+			Evidence:  map[string]any{"dst_ip": key.dst, "dst_port": key.port, "peak_syns_per_second": peak},
+			FirstSeen: floodFirst[key],
+		})
+	}
+
+	for src, targets := range d.icmpTarget {
+		if len(targets) < icmpSweepHosts {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:      "icmp_sweep",
+			Severity:  SeverityLow,
+			Summary:   fmt.Sprintf("%s pinged %d hosts", src, len(targets)),
+			Evidence:  map[string]any{"src_ip": src, "host_count": len(targets), "hosts": sample(sortedKeys(targets))},
+			FirstSeen: d.icmpFirst[src],
+		})
+	}
+
+	for ip, macs := range d.arpMACs {
+		if len(macs) < 2 || ip == "0.0.0.0" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:      "arp_spoofing",
+			Severity:  SeverityHigh,
+			Summary:   fmt.Sprintf("%s was claimed by %d MAC addresses", ip, len(macs)),
+			Evidence:  map[string]any{"ip": ip, "macs": sortedKeys(macs)},
+			FirstSeen: d.arpFirst[ip],
+		})
+	}
+
+	for domain, stats := range d.dnsDomains {
+		if len(stats.subdomains) < dnsTunnelSubdomains {
+			continue
+		}
+		entropy := stats.entropy / float64(len(stats.subdomains))
+		if stats.longNames < dnsTunnelLongNames && entropy < dnsTunnelEntropy {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     "dns_tunnelling",
+			Severity: SeverityMedium,
+			Summary:  fmt.Sprintf("%d distinct subdomains of %s queried, %d with long names", len(stats.subdomains), domain, stats.longNames),
+			Evidence: map[string]any{
+				"domain":            domain,
+				"unique_subdomains": len(stats.subdomains),
+				"long_names":        stats.longNames,
+				"longest_name":      stats.sample,
+				"subdomain_entropy": math.Round(entropy*100) / 100,
+			},
+			FirstSeen: stats.firstSeen,
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if !findings[i].FirstSeen.Equal(findings[j].FirstSeen) {
+			return findings[i].FirstSeen.Before(findings[j].FirstSeen)
+		}
+		if findings[i].Rule != findings[j].Rule {
+			return findings[i].Rule < findings[j].Rule
+		}
+		return findings[i].Summary < findings[j].Summary
+	})
+	return findings
+}
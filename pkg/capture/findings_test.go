@@ -0,0 +1,339 @@
+package capture
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var findingsStart = time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+
+// timedPacket is a packet seen the given time after findingsStart.
+type timedPacket struct {
+	at     time.Duration
+	packet gopacket.Packet
+}
+
+func syn(t *testing.T, src, dst string, srcPort, dstPort int) gopacket.Packet {
+	return buildPacket(t, src, dst, layers.IPProtocolTCP,
+		&layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), SYN: true})
+}
+
+func synAck(t *testing.T, src, dst string, srcPort, dstPort int) gopacket.Packet {
+	return buildPacket(t, src, dst, layers.IPProtocolTCP,
+		&layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), SYN: true, ACK: true})
+}
+
+func rst(t *testing.T, src, dst string, srcPort, dstPort int) gopacket.Packet {
+	return buildPacket(t, src, dst, layers.IPProtocolTCP,
+		&layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), RST: true, ACK: true})
+}
+
+func ping(t *testing.T, src, dst string) gopacket.Packet {
+	return buildPacket(t, src, dst, layers.IPProtocolICMPv4,
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1})
+}
+
+func dnsQuery(t *testing.T, name string) gopacket.Packet {
+	return buildPacket(t, "10.0.0.1", "10.0.0.53", layers.IPProtocolUDP,
+		&layers.UDP{SrcPort: 40000, DstPort: 53},
+		&layers.DNS{ID: 1, RD: true, Questions: []layers.DNSQuestion{
+			{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN},
+		}})
+}
+
+func arpReply(t *testing.T, ip, mac string) gopacket.Packet {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := serializePacket(t,
+		&layers.Ethernet{SrcMAC: hw, DstMAC: testDstMAC, EthernetType: layers.EthernetTypeARP},
+		&layers.ARP{
+			AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4,
+			HwAddressSize: 6, ProtAddressSize: 4, Operation: layers.ARPReply,
+			SourceHwAddress: hw, SourceProtAddress: net.ParseIP(ip).To4(),
+			DstHwAddress: testDstMAC, DstProtAddress: net.IP{10, 0, 0, 2},
+		})
+	return gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+}
+
+// every repeats one packet per step, starting at start.
+func every(count int, start, step time.Duration, packet func(i int) gopacket.Packet) []timedPacket {
+	packets := make([]timedPacket, count)
+	for i := range packets {
+		packets[i] = timedPacket{at: start + time.Duration(i)*step, packet: packet(i)}
+	}
+	return packets
+}
+
+// portScan probes count ports of 10.0.0.2 from 10.0.0.1, one per step.
+func portScan(t *testing.T, count int, start, step time.Duration) []timedPacket {
+	return every(count, start, step, func(i int) gopacket.Packet {
+		return syn(t, "10.0.0.1", "10.0.0.2", 40000, 1+i)
+	})
+}
+
+// randomLabel is a label of hex digits, like encoded data.
+func randomLabel(i, length int) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte{byte(i), byte(i >> 8)}))[:length]
+}
+
+func detectFindings(t *testing.T, packets []timedPacket) []Finding {
+	t.Helper()
+	d := newFindingDetector()
+	for _, p := range packets {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     findingsStart.Add(p.at),
+			CaptureLength: len(p.packet.Data()),
+			Length:        len(p.packet.Data()),
+		}
+		d.HandlePacket(p.packet, ci)
+	}
+	var stats CaptureStats
+	d.Finalize(&stats)
+	return stats.Findings
+}
+
+func TestFindings(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets []timedPacket
+		// want is the rules reported, in order.
+		want []string
+		// evidence is checked against the first finding.
+		evidence map[string]any
+		// firstSeen is the offset the first finding starts at.
+		firstSeen time.Duration
+	}{
+		{
+			name:    "vertical scan below threshold",
+			packets: portScan(t, verticalScanPorts-1, 0, 100*time.Millisecond),
+		},
+		{
+			name:      "vertical scan at threshold",
+			packets:   portScan(t, verticalScanPorts, 0, 100*time.Millisecond),
+			want:      []string{"port_scan_vertical"},
+			evidence:  map[string]any{"src_ip": "10.0.0.1", "dst_ip": "10.0.0.2", "port_count": verticalScanPorts},
+			firstSeen: 0,
+		},
+		{
+			// 100 ports over 3 minutes, at most 31 in any minute.
+			name:    "vertical scan too slow",
+			packets: portScan(t, 100, 0, 2*time.Second),
+		},
+		{
+			// 60 ports, but only 49 fit into one minute.
+			name:    "vertical scan just wider than the window",
+			packets: portScan(t, 60, 0, 1250*time.Millisecond),
+		},
+		{
+			// A slow start, then 50 ports in a burst. The busiest minute
+			// ends with the burst and still holds the last slow probe.
+			name: "vertical scan in the densest minute",
+			packets: append(portScan(t, 20, 0, 10*time.Second),
+				every(verticalScanPorts, 200*time.Second, time.Second, func(i int) gopacket.Packet {
+					return syn(t, "10.0.0.1", "10.0.0.2", 40000, 1000+i)
+				})...),
+			want:      []string{"port_scan_vertical"},
+			evidence:  map[string]any{"port_count": verticalScanPorts + 1},
+			firstSeen: 190 * time.Second,
+		},
+		{
+			name: "answered probes are not a scan",
+			packets: append(portScan(t, verticalScanPorts, 0, 100*time.Millisecond),
+				every(verticalScanPorts, time.Second, 0, func(i int) gopacket.Packet {
+					return synAck(t, "10.0.0.2", "10.0.0.1", 1+i, 40000)
+				})...),
+		},
+		{
+			name: "refused probes are a scan",
+			packets: append(portScan(t, verticalScanPorts, 0, 100*time.Millisecond),
+				every(verticalScanPorts, time.Second, 0, func(i int) gopacket.Packet {
+					return rst(t, "10.0.0.2", "10.0.0.1", 1+i, 40000)
+				})...),
+			want: []string{"port_scan_vertical"},
+		},
+		{
+			name: "horizontal scan below threshold",
+			packets: every(horizontalScanHosts-1, 0, time.Second, func(i int) gopacket.Packet {
+				return syn(t, "10.0.0.1", fmt.Sprintf("10.0.1.%d", 1+i), 40000, 22)
+			}),
+		},
+		{
+			name: "horizontal scan at threshold",
+			packets: every(horizontalScanHosts, 0, time.Second, func(i int) gopacket.Packet {
+				return syn(t, "10.0.0.1", fmt.Sprintf("10.0.1.%d", 1+i), 40000, 22)
+			}),
+			want:     []string{"port_scan_horizontal"},
+			evidence: map[string]any{"src_ip": "10.0.0.1", "port": "tcp/22", "host_count": horizontalScanHosts},
+		},
+		{
+			// 40 hosts over 2 minutes, at most 21 in any minute.
+			name: "horizontal scan in the densest minute",
+			packets: every(2*horizontalScanHosts, 0, 3*time.Second, func(i int) gopacket.Packet {
+				return syn(t, "10.0.0.1", fmt.Sprintf("10.0.1.%d", 1+i), 40000, 22)
+			}),
+			want:     []string{"port_scan_horizontal"},
+			evidence: map[string]any{"host_count": 21},
+		},
+		{
+			name: "syn flood below threshold",
+			packets: every(synFloodRate-1, 0, time.Millisecond, func(i int) gopacket.Packet {
+				return syn(t, "10.0.0.1", "10.0.0.2", 10000+i, 80)
+			}),
+		},
+		{
+			name: "syn flood at threshold",
+			packets: every(synFloodRate, 0, time.Millisecond, func(i int) gopacket.Packet {
+				return syn(t, "10.0.0.1", "10.0.0.2", 10000+i, 80)
+			}),
+			want:     []string{"syn_flood"},
+			evidence: map[string]any{"dst_ip": "10.0.0.2", "dst_port": uint16(80), "peak_syns_per_second": synFloodRate},
+		},
+		{
+			// The same SYNs, spread over two seconds.
+			name: "syn flood split over two seconds",
+			packets: every(synFloodRate, 500*time.Millisecond, 10*time.Millisecond, func(i int) gopacket.Packet {
+				return syn(t, "10.0.0.1", "10.0.0.2", 10000+i, 80)
+			}),
+		},
+		{
+			name: "icmp sweep below threshold",
+			packets: every(icmpSweepHosts-1, 0, time.Second, func(i int) gopacket.Packet {
+				return ping(t, "10.0.0.1", fmt.Sprintf("10.0.1.%d", 1+i))
+			}),
+		},
+		{
+			name: "icmp sweep at threshold",
+			packets: every(icmpSweepHosts, 0, time.Second, func(i int) gopacket.Packet {
+				return ping(t, "10.0.0.1", fmt.Sprintf("10.0.1.%d", 1+i))
+			}),
+			want:     []string{"icmp_sweep"},
+			evidence: map[string]any{"src_ip": "10.0.0.1", "host_count": icmpSweepHosts},
+		},
+		{
+			name: "repeated pings to one host",
+			packets: every(2*icmpSweepHosts, 0, time.Second, func(int) gopacket.Packet {
+				return ping(t, "10.0.0.1", "10.0.1.1")
+			}),
+		},
+		{
+			name: "arp from one mac",
+			packets: every(3, 0, time.Second, func(int) gopacket.Packet {
+				return arpReply(t, "10.0.0.1", "02:00:00:00:00:01")
+			}),
+		},
+		{
+			name: "arp from two macs",
+			packets: []timedPacket{
+				{0, arpReply(t, "10.0.0.1", "02:00:00:00:00:01")},
+				{time.Second, arpReply(t, "10.0.0.1", "02:00:00:00:00:66")},
+			},
+			want:     []string{"arp_spoofing"},
+			evidence: map[string]any{"ip": "10.0.0.1", "macs": []string{"02:00:00:00:00:01", "02:00:00:00:00:66"}},
+		},
+		{
+			// Probes for duplicate addresses come from 0.0.0.0.
+			name: "arp probes",
+			packets: []timedPacket{
+				{0, arpReply(t, "0.0.0.0", "02:00:00:00:00:01")},
+				{time.Second, arpReply(t, "0.0.0.0", "02:00:00:00:00:66")},
+			},
+		},
+		{
+			name: "dns tunnelling below threshold",
+			packets: every(dnsTunnelSubdomains-1, 0, time.Second, func(i int) gopacket.Packet {
+				return dnsQuery(t, randomLabel(i, 40)+".example.com")
+			}),
+		},
+		{
+			name: "dns tunnelling at threshold",
+			packets: every(dnsTunnelSubdomains, 0, time.Second, func(i int) gopacket.Packet {
+				return dnsQuery(t, randomLabel(i, 40)+".example.com")
+			}),
+			want:     []string{"dns_tunnelling"},
+			evidence: map[string]any{"domain": "example.com", "unique_subdomains": dnsTunnelSubdomains, "long_names": dnsTunnelSubdomains},
+		},
+		{
+			// Short names with little entropy, like a CDN's hosts.
+			name: "dns many plain subdomains",
+			packets: every(dnsTunnelSubdomains, 0, time.Second, func(i int) gopacket.Packet {
+				return dnsQuery(t, fmt.Sprintf("img%d.example.com", i))
+			}),
+		},
+		{
+			name: "dns grouped below the public suffix",
+			packets: every(dnsTunnelSubdomains, 0, time.Second, func(i int) gopacket.Packet {
+				return dnsQuery(t, randomLabel(i, 40)+".tunnel.co.uk")
+			}),
+			want:     []string{"dns_tunnelling"},
+			evidence: map[string]any{"domain": "tunnel.co.uk", "unique_subdomains": dnsTunnelSubdomains},
+		},
+		{
+			// Every name is a registrable domain of its own.
+			name: "dns distinct domains under a public suffix",
+			packets: every(dnsTunnelSubdomains, 0, time.Second, func(i int) gopacket.Packet {
+				return dnsQuery(t, randomLabel(i, 40)+".co.uk")
+			}),
+		},
+		{
+			name: "dns distinct sites under a private suffix",
+			packets: every(dnsTunnelSubdomains, 0, time.Second, func(i int) gopacket.Packet {
+				return dnsQuery(t, "www."+randomLabel(i, 40)+".github.io")
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := detectFindings(t, tt.packets)
+			var rules []string
+			for _, f := range findings {
+				rules = append(rules, f.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.want) {
+				t.Fatalf("rules = %v, want %v", rules, tt.want)
+			}
+			if len(findings) == 0 {
+				return
+			}
+			for key, want := range tt.evidence {
+				if got := findings[0].Evidence[key]; !reflect.DeepEqual(got, want) {
+					t.Errorf("evidence %s = %#v, want %#v", key, got, want)
+				}
+			}
+			if want := findingsStart.Add(tt.firstSeen); tt.firstSeen != 0 && !findings[0].FirstSeen.Equal(want) {
+				t.Errorf("FirstSeen = %s, want %s", findings[0].FirstSeen, want)
+			}
+		})
+	}
+}
+
+func TestBaseDomain(t *testing.T) {
+	tests := []struct {
+		name, base, sub string
+	}{
+		{"example.com", "example.com", ""},
+		{"a.b.example.com", "example.com", "a.b"},
+		{"www.example.co.uk", "example.co.uk", "www"},
+		{"co.uk", "co.uk", ""},
+		{"user.github.io", "user.github.io", ""},
+		{"www.user.github.io", "user.github.io", "www"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, sub := baseDomain(tt.name)
+			if base != tt.base || sub != tt.sub {
+				t.Errorf("baseDomain(%q) = %q, %q, want %q, %q", tt.name, base, sub, tt.base, tt.sub)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package capture
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Flow is a bidirectional conversation keyed by its 5-tuple. Src is the side
+// that sent the first packet seen in the capture.
+type Flow struct {
+	Protocol string `json:"protocol"`
+	SrcIP    string `json:"src_ip"`
+	SrcPort  uint16 `json:"src_port"`
+	DstIP    string `json:"dst_ip"`
+	DstPort  uint16 `json:"dst_port"`
+
+	PacketsSrcToDst int64 `json:"packets_src_to_dst"`
+	BytesSrcToDst   int64 `json:"bytes_src_to_dst"`
+	PacketsDstToSrc int64 `json:"packets_dst_to_src"`
+	BytesDstToSrc   int64 `json:"bytes_dst_to_src"`
+
+	TCPFlags  string    `json:"tcp_flags,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// HandshakeRTT is the time from the SYN to the ACK completing the
+	// three-way handshake; zero if no complete handshake was captured.
+	HandshakeRTT time.Duration `json:"handshake_rtt"`
+
+	flags    uint8
+	synAt    time.Time
+	synAckAt time.Time
+}
+
+type flowKey struct {
+	protocol string
+	srcIP    string
+	srcPort  uint16
+	dstIP    string
+	dstPort  uint16
+}
+
+func (k flowKey) reverse() flowKey {
+	return flowKey{
+		protocol: k.protocol,
+		srcIP:    k.dstIP,
+		srcPort:  k.dstPort,
+		dstIP:    k.srcIP,
+		dstPort:  k.srcPort,
+	}
+}
+
+type flowTable struct {
+	flows map[flowKey]*Flow
+	order []*Flow
+	// result is what Finalize reported, for Persist.
+	result []Flow
+}
+
+func newFlowTable() *flowTable {
+	return &flowTable{flows: make(map[flowKey]*Flow)}
+}
+
+const (
+	tcpFlagFIN uint8 = 1 << iota
+	tcpFlagSYN
+	tcpFlagRST
+	tcpFlagPSH
+	tcpFlagACK
+	tcpFlagURG
+	tcpFlagECE
+	tcpFlagCWR
+)
+
+var tcpFlagNames = []struct {
+	flag uint8
+	name string
+}{
+	{tcpFlagSYN, "SYN"},
+	{tcpFlagACK, "ACK"},
+	{tcpFlagPSH, "PSH"},
+	{tcpFlagURG, "URG"},
+	{tcpFlagFIN, "FIN"},
+	{tcpFlagRST, "RST"},
+	{tcpFlagECE, "ECE"},
+	{tcpFlagCWR, "CWR"},
+}
+
+func tcpFlags(tcp *layers.TCP) uint8 {
+	var flags uint8
+	if tcp.FIN {
+		flags |= tcpFlagFIN
+	}
+	if tcp.SYN {
+		flags |= tcpFlagSYN
+	}
+	if tcp.RST {
+		flags |= tcpFlagRST
+	}
+	if tcp.PSH {
+		flags |= tcpFlagPSH
+	}
+	if tcp.ACK {
+		flags |= tcpFlagACK
+	}
+	if tcp.URG {
+		flags |= tcpFlagURG
+	}
+	if tcp.ECE {
+		flags |= tcpFlagECE
+	}
+	if tcp.CWR {
+		flags |= tcpFlagCWR
+	}
+	return flags
+}
+
+func formatTCPFlags(flags uint8) string {
+	names := make([]string, 0, len(tcpFlagNames))
+	for _, f := range tcpFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// packetFlowKey extracts the 5-tuple of an IP packet. Protocols without ports
+// (ICMP and friends) are keyed on addresses alone.
+func packetFlowKey(packet gopacket.Packet) (flowKey, bool) {
+	var key flowKey
+	var ipProto layers.IPProtocol
+
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		key.srcIP = ip.SrcIP.String()
+		key.dstIP = ip.DstIP.String()
+		ipProto = ip.Protocol
+	case *layers.IPv6:
+		key.srcIP = ip.SrcIP.String()
+		key.dstIP = ip.DstIP.String()
+		ipProto = ip.NextHeader
+	default:
+		return key, false
+	}
+
+	switch transport := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		key.protocol = "TCP"
+		key.srcPort = uint16(transport.SrcPort)
+		key.dstPort = uint16(transport.DstPort)
+	case *layers.UDP:
+		key.protocol = "UDP"
+		key.srcPort = uint16(transport.SrcPort)
+		key.dstPort = uint16(transport.DstPort)
+	default:
+		switch ipProto {
+		case layers.IPProtocolICMPv4:
+			key.protocol = "ICMP"
+		case layers.IPProtocolICMPv6:
+			key.protocol = "ICMPv6"
+		default:
+			key.protocol = ipProto.String()
+		}
+	}
+
+	return key, true
+}
+
+func (t *flowTable) HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo) {
+	key, ok := packetFlowKey(packet)
+	if !ok {
+		return
+	}
+
+	forward := true
+	flow, found := t.flows[key]
+	if !found {
+		if flow, found = t.flows[key.reverse()]; found {
+			forward = false
+		}
+	}
+	if !found {
+		flow = &Flow{
+			Protocol:  key.protocol,
+			SrcIP:     key.srcIP,
+			SrcPort:   key.srcPort,
+			DstIP:     key.dstIP,
+			DstPort:   key.dstPort,
+			FirstSeen: ci.Timestamp,
+		}
+		t.flows[key] = flow
+		t.order = append(t.order, flow)
+	}
+
+	if forward {
+		flow.PacketsSrcToDst++
+		flow.BytesSrcToDst += int64(ci.Length)
+	} else {
+		flow.PacketsDstToSrc++
+		flow.BytesDstToSrc += int64(ci.Length)
+	}
+	if ci.Timestamp.After(flow.LastSeen) {
+		flow.LastSeen = ci.Timestamp
+	}
+	if ci.Timestamp.Before(flow.FirstSeen) {
+		flow.FirstSeen = ci.Timestamp
+	}
+
+	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
+		tcp := tcpLayer.(*layers.TCP)
+		flow.flags |= tcpFlags(tcp)
+		trackHandshake(flow, tcp, forward, ci.Timestamp)
+	}
+}
+
+func trackHandshake(flow *Flow, tcp *layers.TCP, forward bool, ts time.Time) {
+	switch {
+	case tcp.SYN && !tcp.ACK && forward && flow.synAt.IsZero():
+		flow.synAt = ts
+	case tcp.SYN && tcp.ACK && !forward && !flow.synAt.IsZero() && flow.synAckAt.IsZero():
+		flow.synAckAt = ts
+	case tcp.ACK && !tcp.SYN && !tcp.RST && forward && !flow.synAckAt.IsZero() && flow.HandshakeRTT == 0:
+		flow.HandshakeRTT = max(ts.Sub(flow.synAt), time.Nanosecond)
+	}
+}
+
+func (t *flowTable) Finalize(stats *CaptureStats) {
+	t.result = t.list()
+	stats.Flows = t.result
+}
+
+func (t *flowTable) list() []Flow {
+	flows := make([]Flow, 0, len(t.order))
+	for _, flow := range t.order {
+		f := *flow
+		f.TCPFlags = formatTCPFlags(f.flags)
+		flows = append(flows, f)
+	}
+
+	sort.SliceStable(flows, func(i, j int) bool {
+		return flows[i].FirstSeen.Before(flows[j].FirstSeen)
+	})
+
+	return flows
+}
@@ -0,0 +1,32 @@
+package capture
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ContentHash returns the hex SHA-256 of a capture file's content. Gzipped
+// files are hashed decompressed, so compressing a stored capture keeps its
+// hash and a damaged gzip stream is reported as a *CorruptError.
+func ContentHash(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open capture file: %w", err)
+	}
+	defer file.Close()
+
+	br, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, br); err != nil {
+		return "", corruptAt(0, fmt.Errorf("failed to read capture file: %w", err))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
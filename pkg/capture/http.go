@@ -0,0 +1,342 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+)
+
+// HTTPTransaction is one HTTP/1.x request paired with its response. Response
+// fields are empty when the response was not captured.
+type HTTPTransaction struct {
+	ClientIP   string    `json:"client_ip"`
+	ClientPort uint16    `json:"client_port"`
+	ServerIP   string    `json:"server_ip"`
+	ServerPort uint16    `json:"server_port"`
+	Timestamp  time.Time `json:"timestamp"`
+
+	Method    string `json:"method,omitempty"`
+	Host      string `json:"host,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+
+	ResponseTime time.Time `json:"response_time,omitzero"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+}
+
+const (
+	// Messages whose headers (or unsized bodies) grow past this are dropped
+	// and the stream is no longer parsed.
+	maxHTTPBuffer = 1 << 20
+	// Limits how much out-of-order data the assembler keeps per connection.
+	maxHTTPBufferedPages = 64
+)
+
+var httpMethods = []string{"GET ", "POST ", "PUT ", "DELETE ", "HEAD ", "OPTIONS ", "PATCH ", "CONNECT ", "TRACE "}
+
+// httpTable feeds TCP packets through tcpassembly and collects the HTTP
+// transactions found in the reassembled streams.
+type httpTable struct {
+	assembler    *tcpassembly.Assembler
+	pending      map[string][]*HTTPTransaction
+	transactions []*HTTPTransaction
+	// result is what Finalize reported, for Persist.
+	result []HTTPTransaction
+}
+
+func newHTTPTable() *httpTable {
+	t := &httpTable{pending: make(map[string][]*HTTPTransaction)}
+	t.assembler = tcpassembly.NewAssembler(tcpassembly.NewStreamPool(&httpStreamFactory{table: t}))
+	t.assembler.MaxBufferedPagesPerConnection = maxHTTPBufferedPages
+	return t
+}
+
+func (t *httpTable) HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo) {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil || packet.NetworkLayer() == nil {
+		return
+	}
+	t.assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcpLayer.(*layers.TCP), ci.Timestamp)
+}
+
+func (t *httpTable) Finalize(stats *CaptureStats) {
+	t.result = t.list()
+	stats.HTTP = t.result
+}
+
+func (t *httpTable) list() []HTTPTransaction {
+	t.assembler.FlushAll()
+
+	transactions := make([]HTTPTransaction, 0, len(t.transactions))
+	for _, tx := range t.transactions {
+		transactions = append(transactions, *tx)
+	}
+	return transactions
+}
+
+func connKey(netFlow, tcpFlow gopacket.Flow) string {
+	return netFlow.String() + "|" + tcpFlow.String()
+}
+
+type httpStreamFactory struct {
+	table *httpTable
+}
+
+func (f *httpStreamFactory) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	return &httpStream{table: f.table, netFlow: netFlow, tcpFlow: tcpFlow}
+}
+
+// httpStream parses one direction of a TCP connection. Whether it carries
+// requests or responses is decided by the first bytes seen.
+type httpStream struct {
+	table   *httpTable
+	netFlow gopacket.Flow
+	tcpFlow gopacket.Flow
+
+	decided    bool
+	isRequest  bool
+	done       bool
+	buf        []byte
+	skipBody   int64
+	lastSeen   time.Time
+	bufferedAt time.Time
+}
+
+func (s *httpStream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+	for _, r := range reassemblies {
+		if s.done {
+			return
+		}
+		// A gap after the stream has been identified means we lost sync.
+		if r.Skip > 0 && s.decided {
+			s.done = true
+			s.buf = nil
+			return
+		}
+		s.feed(r.Bytes, r.Seen)
+	}
+}
+
+func (s *httpStream) ReassemblyComplete() {
+	s.buf = nil
+}
+
+func (s *httpStream) feed(data []byte, seen time.Time) {
+	if s.skipBody > 0 {
+		if int64(len(data)) <= s.skipBody {
+			s.skipBody -= int64(len(data))
+			return
+		}
+		data = data[s.skipBody:]
+		s.skipBody = 0
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	if len(s.buf) == 0 {
+		s.bufferedAt = seen
+	}
+	s.buf = append(s.buf, data...)
+	s.lastSeen = seen
+
+	if !s.decided {
+		if len(s.buf) < 8 {
+			return
+		}
+		switch {
+		case bytes.HasPrefix(s.buf, []byte("HTTP/")):
+			s.isRequest = false
+		case hasHTTPMethodPrefix(s.buf):
+			s.isRequest = true
+		default:
+			s.done = true
+			s.buf = nil
+			return
+		}
+		s.decided = true
+	}
+
+	for len(s.buf) > 0 && !s.done {
+		if !s.parseMessage() {
+			break
+		}
+	}
+
+	if len(s.buf) > maxHTTPBuffer {
+		s.done = true
+		s.buf = nil
+	}
+}
+
+func hasHTTPMethodPrefix(b []byte) bool {
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(b, []byte(method)) {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader tracks how many bytes the HTTP parser consumed so the parsed
+// message can be cut from the front of the buffer.
+type countingReader struct {
+	r *bytes.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// parseMessage parses one message from the front of the buffer. It returns
+// false when more data is needed.
+func (s *httpStream) parseMessage() bool {
+	headerEnd := bytes.Index(s.buf, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		return false
+	}
+	headerLen := headerEnd + 4
+
+	counter := &countingReader{r: bytes.NewReader(s.buf)}
+	reader := bufio.NewReader(counter)
+
+	var req *http.Request
+	var resp *http.Response
+	var contentLength int64
+	var body io.ReadCloser
+	var err error
+	if s.isRequest {
+		req, err = http.ReadRequest(reader)
+		if err == nil {
+			contentLength, body = req.ContentLength, req.Body
+		}
+	} else {
+		resp, err = http.ReadResponse(reader, s.table.peekRequest(s.netFlow, s.tcpFlow))
+		if err == nil {
+			contentLength, body = resp.ContentLength, resp.Body
+		}
+	}
+	if err != nil {
+		s.done = true
+		return false
+	}
+
+	consumed := 0
+	switch {
+	case body == http.NoBody:
+		// HEAD responses, 1xx, 204 and 304 have no body whatever the headers say.
+		consumed = headerLen
+	case contentLength >= 0:
+		// Sized bodies are skipped without buffering them.
+		available := int64(len(s.buf) - headerLen)
+		if contentLength <= available {
+			consumed = headerLen + int(contentLength)
+		} else {
+			s.skipBody = contentLength - available
+			consumed = len(s.buf)
+		}
+	case resp != nil && len(resp.TransferEncoding) == 0:
+		// The body runs until the connection closes, nothing else follows.
+		s.done = true
+		consumed = len(s.buf)
+	default:
+		// Chunked bodies have to be complete in the buffer to find their end.
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+				s.done = true
+			}
+			return false
+		}
+		consumed = counter.n - reader.Buffered()
+	}
+
+	if req != nil {
+		s.table.addRequest(s.netFlow, s.tcpFlow, req, s.bufferedAt)
+	} else {
+		s.table.addResponse(s.netFlow, s.tcpFlow, resp, s.bufferedAt)
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			s.done = true
+		}
+	}
+
+	s.buf = s.buf[consumed:]
+	if len(s.buf) == 0 {
+		s.buf = nil
+	}
+	s.bufferedAt = s.lastSeen
+	return true
+}
+
+func (t *httpTable) addRequest(netFlow, tcpFlow gopacket.Flow, req *http.Request, timestamp time.Time) {
+	srcPort, _ := strconv.ParseUint(tcpFlow.Src().String(), 10, 16)
+	dstPort, _ := strconv.ParseUint(tcpFlow.Dst().String(), 10, 16)
+
+	tx := &HTTPTransaction{
+		ClientIP:   netFlow.Src().String(),
+		ClientPort: uint16(srcPort),
+		ServerIP:   netFlow.Dst().String(),
+		ServerPort: uint16(dstPort),
+		Timestamp:  timestamp,
+		Method:     req.Method,
+		Host:       strings.ToLower(req.Host),
+		URI:        req.RequestURI,
+		UserAgent:  req.UserAgent(),
+	}
+	key := connKey(netFlow, tcpFlow)
+	t.pending[key] = append(t.pending[key], tx)
+	t.transactions = append(t.transactions, tx)
+}
+
+// peekRequest returns the oldest unanswered request on the connection a
+// response stream belongs to, so HEAD responses are parsed without a body.
+func (t *httpTable) peekRequest(netFlow, tcpFlow gopacket.Flow) *http.Request {
+	queue := t.pending[connKey(netFlow.Reverse(), tcpFlow.Reverse())]
+	if len(queue) == 0 {
+		return nil
+	}
+	return &http.Request{Method: queue[0].Method}
+}
+
+func (t *httpTable) addResponse(netFlow, tcpFlow gopacket.Flow, resp *http.Response, timestamp time.Time) {
+	// Interim responses (100 Continue, ...) are followed by the real one.
+	if resp.StatusCode < 200 && resp.StatusCode != http.StatusSwitchingProtocols {
+		return
+	}
+
+	key := connKey(netFlow.Reverse(), tcpFlow.Reverse())
+
+	var tx *HTTPTransaction
+	if queue := t.pending[key]; len(queue) > 0 {
+		tx = queue[0]
+		t.pending[key] = queue[1:]
+	} else {
+		// Response to a request that was not captured.
+		srcPort, _ := strconv.ParseUint(tcpFlow.Src().String(), 10, 16)
+		dstPort, _ := strconv.ParseUint(tcpFlow.Dst().String(), 10, 16)
+		tx = &HTTPTransaction{
+			ClientIP:   netFlow.Dst().String(),
+			ClientPort: uint16(dstPort),
+			ServerIP:   netFlow.Src().String(),
+			ServerPort: uint16(srcPort),
+			Timestamp:  timestamp,
+		}
+		t.transactions = append(t.transactions, tx)
+	}
+
+	tx.ResponseTime = timestamp
+	tx.StatusCode = resp.StatusCode
+	tx.ContentType = resp.Header.Get("Content-Type")
+}
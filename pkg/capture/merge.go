@@ -0,0 +1,201 @@
+package capture
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const (
+	MergeFormatPcap   = "pcap"
+	MergeFormatPcapng = "pcapng"
+)
+
+// ErrMixedLinkTypes is returned when sources with different link types are
+// merged into a classic pcap, which can only hold one.
+var ErrMixedLinkTypes = errors.New("sources have different link types, merge to pcapng instead")
+
+// MergeSource is one capture taking part in a merge. Name becomes the name of
+// its interface in pcapng output.
+type MergeSource struct {
+	Name   string
+	Reader PacketReader
+}
+
+type mergePacket struct {
+	data   []byte
+	ci     gopacket.CaptureInfo
+	source int
+}
+
+// mergeHeap orders the next packet of every source by timestamp, ties going
+// to the source listed first.
+type mergeHeap []mergePacket
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].ci.Timestamp.Equal(h[j].ci.Timestamp) {
+		return h[i].source < h[j].source
+	}
+	return h[i].ci.Timestamp.Before(h[j].ci.Timestamp)
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(mergePacket)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// mergeWriter hides the differences between the pcap and pcapng writers.
+type mergeWriter interface {
+	// write reports false for packets the output format can't hold.
+	write(source int, src PacketReader, ci gopacket.CaptureInfo, data []byte) (bool, error)
+	flush() error
+}
+
+type pcapMergeWriter struct {
+	w        *pcapgo.Writer
+	linkType layers.LinkType
+}
+
+func (m *pcapMergeWriter) write(_ int, src PacketReader, ci gopacket.CaptureInfo, data []byte) (bool, error) {
+	// pcapng sources can have interfaces of another link type.
+	if packetLinkType(src, ci) != m.linkType {
+		return false, nil
+	}
+	return true, m.w.WritePacket(ci, data)
+}
+
+func (m *pcapMergeWriter) flush() error { return nil }
+
+type interfaceKey struct {
+	source int
+	index  int
+}
+
+// ngMergeWriter gives every source interface its own interface block.
+type ngMergeWriter struct {
+	w          *pcapgo.NgWriter
+	sources    []MergeSource
+	interfaces map[interfaceKey]int
+}
+
+func (m *ngMergeWriter) write(source int, src PacketReader, ci gopacket.CaptureInfo, data []byte) (bool, error) {
+	key := interfaceKey{source: source, index: ci.InterfaceIndex}
+	id, ok := m.interfaces[key]
+	if !ok {
+		intf := pcapgo.NgInterface{
+			Name:     fmt.Sprintf("%s#%d", m.sources[source].Name, ci.InterfaceIndex),
+			LinkType: packetLinkType(src, ci),
+		}
+		var err error
+		if id, err = m.w.AddInterface(intf); err != nil {
+			return false, fmt.Errorf("failed to add interface: %w", err)
+		}
+		m.interfaces[key] = id
+	}
+	ci.InterfaceIndex = id
+	return true, m.w.WritePacket(ci, data)
+}
+
+func (m *ngMergeWriter) flush() error { return m.w.Flush() }
+
+func newMergeWriter(sources []MergeSource, w io.Writer, format string) (mergeWriter, error) {
+	switch format {
+	case MergeFormatPcap:
+		linkType := sources[0].Reader.LinkType()
+		for _, source := range sources[1:] {
+			if source.Reader.LinkType() != linkType {
+				return nil, ErrMixedLinkTypes
+			}
+		}
+		writer := pcapgo.NewWriterNanos(w)
+		if err := writer.WriteFileHeader(extractSnaplen, linkType); err != nil {
+			return nil, fmt.Errorf("failed to write pcap header: %w", err)
+		}
+		return &pcapMergeWriter{w: writer, linkType: linkType}, nil
+
+	case MergeFormatPcapng:
+		m := &ngMergeWriter{sources: sources, interfaces: make(map[interfaceKey]int)}
+		for i, source := range sources {
+			intf := pcapgo.NgInterface{
+				Name:     source.Name,
+				LinkType: source.Reader.LinkType(),
+			}
+			var err error
+			if i == 0 {
+				m.w, err = pcapgo.NewNgWriterInterface(w, intf, pcapgo.DefaultNgWriterOptions)
+			} else {
+				_, err = m.w.AddInterface(intf)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to write pcapng header: %w", err)
+			}
+			m.interfaces[interfaceKey{source: i}] = i
+		}
+		return m, nil
+
+	default:
+		return nil, fmt.Errorf("unknown merge format %q", format)
+	}
+}
+
+// Merge interleaves the packets of all sources by timestamp into w, like
+// mergecap does, and returns the number of packets written. Each source is
+// expected to be in time order itself.
+func Merge(sources []MergeSource, w io.Writer, format string) (int, error) {
+	if len(sources) == 0 {
+		return 0, fmt.Errorf("nothing to merge")
+	}
+
+	writer, err := newMergeWriter(sources, w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	h := make(mergeHeap, 0, len(sources))
+	readNext := func(source int) error {
+		data, ci, err := sources[source].Reader.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read from %s: %w", sources[source].Name, err)
+		}
+		heap.Push(&h, mergePacket{data: data, ci: ci, source: source})
+		return nil
+	}
+
+	for i := range sources {
+		if err := readNext(i); err != nil {
+			return 0, err
+		}
+	}
+
+	written := 0
+	for h.Len() > 0 {
+		next := heap.Pop(&h).(mergePacket)
+		ok, err := writer.write(next.source, sources[next.source].Reader, next.ci, next.data)
+		if err != nil {
+			return written, fmt.Errorf("failed to write packet: %w", err)
+		}
+		if ok {
+			written++
+		}
+		if err := readNext(next.source); err != nil {
+			return written, err
+		}
+	}
+
+	if err := writer.flush(); err != nil {
+		return written, fmt.Errorf("failed to flush merge output: %w", err)
+	}
+	return written, nil
+}
@@ -0,0 +1,241 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// mergeTestPacket is a packet of a merge source. Its data is just marker, so
+// it can be told apart in the output.
+type mergeTestPacket struct {
+	second int
+	iface  int
+	marker byte
+}
+
+var mergeBase = time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+
+func mergeCI(p mergeTestPacket) gopacket.CaptureInfo {
+	return gopacket.CaptureInfo{
+		Timestamp:      mergeBase.Add(time.Duration(p.second) * time.Second),
+		CaptureLength:  1,
+		Length:         1,
+		InterfaceIndex: p.iface,
+	}
+}
+
+func mergePcapSource(t *testing.T, linkType layers.LinkType, packets ...mergeTestPacket) PacketReader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	if err := w.WriteFileHeader(65535, linkType); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		if err := w.WritePacket(mergeCI(p), []byte{p.marker}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+// mergePcapngSource writes a pcapng with one interface per entry of linkTypes.
+func mergePcapngSource(t *testing.T, linkTypes []layers.LinkType, packets ...mergeTestPacket) PacketReader {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := pcapgo.NewNgWriterInterface(&buf, pcapgo.NgInterface{LinkType: linkTypes[0]}, pcapgo.DefaultNgWriterOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, linkType := range linkTypes[1:] {
+		if _, err := w.AddInterface(pcapgo.NgInterface{LinkType: linkType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range packets {
+		if err := w.WritePacket(mergeCI(p), []byte{p.marker}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+type mergedPacket struct {
+	marker   byte
+	iface    string
+	linkType layers.LinkType
+}
+
+// readMerged reads back a merge output with the interface of every packet.
+// Unlike NewReader it keeps pcapng interfaces of other link types.
+func readMerged(t *testing.T, data []byte) []mergedPacket {
+	t.Helper()
+	var reader PacketReader
+	var err error
+	if bytes.HasPrefix(data, []byte{0x0a, 0x0d, 0x0d, 0x0a}) {
+		reader, err = pcapgo.NewNgReader(bytes.NewReader(data), pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	} else {
+		reader, err = NewReader(bytes.NewReader(data))
+	}
+	if err != nil {
+		t.Fatalf("failed to open merge output: %v", err)
+	}
+	var packets []mergedPacket
+	for {
+		data, ci, err := reader.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			return packets
+		}
+		if err != nil {
+			t.Fatalf("failed to read merge output: %v", err)
+		}
+		p := mergedPacket{marker: data[0], linkType: reader.LinkType()}
+		if ng, ok := reader.(*pcapgo.NgReader); ok {
+			intf, err := ng.Interface(ci.InterfaceIndex)
+			if err != nil {
+				t.Fatalf("packet %#x has unknown interface %d", p.marker, ci.InterfaceIndex)
+			}
+			p.iface, p.linkType = intf.Name, intf.LinkType
+		}
+		packets = append(packets, p)
+	}
+}
+
+func TestMergeOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources [][]mergeTestPacket
+		want    []byte
+	}{
+		{
+			name: "interleaved",
+			sources: [][]mergeTestPacket{
+				{{second: 1, marker: 0xa1}, {second: 4, marker: 0xa4}, {second: 5, marker: 0xa5}},
+				{{second: 2, marker: 0xb2}, {second: 3, marker: 0xb3}, {second: 6, marker: 0xb6}},
+			},
+			want: []byte{0xa1, 0xb2, 0xb3, 0xa4, 0xa5, 0xb6},
+		},
+		{
+			name: "ties go to the source listed first",
+			sources: [][]mergeTestPacket{
+				{{second: 2, marker: 0xa2}},
+				{{second: 1, marker: 0xb1}, {second: 2, marker: 0xb2}},
+				{{second: 2, marker: 0xc2}, {second: 2, marker: 0xc3}},
+			},
+			want: []byte{0xb1, 0xa2, 0xb2, 0xc2, 0xc3},
+		},
+		{
+			name: "one source after the other",
+			sources: [][]mergeTestPacket{
+				{{second: 5, marker: 0xa5}, {second: 6, marker: 0xa6}},
+				{{second: 1, marker: 0xb1}, {second: 2, marker: 0xb2}},
+			},
+			want: []byte{0xb1, 0xb2, 0xa5, 0xa6},
+		},
+		{
+			name: "empty source",
+			sources: [][]mergeTestPacket{
+				{},
+				{{second: 1, marker: 0xb1}},
+			},
+			want: []byte{0xb1},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, format := range []string{MergeFormatPcap, MergeFormatPcapng} {
+			t.Run(tt.name+" "+format, func(t *testing.T) {
+				var sources []MergeSource
+				for i, packets := range tt.sources {
+					sources = append(sources, MergeSource{
+						Name:   string(rune('a' + i)),
+						Reader: mergePcapSource(t, layers.LinkTypeEthernet, packets...),
+					})
+				}
+				var out bytes.Buffer
+				written, err := Merge(sources, &out, format)
+				if err != nil {
+					t.Fatalf("Merge failed: %v", err)
+				}
+				if written != len(tt.want) {
+					t.Errorf("Merge wrote %d packets, want %d", written, len(tt.want))
+				}
+				var got []byte
+				for _, p := range readMerged(t, out.Bytes()) {
+					got = append(got, p.marker)
+				}
+				if !bytes.Equal(got, tt.want) {
+					t.Errorf("order = %x, want %x", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMergePcapngInterfaces(t *testing.T) {
+	sources := []MergeSource{
+		{Name: "a", Reader: mergePcapSource(t, layers.LinkTypeEthernet,
+			mergeTestPacket{second: 1, marker: 0xa1},
+			mergeTestPacket{second: 4, marker: 0xa4})},
+		// b captured on two interfaces.
+		{Name: "b", Reader: mergePcapngSource(t, []layers.LinkType{layers.LinkTypeEthernet, layers.LinkTypeEthernet},
+			mergeTestPacket{second: 2, iface: 1, marker: 0xb2},
+			mergeTestPacket{second: 3, iface: 0, marker: 0xb3},
+			mergeTestPacket{second: 5, iface: 1, marker: 0xb5})},
+		{Name: "c", Reader: mergePcapSource(t, layers.LinkTypeLinuxSLL,
+			mergeTestPacket{second: 6, marker: 0xc6})},
+	}
+
+	var out bytes.Buffer
+	written, err := Merge(sources, &out, MergeFormatPcapng)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if written != 6 {
+		t.Errorf("Merge wrote %d packets, want 6", written)
+	}
+
+	// Every source keeps its first interface under its own name, later
+	// interfaces of a source are added with their index.
+	want := []mergedPacket{
+		{0xa1, "a", layers.LinkTypeEthernet},
+		{0xb2, "b#1", layers.LinkTypeEthernet},
+		{0xb3, "b", layers.LinkTypeEthernet},
+		{0xa4, "a", layers.LinkTypeEthernet},
+		{0xb5, "b#1", layers.LinkTypeEthernet},
+		{0xc6, "c", layers.LinkTypeLinuxSLL},
+	}
+	if got := readMerged(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged packets = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergePcapMixedLinkTypes(t *testing.T) {
+	sources := []MergeSource{
+		{Name: "a", Reader: mergePcapSource(t, layers.LinkTypeEthernet, mergeTestPacket{second: 1, marker: 0xa1})},
+		{Name: "b", Reader: mergePcapSource(t, layers.LinkTypeRaw, mergeTestPacket{second: 2, marker: 0xb2})},
+	}
+	_, err := Merge(sources, io.Discard, MergeFormatPcap)
+	if !errors.Is(err, ErrMixedLinkTypes) {
+		t.Errorf("error = %v, want ErrMixedLinkTypes", err)
+	}
+}
@@ -0,0 +1,170 @@
+package capture
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+// The built-in analyzers store their results through Persister like any
+// other. Their tables are cleared by the store on reanalysis, so that an
+// analyzer disabled since leaves nothing behind, and they only insert.
+
+func (t *flowTable) Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error {
+	q := sqlc.New(tx)
+	for _, flow := range t.result {
+		if err := q.InsertCaptureFlow(ctx, sqlc.InsertCaptureFlowParams{
+			CaptureID:       captureID,
+			Protocol:        flow.Protocol,
+			SrcIp:           flow.SrcIP,
+			SrcPort:         int64(flow.SrcPort),
+			DstIp:           flow.DstIP,
+			DstPort:         int64(flow.DstPort),
+			PacketsSrcToDst: flow.PacketsSrcToDst,
+			BytesSrcToDst:   flow.BytesSrcToDst,
+			PacketsDstToSrc: flow.PacketsDstToSrc,
+			BytesDstToSrc:   flow.BytesDstToSrc,
+			TcpFlags:        nullString(flow.TCPFlags),
+			HandshakeRttMs:  sql.NullFloat64{Float64: float64(flow.HandshakeRTT) / float64(time.Millisecond), Valid: flow.HandshakeRTT > 0},
+			FirstSeen:       flow.FirstSeen,
+			LastSeen:        flow.LastSeen,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *dnsTable) Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error {
+	q := sqlc.New(tx)
+	for _, query := range t.result {
+		var answers sql.NullString
+		if query.Answered {
+			answersJSON, err := json.Marshal(query.Answers)
+			if err != nil {
+				return fmt.Errorf("failed to marshal DNS answers: %w", err)
+			}
+			answers = sql.NullString{String: string(answersJSON), Valid: true}
+		}
+
+		if err := q.InsertCaptureDNS(ctx, sqlc.InsertCaptureDNSParams{
+			CaptureID:     captureID,
+			QueryTime:     query.QueryTime,
+			ResponseTime:  sql.NullTime{Time: query.ResponseTime, Valid: query.Answered},
+			ClientIp:      query.ClientIP,
+			ServerIp:      query.ServerIP,
+			TransactionID: int64(query.TransactionID),
+			Name:          query.Name,
+			RecordType:    query.RecordType,
+			ResponseCode:  sql.NullString{String: query.ResponseCode, Valid: query.Answered},
+			Answers:       answers,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tlsTable) Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error {
+	q := sqlc.New(tx)
+	for _, session := range t.result {
+		var cipherSuites sql.NullString
+		if len(session.CipherSuites) > 0 {
+			suitesJSON, err := json.Marshal(session.CipherSuites)
+			if err != nil {
+				return fmt.Errorf("failed to marshal TLS cipher suites: %w", err)
+			}
+			cipherSuites = sql.NullString{String: string(suitesJSON), Valid: true}
+		}
+
+		if err := q.InsertCaptureTLS(ctx, sqlc.InsertCaptureTLSParams{
+			CaptureID:     captureID,
+			ClientIp:      session.ClientIP,
+			ClientPort:    int64(session.ClientPort),
+			ServerIp:      session.ServerIP,
+			ServerPort:    int64(session.ServerPort),
+			SeenAt:        session.Timestamp,
+			Sni:           nullString(session.SNI),
+			ClientVersion: nullString(session.ClientVersion),
+			CipherSuites:  cipherSuites,
+			Ja3:           nullString(session.JA3),
+			Ja3Hash:       nullString(session.JA3Hash),
+			Version:       nullString(session.Version),
+			CipherSuite:   nullString(session.CipherSuite),
+			Ja3s:          nullString(session.JA3S),
+			Ja3sHash:      nullString(session.JA3SHash),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *httpTable) Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error {
+	q := sqlc.New(tx)
+	for _, transaction := range t.result {
+		if err := q.InsertCaptureHTTP(ctx, sqlc.InsertCaptureHTTPParams{
+			CaptureID:    captureID,
+			ClientIp:     transaction.ClientIP,
+			ClientPort:   int64(transaction.ClientPort),
+			ServerIp:     transaction.ServerIP,
+			ServerPort:   int64(transaction.ServerPort),
+			RequestTime:  transaction.Timestamp,
+			Method:       nullString(transaction.Method),
+			Host:         nullString(transaction.Host),
+			Uri:          nullString(transaction.URI),
+			UserAgent:    nullString(transaction.UserAgent),
+			ResponseTime: sql.NullTime{Time: transaction.ResponseTime, Valid: !transaction.ResponseTime.IsZero()},
+			StatusCode:   sql.NullInt64{Int64: int64(transaction.StatusCode), Valid: transaction.StatusCode != 0},
+			ContentType:  nullString(transaction.ContentType),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *timelineTable) Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error {
+	q := sqlc.New(tx)
+	for _, bucket := range t.result {
+		if err := q.InsertCaptureTimelineBucket(ctx, sqlc.InsertCaptureTimelineBucketParams{
+			CaptureID:   captureID,
+			BucketStart: bucket.Start,
+			Protocol:    bucket.Protocol,
+			Packets:     bucket.Packets,
+			Bytes:       bucket.Bytes,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *findingDetector) Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error {
+	q := sqlc.New(tx)
+	for _, finding := range d.result {
+		evidence, err := json.Marshal(finding.Evidence)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s evidence: %w", finding.Rule, err)
+		}
+		if err := q.InsertCaptureFinding(ctx, sqlc.InsertCaptureFindingParams{
+			CaptureID: captureID,
+			Rule:      finding.Rule,
+			Severity:  finding.Severity,
+			Summary:   finding.Summary,
+			Evidence:  string(evidence),
+			FirstSeen: sql.NullTime{Time: finding.FirstSeen, Valid: !finding.FirstSeen.IsZero()},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,205 @@
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const (
+	pcapngMagic = 0x0A0D0D0A
+	gzipMagic1  = 0x1f
+	gzipMagic2  = 0x8b
+)
+
+// PacketReader is implemented by both the classic pcap and the pcapng reader.
+type PacketReader interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// CorruptError is returned for a capture that can't be read to the end: a bad
+// header or magic, a truncated record or a record that breaks the format, like
+// a captured length beyond the snaplen. Packet is the packet reading stopped
+// at, counting from 1, or 0 for a bad header or when it isn't known.
+type CorruptError struct {
+	Packet int
+	Err    error
+}
+
+func (e *CorruptError) Error() string {
+	if e.Packet == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("failed to read packet %d: %v", e.Packet, e.Err)
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// corruptAt wraps a read error at the given packet in a *CorruptError, unless
+// it comes from reading the file rather than from its content.
+func corruptAt(packet int, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return err
+	}
+	return &CorruptError{Packet: packet, Err: err}
+}
+
+// NewReader sniffs the stream for compression and capture format and returns
+// a matching pure-Go packet reader. A stream that is neither is reported as a
+// *CorruptError.
+func NewReader(r io.Reader) (PacketReader, error) {
+	br, err := decompress(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, corruptAt(0, fmt.Errorf("failed to read capture header: %w", err))
+	}
+
+	if binary.LittleEndian.Uint32(magic) == pcapngMagic {
+		ng, err := pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, corruptAt(0, fmt.Errorf("failed to open pcapng: %w", err))
+		}
+		return ng, nil
+	}
+
+	pr, err := pcapgo.NewReader(br)
+	if err != nil {
+		return nil, corruptAt(0, fmt.Errorf("failed to open pcap: %w", err))
+	}
+	return pr, nil
+}
+
+// OpenFile opens a capture file from disk. The returned closer must be closed
+// once the reader is no longer needed.
+func OpenFile(filePath string) (PacketReader, io.Closer, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open capture file: %w", err)
+	}
+
+	reader, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return reader, file, nil
+}
+
+// FirstPacketTime returns the timestamp of the first packet in a capture file.
+func FirstPacketTime(filePath string) (time.Time, error) {
+	reader, closer, err := OpenFile(filePath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer closer.Close()
+
+	_, ci, err := readRecord(reader)
+	if errors.Is(err, io.EOF) {
+		return time.Time{}, fmt.Errorf("capture has no packets")
+	}
+	if err != nil {
+		return time.Time{}, corruptAt(1, err)
+	}
+	return ci.Timestamp, nil
+}
+
+// FileExt returns the extension a capture file should have for its content:
+// .pcap or .pcapng, followed by .gz if it is gzip compressed. Content that is
+// neither format counts as .pcap and is left for the analysis to report.
+func FileExt(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open capture file: %w", err)
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	suffix := ""
+	if magic, err := br.Peek(2); err == nil && magic[0] == gzipMagic1 && magic[1] == gzipMagic2 {
+		suffix = ".gz"
+		if br, err = decompress(br); err != nil {
+			return ".pcap" + suffix, nil
+		}
+	}
+
+	magic, err := br.Peek(4)
+	if err == nil && binary.LittleEndian.Uint32(magic) == pcapngMagic {
+		return ".pcapng" + suffix, nil
+	}
+	return ".pcap" + suffix, nil
+}
+
+func decompress(br *bufio.Reader) (*bufio.Reader, error) {
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, corruptAt(0, fmt.Errorf("failed to read capture header: %w", err))
+	}
+
+	switch {
+	case magic[0] == gzipMagic1 && magic[1] == gzipMagic2:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, corruptAt(0, fmt.Errorf("failed to open gzip stream: %w", err))
+		}
+		return bufio.NewReader(gz), nil
+	default:
+		return br, nil
+	}
+}
+
+// packetLinkType resolves the link type of a single packet. pcapng files can
+// carry several interfaces with different link types.
+func packetLinkType(src PacketReader, ci gopacket.CaptureInfo) layers.LinkType {
+	if ng, ok := src.(*pcapgo.NgReader); ok {
+		if intf, err := ng.Interface(ci.InterfaceIndex); err == nil {
+			return intf.LinkType
+		}
+	}
+	return src.LinkType()
+}
+
+// readRecord reads the next packet. The readers report a capture that ends
+// right after a record header as io.EOF, which is a truncated record.
+func readRecord(src PacketReader) ([]byte, gopacket.CaptureInfo, error) {
+	data, ci, err := src.ReadPacketData()
+	if err == io.EOF && ci.CaptureLength > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		err = checkRecord(src, ci)
+	}
+	return data, ci, err
+}
+
+// checkRecord catches records the pcapng reader lets through: a captured
+// length beyond the original packet length or the interface's snaplen. The
+// classic pcap reader already fails on those.
+func checkRecord(src PacketReader, ci gopacket.CaptureInfo) error {
+	if ci.CaptureLength > ci.Length {
+		return fmt.Errorf("capture length exceeds original packet length: %d > %d", ci.CaptureLength, ci.Length)
+	}
+	if ng, ok := src.(*pcapgo.NgReader); ok {
+		intf, err := ng.Interface(ci.InterfaceIndex)
+		if err == nil && intf.SnapLength != 0 && uint32(ci.CaptureLength) > intf.SnapLength {
+			return fmt.Errorf("capture length exceeds snap length: %d > %d", ci.CaptureLength, intf.SnapLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package capture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+type testRecord struct {
+	length int // original length on the wire
+	data   []byte
+}
+
+func records(sizes ...int) []testRecord {
+	var recs []testRecord
+	for _, size := range sizes {
+		recs = append(recs, testRecord{length: size, data: bytes.Repeat([]byte{0xab}, size)})
+	}
+	return recs
+}
+
+func captureInfo(i int, rec testRecord) gopacket.CaptureInfo {
+	return gopacket.CaptureInfo{
+		Timestamp:     time.Date(2026, 10, 15, 10, 0, i, 0, time.UTC),
+		CaptureLength: len(rec.data),
+		Length:        rec.length,
+	}
+}
+
+// writePcap writes a classic pcap with the given snaplen. The writer doesn't
+// check records against the snaplen, so it can exceed it.
+func writePcap(t *testing.T, snaplen uint32, recs []testRecord) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	if err := w.WriteFileHeader(snaplen, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	for i, rec := range recs {
+		if err := w.WritePacket(captureInfo(i, rec), rec.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// writePcapng writes a pcapng with one Ethernet interface of the given
+// snaplen.
+func writePcapng(t *testing.T, snaplen uint32, recs []testRecord) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := pcapgo.NewNgWriterInterface(&buf, pcapgo.NgInterface{
+		LinkType:   layers.LinkTypeEthernet,
+		SnapLength: snaplen,
+	}, pcapgo.DefaultNgWriterOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rec := range recs {
+		if err := w.WritePacket(captureInfo(i, rec), rec.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// shrinkLastRecord sets the original length of the last record of a capture
+// written by writePcap or writePcapng below its captured length of size,
+// which the writers refuse to do. In pcap it is the last field of the record
+// header, in pcapng it is followed by the padded data and the block length.
+func shrinkLastRecord(data []byte, size, length int, ng bool) []byte {
+	data = append([]byte(nil), data...)
+	offset := len(data) - size - 4
+	if ng {
+		offset -= 4
+	}
+	binary.LittleEndian.PutUint32(data[offset:], uint32(length))
+	return data
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadRecord(t *testing.T) {
+	full := writePcap(t, 65535, records(60, 80))
+	fullNg := writePcapng(t, 65535, records(60, 80))
+
+	tests := []struct {
+		name string
+		data []byte
+		// read is how many packets are read before the error.
+		read int
+		// err is a substring of the error, "" for a capture that ends
+		// cleanly with io.EOF.
+		err string
+		// unexpectedEOF is set for errors that must wrap
+		// io.ErrUnexpectedEOF.
+		unexpectedEOF bool
+	}{
+		{name: "pcap", data: full, read: 2},
+		{name: "pcap gzip", data: gzipped(t, full), read: 2},
+		{name: "pcap truncated in last record", data: full[:len(full)-10], read: 1, unexpectedEOF: true},
+		{name: "pcap truncated after last record header", data: full[:len(full)-80], read: 1, unexpectedEOF: true},
+		{name: "pcap truncated in last record header", data: full[:len(full)-80-8], read: 1, unexpectedEOF: true},
+		{name: "pcap beyond snaplen", data: writePcap(t, 64, records(60, 80)), read: 1, err: "capture length exceeds snap length: 80 > 64"},
+		{
+			name: "pcap beyond original length",
+			data: shrinkLastRecord(full, 80, 40, false),
+			read: 1,
+			err:  "capture length exceeds original packet length: 80 > 40",
+		},
+		{name: "pcapng", data: fullNg, read: 2},
+		{name: "pcapng gzip", data: gzipped(t, fullNg), read: 2},
+		{name: "pcapng truncated in last block", data: fullNg[:len(fullNg)-10], read: 1, unexpectedEOF: true},
+		{name: "pcapng beyond snaplen", data: writePcapng(t, 64, records(60, 80)), read: 1, err: "capture length exceeds snap length: 80 > 64"},
+		{
+			name: "pcapng beyond original length",
+			data: shrinkLastRecord(fullNg, 80, 40, true),
+			read: 1,
+			err:  "capture length exceeds original packet length: 80 > 40",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+
+			read := 0
+			for {
+				_, _, err = readRecord(reader)
+				if err != nil {
+					break
+				}
+				read++
+			}
+			if read != tt.read {
+				t.Errorf("read %d packets, want %d", read, tt.read)
+			}
+
+			switch {
+			case tt.unexpectedEOF:
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("error = %v, want io.ErrUnexpectedEOF", err)
+				}
+			case tt.err != "":
+				if err == io.EOF || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+			default:
+				if err != io.EOF {
+					t.Errorf("error = %v, want io.EOF", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewReaderCorrupt(t *testing.T) {
+	full := writePcap(t, 65535, records(60))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"too short for a magic", []byte{0xd4}},
+		{"unknown magic", bytes.Repeat([]byte{0x42}, 64)},
+		{"truncated header", full[:12]},
+		{"truncated gzip header", gzipped(t, full)[:5]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(tt.data))
+			var corrupt *CorruptError
+			if !errors.As(err, &corrupt) {
+				t.Fatalf("error = %v, want a *CorruptError", err)
+			}
+			if corrupt.Packet != 0 {
+				t.Errorf("Packet = %d, want 0 for a bad header", corrupt.Packet)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package capture
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TCPHealth aggregates the signs of a struggling TCP connection over the whole
+// capture. Retransmissions and out-of-order segments are told apart by
+// remembering the sequence gaps each direction has seen. It is nil in
+// CaptureStats when the tcp_health analyzer is disabled.
+type TCPHealth struct {
+	SYNs                int64   `json:"syns"`
+	SYNACKs             int64   `json:"syn_acks"`
+	HandshakesCompleted int64   `json:"handshakes_completed"`
+	Resets              int64   `json:"resets"`
+	FINs                int64   `json:"fins"`
+	DuplicateACKs       int64   `json:"duplicate_acks"`
+	Retransmissions     int64   `json:"retransmissions"`
+	OutOfOrder          int64   `json:"out_of_order"`
+	AvgHandshakeRTTMs   float64 `json:"avg_handshake_rtt_ms"`
+	MaxHandshakeRTTMs   float64 `json:"max_handshake_rtt_ms"`
+}
+
+// Gaps beyond this many per direction are forgotten; later fills are then
+// counted as retransmissions.
+const maxTrackedGaps = 32
+
+type seqRange struct {
+	start, end uint32
+}
+
+// tcpDirection is the sequence state of one side of a connection.
+type tcpDirection struct {
+	started bool
+	nextSeq uint32
+	gaps    []seqRange
+
+	lastAck    uint32
+	lastWindow uint16
+	hasAck     bool
+}
+
+type tcpHealthTable struct {
+	health     TCPHealth
+	directions map[flowKey]*tcpDirection
+}
+
+func newTCPHealthTable() *tcpHealthTable {
+	return &tcpHealthTable{directions: make(map[flowKey]*tcpDirection)}
+}
+
+// seqAfter reports whether a comes after b, allowing for wraparound.
+func seqAfter(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+func (t *tcpHealthTable) HandlePacket(packet gopacket.Packet, _ gopacket.CaptureInfo) {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil {
+		return
+	}
+	tcp := tcpLayer.(*layers.TCP)
+
+	key, ok := packetFlowKey(packet)
+	if !ok {
+		return
+	}
+
+	switch {
+	case tcp.SYN && tcp.ACK:
+		t.health.SYNACKs++
+	case tcp.SYN:
+		t.health.SYNs++
+	}
+	if tcp.RST {
+		t.health.Resets++
+	}
+	if tcp.FIN {
+		t.health.FINs++
+	}
+
+	dir, found := t.directions[key]
+	if !found {
+		dir = &tcpDirection{}
+		t.directions[key] = dir
+	}
+
+	// SYN and FIN occupy one sequence number each.
+	segLen := uint32(len(tcp.Payload))
+	if tcp.SYN {
+		segLen++
+	}
+	if tcp.FIN {
+		segLen++
+	}
+
+	if tcp.RST {
+		return
+	}
+
+	if segLen == 0 {
+		t.checkDuplicateACK(dir, tcp)
+		return
+	}
+	dir.hasAck = false
+
+	start, end := tcp.Seq, tcp.Seq+segLen
+	switch {
+	case !dir.started:
+		dir.started = true
+		dir.nextSeq = end
+	case seqAfter(start, dir.nextSeq):
+		// Something in between is missing (lost or still in flight).
+		if len(dir.gaps) < maxTrackedGaps {
+			dir.gaps = append(dir.gaps, seqRange{start: dir.nextSeq, end: start})
+		}
+		dir.nextSeq = end
+	case seqAfter(end, dir.nextSeq):
+		// Overlaps the end of what we have and extends it.
+		if t.fillsGap(dir, start, end) {
+			t.health.OutOfOrder++
+		} else if start != dir.nextSeq {
+			t.health.Retransmissions++
+		}
+		dir.nextSeq = end
+	default:
+		if t.fillsGap(dir, start, end) {
+			t.health.OutOfOrder++
+		} else {
+			t.health.Retransmissions++
+		}
+	}
+}
+
+// fillsGap reports whether the segment lands in a hole seen earlier, and
+// shrinks that hole.
+func (t *tcpHealthTable) fillsGap(dir *tcpDirection, start, end uint32) bool {
+	for i, gap := range dir.gaps {
+		if seqAfter(gap.start, start) || !seqAfter(gap.end, start) {
+			continue
+		}
+		switch {
+		case !seqAfter(gap.end, end) && start == gap.start:
+			dir.gaps = append(dir.gaps[:i], dir.gaps[i+1:]...)
+		case start == gap.start:
+			dir.gaps[i].start = end
+		case !seqAfter(gap.end, end):
+			dir.gaps[i].end = start
+		default:
+			dir.gaps[i].end = start
+			if len(dir.gaps) < maxTrackedGaps {
+				dir.gaps = append(dir.gaps, seqRange{start: end, end: gap.end})
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (t *tcpHealthTable) checkDuplicateACK(dir *tcpDirection, tcp *layers.TCP) {
+	if !tcp.ACK || tcp.SYN || tcp.FIN {
+		return
+	}
+	if dir.hasAck && tcp.Ack == dir.lastAck && tcp.Window == dir.lastWindow {
+		t.health.DuplicateACKs++
+	}
+	dir.hasAck = true
+	dir.lastAck = tcp.Ack
+	dir.lastWindow = tcp.Window
+}
+
+// Finalize needs the flows analyzer to have run first for the handshake RTTs.
+func (t *tcpHealthTable) Finalize(stats *CaptureStats) {
+	health := t.result(stats.Flows)
+	stats.TCPHealth = &health
+}
+
+// result folds the per-flow handshake RTTs into the aggregate.
+func (t *tcpHealthTable) result(flows []Flow) TCPHealth {
+	health := t.health
+
+	var total time.Duration
+	var maxRTT time.Duration
+	for _, flow := range flows {
+		if flow.HandshakeRTT <= 0 {
+			continue
+		}
+		health.HandshakesCompleted++
+		total += flow.HandshakeRTT
+		maxRTT = max(maxRTT, flow.HandshakeRTT)
+	}
+	if health.HandshakesCompleted > 0 {
+		health.AvgHandshakeRTTMs = durationMs(total / time.Duration(health.HandshakesCompleted))
+		health.MaxHandshakeRTTMs = durationMs(maxRTT)
+	}
+	return health
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,73 @@
+package capture
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TimelineBucket counts the packets and bytes of one protocol within one
+// second of the capture. Coarser buckets are summed up from these at query time.
+type TimelineBucket struct {
+	Start    time.Time `json:"start"`
+	Protocol string    `json:"protocol"`
+	Packets  int64     `json:"packets"`
+	Bytes    int64     `json:"bytes"`
+}
+
+type timelineKey struct {
+	second   int64
+	protocol string
+}
+
+type timelineTable struct {
+	buckets map[timelineKey]*TimelineBucket
+	// result is what Finalize reported, for Persist.
+	result []TimelineBucket
+}
+
+func newTimelineTable() *timelineTable {
+	return &timelineTable{buckets: make(map[timelineKey]*TimelineBucket)}
+}
+
+func packetProtocol(packet gopacket.Packet) string {
+	if key, ok := packetFlowKey(packet); ok {
+		return key.protocol
+	}
+	if packet.Layer(layers.LayerTypeARP) != nil {
+		return "ARP"
+	}
+	return "Other"
+}
+
+func (t *timelineTable) HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo) {
+	key := timelineKey{second: ci.Timestamp.Unix(), protocol: packetProtocol(packet)}
+	bucket, ok := t.buckets[key]
+	if !ok {
+		bucket = &TimelineBucket{Start: time.Unix(key.second, 0).UTC(), Protocol: key.protocol}
+		t.buckets[key] = bucket
+	}
+	bucket.Packets++
+	bucket.Bytes += int64(ci.Length)
+}
+
+func (t *timelineTable) Finalize(stats *CaptureStats) {
+	t.result = t.list()
+	stats.Timeline = t.result
+}
+
+func (t *timelineTable) list() []TimelineBucket {
+	buckets := make([]TimelineBucket, 0, len(t.buckets))
+	for _, bucket := range t.buckets {
+		buckets = append(buckets, *bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Start.Equal(buckets[j].Start) {
+			return buckets[i].Protocol < buckets[j].Protocol
+		}
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets
+}
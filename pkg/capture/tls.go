@@ -0,0 +1,391 @@
+package capture
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TLSSession holds the handshake metadata of one TLS connection. Client fields
+// come from the ClientHello, server fields from the ServerHello; either may be
+// missing when only one side made it into the capture.
+type TLSSession struct {
+	ClientIP   string    `json:"client_ip"`
+	ClientPort uint16    `json:"client_port"`
+	ServerIP   string    `json:"server_ip"`
+	ServerPort uint16    `json:"server_port"`
+	Timestamp  time.Time `json:"timestamp"`
+
+	SNI           string   `json:"sni,omitempty"`
+	ClientVersion string   `json:"client_version,omitempty"`
+	CipherSuites  []string `json:"cipher_suites,omitempty"`
+	JA3           string   `json:"ja3,omitempty"`
+	JA3Hash       string   `json:"ja3_hash,omitempty"`
+
+	Version     string `json:"version,omitempty"`
+	CipherSuite string `json:"cipher_suite,omitempty"`
+	JA3S        string `json:"ja3s,omitempty"`
+	JA3SHash    string `json:"ja3s_hash,omitempty"`
+}
+
+const (
+	tlsRecordHandshake   = 0x16
+	tlsClientHello       = 0x01
+	tlsServerHello       = 0x02
+	tlsExtServerName     = 0x0000
+	tlsExtSupportedGroup = 0x000a
+	tlsExtPointFormats   = 0x000b
+	tlsExtSupportedVers  = 0x002b
+
+	// Hellos larger than this are not worth buffering for.
+	maxTLSHelloBuffer = 64 * 1024
+)
+
+// tlsStream reassembles the start of one direction of a TCP connection until
+// the first handshake message is complete.
+type tlsStream struct {
+	nextSeq uint32
+	done    bool
+	data    []byte
+}
+
+type tlsTable struct {
+	streams  map[flowKey]*tlsStream
+	sessions map[flowKey]*TLSSession
+	order    []*TLSSession
+	// result is what Finalize reported, for Persist.
+	result []TLSSession
+}
+
+func newTLSTable() *tlsTable {
+	return &tlsTable{
+		streams:  make(map[flowKey]*tlsStream),
+		sessions: make(map[flowKey]*TLSSession),
+	}
+}
+
+func (t *tlsTable) HandlePacket(packet gopacket.Packet, ci gopacket.CaptureInfo) {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil {
+		return
+	}
+	tcp := tcpLayer.(*layers.TCP)
+	if len(tcp.Payload) == 0 {
+		return
+	}
+
+	key, ok := packetFlowKey(packet)
+	if !ok {
+		return
+	}
+
+	stream, found := t.streams[key]
+	if !found {
+		// Only follow streams that open with a handshake record.
+		if tcp.Payload[0] != tlsRecordHandshake {
+			t.streams[key] = &tlsStream{done: true}
+			return
+		}
+		stream = &tlsStream{nextSeq: tcp.Seq}
+		t.streams[key] = stream
+	}
+	if stream.done {
+		return
+	}
+
+	payload := tcp.Payload
+	if diff := int32(tcp.Seq - stream.nextSeq); diff != 0 {
+		if diff > 0 {
+			// Gap in the stream, the hello can't be reassembled.
+			stream.done = true
+			return
+		}
+		// Retransmission, keep only the part we haven't seen yet.
+		if int(-diff) >= len(payload) {
+			return
+		}
+		payload = payload[-diff:]
+	}
+	stream.data = append(stream.data, payload...)
+	stream.nextSeq += uint32(len(payload))
+
+	msgType, body, complete, err := firstHandshakeMessage(stream.data)
+	if err != nil || len(stream.data) > maxTLSHelloBuffer {
+		stream.done = true
+		stream.data = nil
+		return
+	}
+	if !complete {
+		return
+	}
+	stream.done = true
+	stream.data = nil
+
+	switch msgType {
+	case tlsClientHello:
+		session := t.session(key, ci)
+		parseClientHello(body, session)
+	case tlsServerHello:
+		session := t.session(key.reverse(), ci)
+		parseServerHello(body, session)
+	}
+}
+
+// session returns the session for a client→server key, creating it on first use.
+func (t *tlsTable) session(key flowKey, ci gopacket.CaptureInfo) *TLSSession {
+	if session, ok := t.sessions[key]; ok {
+		return session
+	}
+	session := &TLSSession{
+		ClientIP:   key.srcIP,
+		ClientPort: key.srcPort,
+		ServerIP:   key.dstIP,
+		ServerPort: key.dstPort,
+		Timestamp:  ci.Timestamp,
+	}
+	t.sessions[key] = session
+	t.order = append(t.order, session)
+	return session
+}
+
+func (t *tlsTable) Finalize(stats *CaptureStats) {
+	t.result = t.list()
+	stats.TLSSessions = t.result
+}
+
+func (t *tlsTable) list() []TLSSession {
+	sessions := make([]TLSSession, 0, len(t.order))
+	for _, session := range t.order {
+		sessions = append(sessions, *session)
+	}
+	return sessions
+}
+
+// firstHandshakeMessage collects handshake bytes from consecutive TLS records
+// and returns the first handshake message once it is complete.
+func firstHandshakeMessage(data []byte) (msgType byte, body []byte, complete bool, err error) {
+	var handshake []byte
+	for len(data) >= 5 {
+		if data[0] != tlsRecordHandshake {
+			return 0, nil, false, fmt.Errorf("unexpected TLS record type %d", data[0])
+		}
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			break
+		}
+		handshake = append(handshake, data[5:5+length]...)
+		data = data[5+length:]
+
+		if len(handshake) >= 4 {
+			msgLen := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+			if len(handshake) >= 4+msgLen {
+				return handshake[0], handshake[4 : 4+msgLen], true, nil
+			}
+		}
+	}
+	return 0, nil, false, nil
+}
+
+// helloReader is a bounds checked cursor over a hello message. Reads past the
+// end set failed instead of panicking.
+type helloReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *helloReader) bytes(n int) []byte {
+	if r.failed || n < 0 || len(r.data) < n {
+		r.failed = true
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *helloReader) uint8() int {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])
+}
+
+func (r *helloReader) uint16() int {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+type tlsExtension struct {
+	typ  int
+	data []byte
+}
+
+func (r *helloReader) extensions() []tlsExtension {
+	if len(r.data) == 0 {
+		return nil
+	}
+	ext := &helloReader{data: r.bytes(r.uint16())}
+	var extensions []tlsExtension
+	for len(ext.data) > 0 && !ext.failed {
+		typ := ext.uint16()
+		data := ext.bytes(ext.uint16())
+		if ext.failed {
+			break
+		}
+		extensions = append(extensions, tlsExtension{typ: typ, data: data})
+	}
+	return extensions
+}
+
+// isGREASE reports whether v is one of the reserved GREASE values (RFC 8701),
+// which JA3 ignores.
+func isGREASE(v int) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, "-")
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func tlsVersionName(v int) string {
+	switch v {
+	case 0x0300:
+		return "SSL 3.0"
+	case 0x0301:
+		return "TLS 1.0"
+	case 0x0302:
+		return "TLS 1.1"
+	case 0x0303:
+		return "TLS 1.2"
+	case 0x0304:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", v)
+	}
+}
+
+func parseClientHello(body []byte, session *TLSSession) {
+	r := &helloReader{data: body}
+	version := r.uint16()
+	r.bytes(32)        // random
+	r.bytes(r.uint8()) // session id
+	suites := r.bytes(r.uint16())
+	r.bytes(r.uint8()) // compression methods
+	extensions := r.extensions()
+	if r.failed {
+		return
+	}
+
+	var ciphers []int
+	for i := 0; i+1 < len(suites); i += 2 {
+		suite := int(binary.BigEndian.Uint16(suites[i:]))
+		if isGREASE(suite) {
+			continue
+		}
+		ciphers = append(ciphers, suite)
+		session.CipherSuites = append(session.CipherSuites, tls.CipherSuiteName(uint16(suite)))
+	}
+
+	maxVersion := version
+	var extTypes, groups, pointFormats []int
+	for _, ext := range extensions {
+		if isGREASE(ext.typ) {
+			continue
+		}
+		extTypes = append(extTypes, ext.typ)
+
+		data := &helloReader{data: ext.data}
+		switch ext.typ {
+		case tlsExtServerName:
+			list := &helloReader{data: data.bytes(data.uint16())}
+			for len(list.data) > 0 && !list.failed {
+				nameType := list.uint8()
+				name := list.bytes(list.uint16())
+				if nameType == 0 && !list.failed {
+					session.SNI = strings.ToLower(string(name))
+					break
+				}
+			}
+		case tlsExtSupportedGroup:
+			list := data.bytes(data.uint16())
+			for i := 0; i+1 < len(list); i += 2 {
+				if group := int(binary.BigEndian.Uint16(list[i:])); !isGREASE(group) {
+					groups = append(groups, group)
+				}
+			}
+		case tlsExtPointFormats:
+			for _, format := range data.bytes(data.uint8()) {
+				pointFormats = append(pointFormats, int(format))
+			}
+		case tlsExtSupportedVers:
+			list := data.bytes(data.uint8())
+			for i := 0; i+1 < len(list); i += 2 {
+				if v := int(binary.BigEndian.Uint16(list[i:])); !isGREASE(v) && v > maxVersion {
+					maxVersion = v
+				}
+			}
+		}
+	}
+
+	session.ClientVersion = tlsVersionName(maxVersion)
+	session.JA3 = strings.Join([]string{
+		strconv.Itoa(version),
+		joinInts(ciphers),
+		joinInts(extTypes),
+		joinInts(groups),
+		joinInts(pointFormats),
+	}, ",")
+	session.JA3Hash = md5Hex(session.JA3)
+}
+
+func parseServerHello(body []byte, session *TLSSession) {
+	r := &helloReader{data: body}
+	version := r.uint16()
+	r.bytes(32)        // random
+	r.bytes(r.uint8()) // session id
+	suite := r.uint16()
+	r.uint8() // compression method
+	extensions := r.extensions()
+	if r.failed {
+		return
+	}
+
+	negotiated := version
+	var extTypes []int
+	for _, ext := range extensions {
+		extTypes = append(extTypes, ext.typ)
+		if ext.typ == tlsExtSupportedVers && len(ext.data) == 2 {
+			negotiated = int(binary.BigEndian.Uint16(ext.data))
+		}
+	}
+
+	session.Version = tlsVersionName(negotiated)
+	session.CipherSuite = tls.CipherSuiteName(uint16(suite))
+	session.JA3S = strings.Join([]string{
+		strconv.Itoa(version),
+		strconv.Itoa(suite),
+		joinInts(extTypes),
+	}, ",")
+	session.JA3SHash = md5Hex(session.JA3S)
+}
@@ -0,0 +1,264 @@
+package capture
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// helloBuilder writes the length-prefixed fields of a hello message.
+type helloBuilder []byte
+
+func (b *helloBuilder) u8(v int) *helloBuilder {
+	*b = append(*b, byte(v))
+	return b
+}
+
+func (b *helloBuilder) u16(values ...int) *helloBuilder {
+	for _, v := range values {
+		*b = binary.BigEndian.AppendUint16(*b, uint16(v))
+	}
+	return b
+}
+
+func (b *helloBuilder) raw(data []byte) *helloBuilder {
+	*b = append(*b, data...)
+	return b
+}
+
+// vec8 and vec16 append data with a 1 or 2 byte length in front.
+func (b *helloBuilder) vec8(data []byte) *helloBuilder {
+	return b.u8(len(data)).raw(data)
+}
+
+func (b *helloBuilder) vec16(data []byte) *helloBuilder {
+	return b.u16(len(data)).raw(data)
+}
+
+func u16s(values ...int) []byte {
+	var b helloBuilder
+	return *b.u16(values...)
+}
+
+type testExtension struct {
+	typ  int
+	data []byte
+}
+
+func sniExtension(name string) testExtension {
+	var entry helloBuilder
+	entry.u8(0).vec16([]byte(name))
+	var list helloBuilder
+	list.vec16(entry)
+	return testExtension{tlsExtServerName, list}
+}
+
+func groupsExtension(groups ...int) testExtension {
+	var b helloBuilder
+	return testExtension{tlsExtSupportedGroup, *b.vec16(u16s(groups...))}
+}
+
+func pointFormatsExtension(formats ...byte) testExtension {
+	var b helloBuilder
+	return testExtension{tlsExtPointFormats, *b.vec8(formats)}
+}
+
+func versionsExtension(versions ...int) testExtension {
+	var b helloBuilder
+	return testExtension{tlsExtSupportedVers, *b.vec8(u16s(versions...))}
+}
+
+func clientHello(version int, suites []int, extensions ...testExtension) []byte {
+	var b helloBuilder
+	b.u16(version).raw(make([]byte, 32)).vec8(nil).vec16(u16s(suites...)).vec8([]byte{0})
+	b.vec16(encodeExtensions(extensions))
+	return b
+}
+
+func serverHello(version, suite int, extensions ...testExtension) []byte {
+	var b helloBuilder
+	b.u16(version).raw(make([]byte, 32)).vec8(nil).u16(suite).u8(0)
+	b.vec16(encodeExtensions(extensions))
+	return b
+}
+
+func encodeExtensions(extensions []testExtension) []byte {
+	var b helloBuilder
+	for _, ext := range extensions {
+		b.u16(ext.typ).vec16(ext.data)
+	}
+	return b
+}
+
+func TestParseClientHello(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		ja3         string
+		ja3Hash     string
+		sni         string
+		version     string
+		cipherCount int
+	}{
+		{
+			// The example from the JA3 README.
+			name: "ja3 reference",
+			body: clientHello(0x0301,
+				[]int{47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+				sniExtension("example.com"), groupsExtension(23, 24, 25), pointFormatsExtension(0)),
+			ja3:         "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0",
+			ja3Hash:     "ada70206e40642a3e4461f35503241d5",
+			sni:         "example.com",
+			version:     "TLS 1.0",
+			cipherCount: 12,
+		},
+		{
+			name: "grease is ignored",
+			body: clientHello(0x0301,
+				[]int{0x0a0a, 47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+				testExtension{0x1a1a, nil}, sniExtension("Example.COM"), groupsExtension(0x2a2a, 23, 24, 25), pointFormatsExtension(0)),
+			ja3:         "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0",
+			ja3Hash:     "ada70206e40642a3e4461f35503241d5",
+			sni:         "example.com",
+			version:     "TLS 1.0",
+			cipherCount: 12,
+		},
+		{
+			name: "tls 1.3 from supported versions",
+			body: clientHello(0x0303, []int{0x1301, 0x1302},
+				versionsExtension(0x3a3a, 0x0304, 0x0303), groupsExtension(29)),
+			ja3:         "771,4865-4866,43-10,29,",
+			ja3Hash:     md5Hex("771,4865-4866,43-10,29,"),
+			version:     "TLS 1.3",
+			cipherCount: 2,
+		},
+		{
+			name: "no extensions",
+			body: func() []byte {
+				var b helloBuilder
+				return *b.u16(0x0303).raw(make([]byte, 32)).vec8(nil).vec16(u16s(47)).vec8([]byte{0})
+			}(),
+			ja3:         "771,47,,,",
+			ja3Hash:     md5Hex("771,47,,,"),
+			version:     "TLS 1.2",
+			cipherCount: 1,
+		},
+		{
+			name: "truncated",
+			body: clientHello(0x0303, []int{47, 53}, sniExtension("example.com"))[:40],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var session TLSSession
+			parseClientHello(tt.body, &session)
+			if session.JA3 != tt.ja3 {
+				t.Errorf("JA3 = %q, want %q", session.JA3, tt.ja3)
+			}
+			if session.JA3Hash != tt.ja3Hash {
+				t.Errorf("JA3Hash = %q, want %q", session.JA3Hash, tt.ja3Hash)
+			}
+			if session.SNI != tt.sni {
+				t.Errorf("SNI = %q, want %q", session.SNI, tt.sni)
+			}
+			if session.ClientVersion != tt.version {
+				t.Errorf("ClientVersion = %q, want %q", session.ClientVersion, tt.version)
+			}
+			if len(session.CipherSuites) != tt.cipherCount {
+				t.Errorf("got %d cipher suites, want %d", len(session.CipherSuites), tt.cipherCount)
+			}
+		})
+	}
+}
+
+func TestParseServerHello(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		ja3s     string
+		ja3sHash string
+		version  string
+		suite    string
+	}{
+		{
+			name: "tls 1.0",
+			body: serverHello(0x0301, 47,
+				testExtension{0xff01, []byte{0}}, testExtension{0, nil}, pointFormatsExtension(0), testExtension{35, nil}, testExtension{5, nil}, testExtension{16, []byte{0, 3, 2, 'h', '2'}}),
+			ja3s:     "769,47,65281-0-11-35-5-16",
+			ja3sHash: "836ce314215654b5b1f85f97c73e506f",
+			version:  "TLS 1.0",
+			suite:    "TLS_RSA_WITH_AES_128_CBC_SHA",
+		},
+		{
+			name:     "tls 1.3 from supported versions",
+			body:     serverHello(0x0303, 0x1301, testExtension{tlsExtSupportedVers, u16s(0x0304)}, testExtension{51, make([]byte, 36)}),
+			ja3s:     "771,4865,43-51",
+			ja3sHash: md5Hex("771,4865,43-51"),
+			version:  "TLS 1.3",
+			suite:    "TLS_AES_128_GCM_SHA256",
+		},
+		{
+			name: "truncated",
+			body: serverHello(0x0303, 0x1301)[:20],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var session TLSSession
+			parseServerHello(tt.body, &session)
+			if session.JA3S != tt.ja3s {
+				t.Errorf("JA3S = %q, want %q", session.JA3S, tt.ja3s)
+			}
+			if session.JA3SHash != tt.ja3sHash {
+				t.Errorf("JA3SHash = %q, want %q", session.JA3SHash, tt.ja3sHash)
+			}
+			if session.Version != tt.version {
+				t.Errorf("Version = %q, want %q", session.Version, tt.version)
+			}
+			if session.CipherSuite != tt.suite {
+				t.Errorf("CipherSuite = %q, want %q", session.CipherSuite, tt.suite)
+			}
+		})
+	}
+}
+
+func TestFirstHandshakeMessage(t *testing.T) {
+	body := clientHello(0x0303, []int{47}, sniExtension("example.com"))
+	var message helloBuilder
+	message.u8(tlsClientHello).u8(0).vec16(body)
+
+	record := func(fragment []byte) []byte {
+		var b helloBuilder
+		return *b.u8(tlsRecordHandshake).u16(0x0301).vec16(fragment)
+	}
+	split := len(message) / 2
+
+	tests := []struct {
+		name     string
+		data     []byte
+		complete bool
+		err      bool
+	}{
+		{"one record", record(message), true, false},
+		{"split over two records", append(record(message[:split]), record(message[split:])...), true, false},
+		{"second record missing", record(message[:split]), false, false},
+		{"record cut short", record(message)[:len(message)], false, false},
+		{"not a handshake", append([]byte{0x17}, record(message)[1:]...), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgType, got, complete, err := firstHandshakeMessage(tt.data)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if complete != tt.complete {
+				t.Fatalf("complete = %v, want %v", complete, tt.complete)
+			}
+			if complete && (msgType != tlsClientHello || string(got) != string(body)) {
+				t.Errorf("got message type %d with %d bytes, want ClientHello with %d", msgType, len(got), len(body))
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
@@ -251,7 +252,7 @@ func NewClient(server, password string, port int, socket string) (*Client, error
 	}, nil
 }
 
-func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response, error) {
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
 	var fullURL string
 
 	if c.socketPath != "" {
@@ -279,6 +280,15 @@ func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response,
 		req.Header.Set("Authorization", c.password)
 	}
 
+	return req, nil
+}
+
+func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -351,8 +361,49 @@ func (c *Client) GetFile(id int64) (any, error) {
 	return result, err
 }
 
-func (c *Client) DownloadFile(id int64, outputPath string) error {
-	resp, err := c.doRequest("GET", fmt.Sprintf("/api/files/%d/download", id), nil)
+// DownloadFile saves a stored file at outputPath. params can ask for an
+// anonymized copy (anonymize, anonymize_macs, truncate_payload).
+func (c *Client) DownloadFile(id int64, params url.Values, outputPath string) error {
+	path := fmt.Sprintf("/api/files/%d/download", id)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	return c.downloadTo("GET", path, nil, outputPath)
+}
+
+// ExtractFile saves the packets of a file matching params (bpf, from, to) as
+// a new pcap at outputPath, anonymized if params ask for it.
+func (c *Client) ExtractFile(id int64, params url.Values, outputPath string) error {
+	path := fmt.Sprintf("/api/files/%d/extract", id)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	return c.downloadTo("GET", path, nil, outputPath)
+}
+
+// MergeFiles saves the merge described by req (ids, search, format) at
+// outputPath.
+func (c *Client) MergeFiles(req map[string]any, outputPath string) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.downloadTo("POST", "/api/merge", bytes.NewReader(jsonData), outputPath)
+}
+
+// MergeAndIngest stores the merge described by req as a new capture.
+func (c *Client) MergeAndIngest(req map[string]any) (any, error) {
+	body := map[string]any{"ingest": true}
+	for key, value := range req {
+		body[key] = value
+	}
+	var result any
+	err := c.doJSONRequest("POST", "/api/merge", body, &result)
+	return result, err
+}
+
+func (c *Client) downloadTo(method, path string, body io.Reader, outputPath string) error {
+	resp, err := c.doRequest(method, path, body)
 	if err != nil {
 		return err
 	}
@@ -373,16 +424,200 @@ func (c *Client) DownloadFile(id int64, outputPath string) error {
 	return err
 }
 
+// UploadFile streams a local capture to the server as a multipart upload.
+func (c *Client) UploadFile(filePath string) (any, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		defer file.Close()
+		part, err := mw.CreateFormFile("file", filepath.Base(filePath))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, file); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+
+	req, err := c.newRequest("POST", "/api/files", pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	// Large captures can take longer than the default request timeout
+	uploadClient := *c.httpClient
+	uploadClient.Timeout = 0
+
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %s - %s", resp.Status, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var result any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (c *Client) GetFileStats(id int64) (any, error) {
 	var result any
 	err := c.doJSONRequest("GET", fmt.Sprintf("/api/files/%d/stats", id), nil, &result)
 	return result, err
 }
 
+func (c *Client) GetFileFlows(id int64, params url.Values) (any, error) {
+	var result any
+	path := fmt.Sprintf("/api/files/%d/flows", id)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	err := c.doJSONRequest("GET", path, nil, &result)
+	return result, err
+}
+
+func (c *Client) GetFileHTTP(id int64, params url.Values) (any, error) {
+	var result any
+	path := fmt.Sprintf("/api/files/%d/http", id)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	err := c.doJSONRequest("GET", path, nil, &result)
+	return result, err
+}
+
+func (c *Client) GetFileTimeline(id int64, bucket string) (any, error) {
+	var result any
+	path := fmt.Sprintf("/api/files/%d/timeline", id)
+	if bucket != "" {
+		path = fmt.Sprintf("%s?bucket=%s", path, url.QueryEscape(bucket))
+	}
+	err := c.doJSONRequest("GET", path, nil, &result)
+	return result, err
+}
+
+func (c *Client) ReanalyzeFile(id int64) (any, error) {
+	var result any
+	err := c.doJSONRequest("POST", fmt.Sprintf("/api/files/%d/reanalyze", id), nil, &result)
+	return result, err
+}
+
+func (c *Client) Reanalyze(versionLt int64) (any, error) {
+	var result any
+	path := "/api/reanalyze"
+	if versionLt > 0 {
+		path = fmt.Sprintf("/api/reanalyze?analyzer_version_lt=%d", versionLt)
+	}
+	err := c.doJSONRequest("POST", path, nil, &result)
+	return result, err
+}
+
 func (c *Client) DeleteFile(id int64) error {
 	return c.doJSONRequest("DELETE", fmt.Sprintf("/api/file/%d", id), nil, nil)
 }
 
+func (c *Client) AddTag(id int64, tag string) (any, error) {
+	var result any
+	err := c.doJSONRequest("PUT", fmt.Sprintf("/api/file/%d/tags/%s", id, url.PathEscape(tag)), nil, &result)
+	return result, err
+}
+
+func (c *Client) RemoveTag(id int64, tag string) (any, error) {
+	var result any
+	err := c.doJSONRequest("DELETE", fmt.Sprintf("/api/file/%d/tags/%s", id, url.PathEscape(tag)), nil, &result)
+	return result, err
+}
+
+func (c *Client) AddNote(id int64, text string) (any, error) {
+	var result any
+	err := c.doJSONRequest("POST", fmt.Sprintf("/api/file/%d/notes", id), map[string]string{"text": text}, &result)
+	return result, err
+}
+
+func (c *Client) GetNotes(id int64) (any, error) {
+	var result any
+	err := c.doJSONRequest("GET", fmt.Sprintf("/api/file/%d/notes", id), nil, &result)
+	return result, err
+}
+
+// Verify re-hashes stored files, all of them or only ids, and reports the
+// ones that no longer match.
+func (c *Client) Verify(ids []int64) (map[string]any, error) {
+	var result map[string]any
+	params := url.Values{}
+	for _, id := range ids {
+		params.Add("id", strconv.FormatInt(id, 10))
+	}
+	path := "/api/verify"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	err := c.doJSONRequest("POST", path, nil, &result)
+	return result, err
+}
+
+func (c *Client) GetJobs(params url.Values) (any, error) {
+	var result any
+	path := "/api/jobs"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	err := c.doJSONRequest("GET", path, nil, &result)
+	return result, err
+}
+
+func (c *Client) RetryJob(id int64) (any, error) {
+	var result any
+	err := c.doJSONRequest("POST", fmt.Sprintf("/api/jobs/%d/retry", id), nil, &result)
+	return result, err
+}
+
+func (c *Client) RetryFailedJobs() (any, error) {
+	var result any
+	err := c.doJSONRequest("POST", "/api/jobs/retry", nil, &result)
+	return result, err
+}
+
+func (c *Client) GetRejections(params url.Values) (any, error) {
+	var result any
+	path := "/api/rejections"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	err := c.doJSONRequest("GET", path, nil, &result)
+	return result, err
+}
+
+// RetryRejection puts a quarantined file back into its watch dir, renamed to
+// name unless that is empty.
+func (c *Client) RetryRejection(id int64, name string) (any, error) {
+	var result any
+	err := c.doJSONRequest("POST", fmt.Sprintf("/api/rejections/%d/retry", id), map[string]string{"name": name}, &result)
+	return result, err
+}
+
+func (c *Client) Reconcile() (any, error) {
+	var result any
+	err := c.doJSONRequest("POST", "/api/reconcile", nil, &result)
+	return result, err
+}
+
 func (c *Client) GetArchive() (any, error) {
 	var result any
 	err := c.doJSONRequest("GET", "/api/archive", nil, &result)
@@ -453,16 +688,47 @@ func (c *Client) CompressTrigger() (any, error) {
 }
 
 // Search & Query
-func (c *Client) Search(query string) (any, error) {
+func (c *Client) Search(query string, filters url.Values) (any, error) {
 	var result any
-	path := "/api/search"
+	params := url.Values{}
+	for key, values := range filters {
+		params[key] = values
+	}
 	if query != "" {
-		path = fmt.Sprintf("/api/search?q=%s", url.QueryEscape(query))
+		params.Set("q", query)
+	}
+	path := "/api/search"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
 	}
 	err := c.doJSONRequest("GET", path, nil, &result)
 	return result, err
 }
 
+func (c *Client) SearchDNS(name string) (any, error) {
+	var result any
+	err := c.doJSONRequest("GET", fmt.Sprintf("/api/dns?name=%s", url.QueryEscape(name)), nil, &result)
+	return result, err
+}
+
+func (c *Client) GetFindings(params url.Values) (any, error) {
+	var result any
+	path := "/api/findings"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	err := c.doJSONRequest("GET", path, nil, &result)
+	return result, err
+}
+
+// GetDiff compares two captures (a, b), or capture b with a baseline
+// scenario (b, baseline).
+func (c *Client) GetDiff(params url.Values) (any, error) {
+	var result any
+	err := c.doJSONRequest("GET", "/api/diff?"+params.Encode(), nil, &result)
+	return result, err
+}
+
 func (c *Client) GetFilesByHostname(hostname string) (any, error) {
 	var result any
 	err := c.doJSONRequest("GET", fmt.Sprintf("/api/files/by-hostname/%s", url.PathEscape(hostname)), nil, &result)
@@ -482,24 +748,12 @@ func (c *Client) QuerySQL(query string) (any, error) {
 	return result, err
 }
 
-func (c *Client) ExportStore(outputPath string) error {
-	resp, err := c.doRequest("GET", "/api/export", nil)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
-	}
-
-	outFile, err := os.Create(outputPath)
-	if err != nil {
-		return err
+// ExportStore saves an archive of the store at outputPath. params can ask
+// for anonymized captures (anonymize, anonymize_macs, truncate_payload).
+func (c *Client) ExportStore(params url.Values, outputPath string) error {
+	path := "/api/export"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
 	}
-	defer outFile.Close()
-
-	_, err = io.Copy(outFile, resp.Body)
-	return err
+	return c.downloadTo("GET", path, nil, outputPath)
 }
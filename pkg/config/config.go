@@ -122,18 +122,29 @@ func diffConfigs(fileCfg, dbCfg Config) []ConfigDiff {
 func chooseConfig(cfg Config, dbCfg Config, diffs []ConfigDiff) (Config, ConfigSource) {
 	diffFields := make(map[string]bool)
 	fieldLabelMap := map[string]string{
-		"WatchDir":           "Watch Dir",
-		"OrganizedDir":       "Organized Dir",
-		"ArchiveDir":         "Archive Dir",
-		"Port":               "Port",
-		"ExposeService":      "Service Exposed",
-		"CompressionEnabled": "Compression Enabled",
-		"ArchiveDays":        "Archive Days",
-		"MaxRetentionDays":   "Max Retention Days",
-		"CleanupIntervalHrs": "Cleanup Interval Hours",
-		"BatchSize":          "Batch Size",
-		"LogLevel":           "Log Level",
-		"UpdatedAt":          "Updated At",
+		"WatchDir":            "Watch Dir",
+		"OrganizedDir":        "Organized Dir",
+		"ArchiveDir":          "Archive Dir",
+		"Port":                "Port",
+		"ExposeService":       "Service Exposed",
+		"CompressionEnabled":  "Compression Enabled",
+		"ArchiveDays":         "Archive Days",
+		"MaxRetentionDays":    "Max Retention Days",
+		"CleanupIntervalHrs":  "Cleanup Interval Hours",
+		"BatchSize":           "Batch Size",
+		"LogLevel":            "Log Level",
+		"WatchDirs":           "Watch Dirs",
+		"QuarantineDir":       "Quarantine Dir",
+		"WatchMode":           "Watch Mode",
+		"PollIntervalSeconds": "Poll Interval Seconds",
+		"QuietSeconds":        "Quiet Seconds",
+		"AnonymizationKey":    "Anonymization Key",
+		"FilenamePatterns":    "Filename Patterns",
+		"SidecarWaitSeconds":  "Sidecar Wait Seconds",
+		"DuplicatePolicy":     "Duplicate Policy",
+		"IngestWorkers":       "Ingest Workers",
+		"Analyzers":           "Analyzers",
+		"UpdatedAt":           "Updated At",
 	}
 
 	for _, diff := range diffs {
@@ -193,6 +204,17 @@ func printConfigFormatted(cfg Config, diffFields map[string]bool) {
 		{"Archive Days", cfg.ArchiveDays},
 		{"Max Retention Days", cfg.MaxRetentionDays},
 		{"Log Level", cfg.LogLevel},
+		{"Watch Dirs", cfg.WatchDirs},
+		{"Quarantine Dir", cfg.QuarantineDir},
+		{"Watch Mode", cfg.WatchMode},
+		{"Poll Interval Seconds", cfg.PollIntervalSeconds},
+		{"Quiet Seconds", cfg.QuietSeconds},
+		{"Anonymization Key", MaskSecret(cfg.AnonymizationKey)},
+		{"Filename Patterns", cfg.FilenamePatterns},
+		{"Sidecar Wait Seconds", formatOptional(cfg.SidecarWaitSeconds)},
+		{"Duplicate Policy", cfg.DuplicatePolicy},
+		{"Ingest Workers", cfg.IngestWorkers},
+		{"Analyzers", cfg.Analyzers},
 	}
 
 	for _, f := range fields {
@@ -203,3 +225,22 @@ func printConfigFormatted(cfg Config, diffFields map[string]bool) {
 		}
 	}
 }
+
+// formatOptional shows an unset setting as such rather than as a pointer.
+func formatOptional(v *int) any {
+	if v == nil {
+		return "(default)"
+	}
+	return *v
+}
+
+// SecretMask stands in for a secret config value that is set.
+const SecretMask = "********"
+
+// MaskSecret hides a secret config value while still showing whether it is set.
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return SecretMask
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"database/sql"
+	"encoding/json"
 
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
 )
@@ -16,32 +17,217 @@ type Config struct {
 	ArchiveDays        int    `toml:"archive_days"`
 	MaxRetentionDays   int    `toml:"max_retention_days"`
 	LogLevel           string `toml:"log_level"`
+
+	// WatchDirs are watched in addition to WatchDir.
+	WatchDirs []WatchDir `toml:"watch_dirs"`
+
+	// QuarantineDir is where files the sorter rejects are moved to. Empty
+	// uses ./data/captures/quarantine.
+	QuarantineDir string `toml:"quarantine_dir"`
+
+	// WatchMode is how the watch dirs are watched: notify (default) uses
+	// filesystem events, poll scans them every PollIntervalSeconds for
+	// mounts that don't send events, both does both.
+	WatchMode           string `toml:"watch_mode"`
+	PollIntervalSeconds int    `toml:"poll_interval_seconds"`
+
+	// QuietSeconds is how long a capture must go unchanged before it counts
+	// as finished. 0 uses the default of 3.
+	QuietSeconds int `toml:"quiet_seconds"`
+
+	// AnonymizationKey keys the address mapping of anonymized downloads and
+	// exports. Keep it secret and unchanged to get the same mapping every time.
+	AnonymizationKey string `toml:"anonymization_key"`
+
+	// FilenamePatterns are tried in order after the built-in
+	// {hostname}_{scenario}_{YYYYMMDD_HHmmss} format.
+	FilenamePatterns []FilenamePattern `toml:"filename_patterns"`
+
+	// SidecarWaitSeconds is how long a finished capture waits for its
+	// metadata sidecar to show up. Unset uses the default of 5, 0 only picks
+	// up sidecars that are already there.
+	SidecarWaitSeconds *int `toml:"sidecar_wait_seconds"`
+
+	// DuplicatePolicy decides what happens to a capture whose content is
+	// already stored: reject (default), link or allow.
+	DuplicatePolicy string `toml:"duplicate_policy"`
+
+	// IngestWorkers is how many watched captures are analyzed at the same
+	// time. 0 uses the default of 4.
+	IngestWorkers int `toml:"ingest_workers"`
+
+	// Analyzers switches capture analyzers on or off by name. Analyzers not
+	// listed stay enabled.
+	Analyzers map[string]bool `toml:"analyzers"`
+}
+
+const (
+	WatchModeNotify = "notify"
+	WatchModePoll   = "poll"
+	WatchModeBoth   = "both"
+)
+
+const (
+	DuplicatePolicyReject = "reject"
+	DuplicatePolicyLink   = "link"
+	DuplicatePolicyAllow  = "allow"
+)
+
+// WatchDir is an extra directory to watch for captures. Recursive also
+// watches its subdirectories, including ones created later. Captures whose
+// name matches no filename pattern are still taken if the directory gives
+// them a hostname and a scenario; their datetime comes from the first packet.
+// HostnameFromDir uses the name of the subdirectory of Path a capture is in,
+// falling back to Hostname for captures directly in Path.
+type WatchDir struct {
+	Path            string `toml:"path"`
+	Recursive       bool   `toml:"recursive,omitempty"`
+	HostnameFromDir bool   `toml:"hostname_from_dir,omitempty"`
+	Hostname        string `toml:"hostname,omitempty"`
+	Scenario        string `toml:"scenario,omitempty"`
+}
+
+// FilenamePattern describes a capture filename format. Regex is matched
+// against the name without its capture extension and may have the named
+// groups hostname, scenario and timestamp. Hostname and Scenario are used
+// when the regex has no such group. TimestampLayout is a Go time layout for
+// the timestamp group; without one the time of the first packet is used.
+type FilenamePattern struct {
+	Name            string `toml:"name"`
+	Regex           string `toml:"regex"`
+	TimestampLayout string `toml:"timestamp_layout,omitempty"`
+	Hostname        string `toml:"hostname,omitempty"`
+	Scenario        string `toml:"scenario,omitempty"`
 }
 
 func (c Config) FromDB(dbCfg sqlc.Config) Config {
 	return Config{
-		WatchDir:           dbCfg.WatchDir.String,
-		OrganizedDir:       dbCfg.OrganizedDir.String,
-		ExposeService:      dbCfg.ExposeService.Bool,
-		Port:               int(dbCfg.Port.Int64),
-		ArchiveDir:         dbCfg.ArchiveDir.String,
-		CompressionEnabled: dbCfg.CompressionEnabled.Bool,
-		ArchiveDays:        int(dbCfg.ArchiveDays.Int64),
-		MaxRetentionDays:   int(dbCfg.MaxRetentionDays.Int64),
-		LogLevel:           dbCfg.LogLevel.String,
+		WatchDir:            dbCfg.WatchDir.String,
+		OrganizedDir:        dbCfg.OrganizedDir.String,
+		ExposeService:       dbCfg.ExposeService.Bool,
+		Port:                int(dbCfg.Port.Int64),
+		ArchiveDir:          dbCfg.ArchiveDir.String,
+		CompressionEnabled:  dbCfg.CompressionEnabled.Bool,
+		ArchiveDays:         int(dbCfg.ArchiveDays.Int64),
+		MaxRetentionDays:    int(dbCfg.MaxRetentionDays.Int64),
+		LogLevel:            dbCfg.LogLevel.String,
+		WatchDirs:           watchDirsFromDB(dbCfg.WatchDirs),
+		QuarantineDir:       dbCfg.QuarantineDir.String,
+		WatchMode:           dbCfg.WatchMode.String,
+		PollIntervalSeconds: int(dbCfg.PollIntervalSeconds.Int64),
+		QuietSeconds:        int(dbCfg.QuietSeconds.Int64),
+		AnonymizationKey:    dbCfg.AnonymizationKey.String,
+		FilenamePatterns:    filenamePatternsFromDB(dbCfg.FilenamePatterns),
+		SidecarWaitSeconds:  intFromDB(dbCfg.SidecarWaitSeconds),
+		DuplicatePolicy:     dbCfg.DuplicatePolicy.String,
+		IngestWorkers:       int(dbCfg.IngestWorkers.Int64),
+		Analyzers:           analyzersFromDB(dbCfg.Analyzers),
+	}
+}
+
+// intFromDB is a setting whose zero value is different from leaving it unset.
+func intFromDB(raw sql.NullInt64) *int {
+	if !raw.Valid {
+		return nil
+	}
+	v := int(raw.Int64)
+	return &v
+}
+
+func filenamePatternsFromDB(raw sql.NullString) []FilenamePattern {
+	if !raw.Valid || raw.String == "" {
+		return nil
 	}
+	var patterns []FilenamePattern
+	if err := json.Unmarshal([]byte(raw.String), &patterns); err != nil || len(patterns) == 0 {
+		return nil
+	}
+	return patterns
+}
+
+func watchDirsFromDB(raw sql.NullString) []WatchDir {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var dirs []WatchDir
+	if err := json.Unmarshal([]byte(raw.String), &dirs); err != nil || len(dirs) == 0 {
+		return nil
+	}
+	return dirs
+}
+
+func analyzersFromDB(raw sql.NullString) map[string]bool {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var analyzers map[string]bool
+	if err := json.Unmarshal([]byte(raw.String), &analyzers); err != nil || len(analyzers) == 0 {
+		return nil
+	}
+	return analyzers
 }
 
 func (c Config) ToUpdateParams() sqlc.UpdateConfigParams {
 	return sqlc.UpdateConfigParams{
-		WatchDir:           sql.NullString{String: c.WatchDir, Valid: c.WatchDir != ""},
-		OrganizedDir:       sql.NullString{String: c.OrganizedDir, Valid: c.OrganizedDir != ""},
-		ArchiveDir:         sql.NullString{String: c.ArchiveDir, Valid: c.ArchiveDir != ""},
-		ExposeService:      sql.NullBool{Bool: c.ExposeService, Valid: true},
-		Port:               sql.NullInt64{Int64: int64(c.Port), Valid: c.Port >= 1024 && c.Port <= 65536},
-		CompressionEnabled: sql.NullBool{Bool: c.CompressionEnabled, Valid: true},
-		ArchiveDays:        sql.NullInt64{Int64: int64(c.ArchiveDays), Valid: c.ArchiveDays > 0},
-		MaxRetentionDays:   sql.NullInt64{Int64: int64(c.MaxRetentionDays), Valid: c.MaxRetentionDays > 0},
-		LogLevel:           sql.NullString{String: c.LogLevel, Valid: c.LogLevel != ""},
+		WatchDir:            sql.NullString{String: c.WatchDir, Valid: c.WatchDir != ""},
+		OrganizedDir:        sql.NullString{String: c.OrganizedDir, Valid: c.OrganizedDir != ""},
+		ArchiveDir:          sql.NullString{String: c.ArchiveDir, Valid: c.ArchiveDir != ""},
+		ExposeService:       sql.NullBool{Bool: c.ExposeService, Valid: true},
+		Port:                sql.NullInt64{Int64: int64(c.Port), Valid: c.Port >= 1024 && c.Port <= 65536},
+		CompressionEnabled:  sql.NullBool{Bool: c.CompressionEnabled, Valid: true},
+		ArchiveDays:         sql.NullInt64{Int64: int64(c.ArchiveDays), Valid: c.ArchiveDays > 0},
+		MaxRetentionDays:    sql.NullInt64{Int64: int64(c.MaxRetentionDays), Valid: c.MaxRetentionDays > 0},
+		LogLevel:            sql.NullString{String: c.LogLevel, Valid: c.LogLevel != ""},
+		WatchDirs:           watchDirsToDB(c.WatchDirs),
+		QuarantineDir:       sql.NullString{String: c.QuarantineDir, Valid: c.QuarantineDir != ""},
+		WatchMode:           sql.NullString{String: c.WatchMode, Valid: c.WatchMode != ""},
+		PollIntervalSeconds: sql.NullInt64{Int64: int64(c.PollIntervalSeconds), Valid: c.PollIntervalSeconds > 0},
+		QuietSeconds:        sql.NullInt64{Int64: int64(c.QuietSeconds), Valid: c.QuietSeconds > 0},
+		AnonymizationKey:    sql.NullString{String: c.AnonymizationKey, Valid: c.AnonymizationKey != ""},
+		FilenamePatterns:    filenamePatternsToDB(c.FilenamePatterns),
+		SidecarWaitSeconds:  intToDB(c.SidecarWaitSeconds),
+		DuplicatePolicy:     sql.NullString{String: c.DuplicatePolicy, Valid: c.DuplicatePolicy != ""},
+		IngestWorkers:       sql.NullInt64{Int64: int64(c.IngestWorkers), Valid: c.IngestWorkers > 0},
+		Analyzers:           analyzersToDB(c.Analyzers),
+	}
+}
+
+func intToDB(v *int) sql.NullInt64 {
+	if v == nil || *v < 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*v), Valid: true}
+}
+
+func filenamePatternsToDB(patterns []FilenamePattern) sql.NullString {
+	if len(patterns) == 0 {
+		return sql.NullString{}
+	}
+	raw, err := json.Marshal(patterns)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(raw), Valid: true}
+}
+
+func watchDirsToDB(dirs []WatchDir) sql.NullString {
+	if len(dirs) == 0 {
+		return sql.NullString{}
+	}
+	raw, err := json.Marshal(dirs)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(raw), Valid: true}
+}
+
+func analyzersToDB(analyzers map[string]bool) sql.NullString {
+	if len(analyzers) == 0 {
+		return sql.NullString{}
+	}
+	raw, err := json.Marshal(analyzers)
+	if err != nil {
+		return sql.NullString{}
 	}
+	return sql.NullString{String: string(raw), Valid: true}
 }
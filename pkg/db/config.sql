@@ -8,10 +8,21 @@ UPDATE config
 SET watch_dir = ?,
 organized_dir = ?,
 archive_dir = ?,
+quarantine_dir = ?,
 expose_service = ?,
 port = ?,
 compression_enabled = ?,
 archive_days = ?,
 max_retention_days = ?,
-log_level = ?;
+log_level = ?,
+watch_dirs = ?,
+watch_mode = ?,
+poll_interval_seconds = ?,
+quiet_seconds = ?,
+analyzers = ?,
+anonymization_key = ?,
+filename_patterns = ?,
+sidecar_wait_seconds = ?,
+duplicate_policy = ?,
+ingest_workers = ?;
 
@@ -3,15 +3,20 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
 
 	_ "embed"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 //go:embed schema.sql
@@ -23,7 +28,9 @@ type Store struct {
 }
 
 func openDB(dbPath string) (*sql.DB, error) {
-	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL", dbPath)
+	// modernc.org/sqlite only takes pragmas as _pragma parameters. Without the
+	// busy timeout concurrent writers fail with SQLITE_BUSY right away.
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", dbPath)
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
@@ -40,6 +47,13 @@ func openDB(dbPath string) (*sql.DB, error) {
 	return db, nil
 }
 
+// schemaMu serializes creating and migrating the database; schemaReady
+// remembers which ones are up to date, so later calls skip the version check.
+var (
+	schemaMu    sync.Mutex
+	schemaReady = make(map[string]bool)
+)
+
 func InitIfNeeded() (*Store, error) {
 	dir, err := os.Getwd()
 	if err != nil {
@@ -47,45 +61,53 @@ func InitIfNeeded() (*Store, error) {
 	}
 	dbPath := filepath.Join(dir, "pcapStore.db")
 
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+
 	_, statErr := os.Stat(dbPath)
-	if os.IsNotExist(statErr) {
-		db, err := openDB(dbPath)
-		if err != nil {
-			return nil, err
-		}
-		tx, err := db.Begin()
-		if err != nil {
-			_ = db.Close()
-			return nil, err
-		}
-		if _, err := tx.Exec(Schema); err != nil {
-			_ = tx.Rollback()
-			_ = db.Close()
-			return nil, fmt.Errorf("apply schema: %w", err)
-		}
-		if err := tx.Commit(); err != nil {
-			_ = db.Close()
-			return nil, err
-		}
-		return &Store{
-			Queries: sqlc.New(db),
-			db:      db,
-		}, nil
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
 	}
 
-	db, err := openDB(dbPath)
+	if os.IsNotExist(statErr) {
+		err = createSchema(db)
+	} else if !schemaReady[dbPath] {
+		err = migrate(db)
+	}
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
+	schemaReady[dbPath] = true
+
 	return &Store{
 		Queries: sqlc.New(db),
 		db:      db,
 	}, nil
 }
 
+// AnalysisParams holds everything the analyzer produced for one capture.
+// The CaptureID of Stats is filled in by the store.
+type AnalysisParams struct {
+	Stats sqlc.InsertCaptureStatsParams
+
+	// Persisters store results of analyzers with their own tables.
+	Persisters []Persister
+}
+
+// Persister matches capture.Persister.
+type Persister interface {
+	Persist(ctx context.Context, tx sqlc.DBTX, captureID int64) error
+}
+
+// InsertCaptureWithStats stores a new capture together with its analysis
+// results, metadata key/values and tags.
 func (s *Store) InsertCaptureWithStats(ctx context.Context,
 	captureParams sqlc.InsertCaptureParams,
-	statsParams sqlc.InsertCaptureStatsParams) (int64, error) {
+	analysis AnalysisParams,
+	metadata map[string]string,
+	tags []string) (int64, error) {
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -100,12 +122,30 @@ func (s *Store) InsertCaptureWithStats(ctx context.Context,
 		return 0, err
 	}
 
-	statsParams.CaptureID = captureID
-	err = q.InsertCaptureStats(ctx, statsParams)
-	if err != nil {
+	if err = insertAnalysis(ctx, tx, captureID, analysis); err != nil {
 		return 0, err
 	}
 
+	for key, value := range metadata {
+		if err = q.InsertCaptureMetadata(ctx, sqlc.InsertCaptureMetadataParams{
+			CaptureID: captureID,
+			Key:       key,
+			Value:     value,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, tag := range tags {
+		if err = q.AddCaptureTag(ctx, sqlc.AddCaptureTagParams{
+			CaptureID: captureID,
+			Tag:       tag,
+			CreatedAt: time.Now().UTC(),
+		}); err != nil {
+			return 0, err
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		return 0, err
 	}
@@ -113,6 +153,75 @@ func (s *Store) InsertCaptureWithStats(ctx context.Context,
 	return captureID, nil
 }
 
+// ReplaceCaptureAnalysis swaps the analysis rows of an existing capture for
+// freshly computed ones inside a single transaction.
+func (s *Store) ReplaceCaptureAnalysis(ctx context.Context,
+	captureID int64,
+	analysis AnalysisParams) error {
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := sqlc.New(tx)
+
+	// The built-in tables are cleared here rather than by their analyzers, so
+	// that one disabled since the last analysis leaves nothing behind.
+	if err := q.DeleteCaptureStats(ctx, captureID); err != nil {
+		return err
+	}
+	if err := q.DeleteCaptureFlows(ctx, captureID); err != nil {
+		return err
+	}
+	if err := q.DeleteCaptureDNS(ctx, captureID); err != nil {
+		return err
+	}
+	if err := q.DeleteCaptureTLS(ctx, captureID); err != nil {
+		return err
+	}
+	if err := q.DeleteCaptureHTTP(ctx, captureID); err != nil {
+		return err
+	}
+	if err := q.DeleteCaptureTimeline(ctx, captureID); err != nil {
+		return err
+	}
+	if err := q.DeleteCaptureFindings(ctx, captureID); err != nil {
+		return err
+	}
+
+	if err := insertAnalysis(ctx, tx, captureID, analysis); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertAnalysis(ctx context.Context, tx *sql.Tx, captureID int64, analysis AnalysisParams) error {
+	analysis.Stats.CaptureID = captureID
+	if err := sqlc.New(tx).InsertCaptureStats(ctx, analysis.Stats); err != nil {
+		return err
+	}
+
+	for _, persister := range analysis.Persisters {
+		if err := persister.Persist(ctx, tx, captureID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *Store) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 	return s.db.QueryContext(ctx, query, args...)
 }
+
+// IsUniqueViolation reports whether err is a unique constraint failure on
+// column, given as table.column.
+func IsUniqueViolation(err error, column string) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+		strings.Contains(sqliteErr.Error(), column)
+}
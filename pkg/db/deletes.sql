@@ -4,3 +4,34 @@
 DELETE FROM captures
 WHERE id = ?;
 
+-- name: DeleteCaptureStats :exec
+DELETE FROM capture_stats
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureFlows :exec
+DELETE FROM capture_flows
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureDNS :exec
+DELETE FROM capture_dns
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureTLS :exec
+DELETE FROM capture_tls
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureHTTP :exec
+DELETE FROM capture_http
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureTimeline :exec
+DELETE FROM capture_timeline
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureFindings :exec
+DELETE FROM capture_findings
+WHERE capture_id = ?;
+
+-- name: DeleteCaptureTag :execrows
+DELETE FROM capture_tags
+WHERE capture_id = ? AND tag = ?;
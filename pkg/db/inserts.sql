@@ -15,9 +15,20 @@ INSERT INTO capture_stats (
     avg_packet_size,
     duration_seconds,
     first_packet_time,
-    last_packet_time
+    last_packet_time,
+    tcp_syns,
+    tcp_syn_acks,
+    tcp_handshakes_completed,
+    tcp_resets,
+    tcp_fins,
+    tcp_duplicate_acks,
+    tcp_retransmissions,
+    tcp_out_of_order,
+    tcp_avg_handshake_rtt_ms,
+    tcp_max_handshake_rtt_ms,
+    analyzer_version
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 );
 
 -- name: InsertCapture :one
@@ -30,9 +41,179 @@ INSERT INTO captures (
     compressed,
     archived,
     created_at,
+    updated_at,
+    sha256,
+    corrupt,
+    corrupt_packet,
+    corrupt_error,
+    duplicate_of
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING id;
+
+-- name: InsertCaptureFlow :exec
+INSERT INTO capture_flows (
+    capture_id,
+    protocol,
+    src_ip,
+    src_port,
+    dst_ip,
+    dst_port,
+    packets_src_to_dst,
+    bytes_src_to_dst,
+    packets_dst_to_src,
+    bytes_dst_to_src,
+    tcp_flags,
+    handshake_rtt_ms,
+    first_seen,
+    last_seen
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: InsertCaptureDNS :exec
+INSERT INTO capture_dns (
+    capture_id,
+    query_time,
+    response_time,
+    client_ip,
+    server_ip,
+    transaction_id,
+    name,
+    record_type,
+    response_code,
+    answers
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: InsertCaptureTLS :exec
+INSERT INTO capture_tls (
+    capture_id,
+    client_ip,
+    client_port,
+    server_ip,
+    server_port,
+    seen_at,
+    sni,
+    client_version,
+    cipher_suites,
+    ja3,
+    ja3_hash,
+    version,
+    cipher_suite,
+    ja3s,
+    ja3s_hash
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: InsertCaptureHTTP :exec
+INSERT INTO capture_http (
+    capture_id,
+    client_ip,
+    client_port,
+    server_ip,
+    server_port,
+    request_time,
+    method,
+    host,
+    uri,
+    user_agent,
+    response_time,
+    status_code,
+    content_type
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: InsertCaptureTimelineBucket :exec
+INSERT INTO capture_timeline (
+    capture_id,
+    bucket_start,
+    protocol,
+    packets,
+    bytes
+) VALUES (
+    ?, ?, ?, ?, ?
+);
+
+-- name: InsertCaptureFinding :exec
+INSERT INTO capture_findings (
+    capture_id,
+    rule,
+    severity,
+    summary,
+    evidence,
+    first_seen
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+);
+
+-- name: InsertCaptureMetadata :exec
+INSERT INTO capture_metadata (
+    capture_id,
+    key,
+    value
+) VALUES (
+    ?, ?, ?
+);
+
+-- name: AddCaptureTag :exec
+INSERT OR IGNORE INTO capture_tags (
+    capture_id,
+    tag,
+    created_at
+) VALUES (
+    ?, ?, ?
+);
+
+-- name: InsertCaptureNote :one
+INSERT INTO capture_notes (
+    capture_id,
+    body,
+    created_at
+) VALUES (
+    ?, ?, ?
+)
+RETURNING *;
+
+-- name: InsertCaptureDuplicate :exec
+INSERT INTO capture_duplicates (
+    capture_id,
+    filename,
+    hostname,
+    scenario,
+    capture_datetime,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+);
+
+-- name: InsertIngestJob :one
+INSERT INTO ingest_jobs (
+    path,
+    status,
+    created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, 'queued', sqlc.arg(now), sqlc.arg(now)
 )
 RETURNING id;
 
+-- name: InsertRejection :one
+INSERT INTO rejections (
+    original_name,
+    original_dir,
+    quarantine_path,
+    sidecar_path,
+    reason,
+    detail,
+    capture_id,
+    status,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, 'quarantined', ?
+)
+RETURNING id;
@@ -0,0 +1,443 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// column is a column a migration adds to an existing table.
+type column struct {
+	table string
+	name  string
+	decl  string
+}
+
+// migration brings the schema from one user_version to the next. Databases
+// from before versioning are at 0 with whatever columns the build that
+// created them had, so a step must be safe on a schema that already has it:
+// columns are only added when missing and tables and indexes are created
+// IF NOT EXISTS.
+type migration struct {
+	columns []column
+	stmts   string
+}
+
+// migrations are applied in order; schema.sql is the result of all of them.
+// Append new steps, never change old ones.
+var migrations = []migration{
+	// 1: capture stats carry the analyzer version that produced them.
+	{columns: []column{
+		{"capture_stats", "analyzer_version", "integer not null default 1"},
+	}},
+	// 2: conversation flows per capture.
+	{
+		stmts: `
+			create table if not exists capture_flows (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    protocol text not null,
+			    src_ip text not null,
+			    src_port integer not null default 0,
+			    dst_ip text not null,
+			    dst_port integer not null default 0,
+			    packets_src_to_dst integer not null default 0,
+			    bytes_src_to_dst integer not null default 0,
+			    packets_dst_to_src integer not null default 0,
+			    bytes_dst_to_src integer not null default 0,
+			    tcp_flags text,
+			    first_seen datetime not null,
+			    last_seen datetime not null,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_flows_capture_id on capture_flows(capture_id);
+		`,
+	},
+	// 3: DNS queries per capture.
+	{
+		stmts: `
+			create table if not exists capture_dns (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    query_time datetime not null,
+			    response_time datetime,
+			    client_ip text not null,
+			    server_ip text not null,
+			    transaction_id integer not null,
+			    name text not null,
+			    record_type text not null,
+			    response_code text,
+			    answers text,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_dns_capture_id on capture_dns(capture_id);
+			create index if not exists idx_capture_dns_name on capture_dns(name);
+		`,
+	},
+	// 4: TLS hellos per capture.
+	{
+		stmts: `
+			create table if not exists capture_tls (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    client_ip text not null,
+			    client_port integer not null,
+			    server_ip text not null,
+			    server_port integer not null,
+			    seen_at datetime not null,
+			    sni text,
+			    client_version text,
+			    cipher_suites text,
+			    ja3 text,
+			    ja3_hash text,
+			    version text,
+			    cipher_suite text,
+			    ja3s text,
+			    ja3s_hash text,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_tls_capture_id on capture_tls(capture_id);
+			create index if not exists idx_capture_tls_sni on capture_tls(sni);
+		`,
+	},
+	// 5: HTTP transactions per capture.
+	{
+		stmts: `
+			create table if not exists capture_http (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    client_ip text not null,
+			    client_port integer not null,
+			    server_ip text not null,
+			    server_port integer not null,
+			    request_time datetime not null,
+			    method text,
+			    host text,
+			    uri text,
+			    user_agent text,
+			    response_time datetime,
+			    status_code integer,
+			    content_type text,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_http_capture_id on capture_http(capture_id);
+			create index if not exists idx_capture_http_host on capture_http(host);
+		`,
+	},
+	// 6: per-second traffic timeline.
+	{
+		stmts: `
+			create table if not exists capture_timeline (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    bucket_start datetime not null,
+			    protocol text not null,
+			    packets integer not null,
+			    bytes integer not null,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_timeline_capture_id on capture_timeline(capture_id);
+		`,
+	},
+	// 7: TCP health stats and per-flow handshake RTT.
+	{
+		columns: []column{
+			{"capture_stats", "tcp_syns", "integer"},
+			{"capture_stats", "tcp_syn_acks", "integer"},
+			{"capture_stats", "tcp_handshakes_completed", "integer"},
+			{"capture_stats", "tcp_resets", "integer"},
+			{"capture_stats", "tcp_fins", "integer"},
+			{"capture_stats", "tcp_duplicate_acks", "integer"},
+			{"capture_stats", "tcp_retransmissions", "integer"},
+			{"capture_stats", "tcp_out_of_order", "integer"},
+			{"capture_stats", "tcp_avg_handshake_rtt_ms", "real"},
+			{"capture_stats", "tcp_max_handshake_rtt_ms", "real"},
+			{"capture_flows", "handshake_rtt_ms", "real"},
+		},
+	},
+	// 8: detector findings.
+	{
+		stmts: `
+			create table if not exists capture_findings (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    rule text not null,
+			    severity text not null,
+			    summary text not null,
+			    evidence text not null,
+			    first_seen datetime,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_findings_capture_id on capture_findings(capture_id);
+		`,
+	},
+	// 9: analyzer toggles in the config.
+	{
+		columns: []column{
+			{"config", "analyzers", "text"},
+		},
+	},
+	// 10: anonymization key in the config.
+	{
+		columns: []column{
+			{"config", "anonymization_key", "text"},
+		},
+	},
+	// 11: filename patterns in the config.
+	{
+		columns: []column{
+			{"config", "filename_patterns", "text"},
+		},
+	},
+	// 12: sidecar metadata per capture.
+	{
+		columns: []column{
+			{"config", "sidecar_wait_seconds", "integer default 0"},
+		},
+		stmts: `
+			create table if not exists capture_metadata (
+			    capture_id integer not null,
+			    key text not null,
+			    value text not null,
+			    primary key(capture_id, key),
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_metadata_key on capture_metadata(key);
+		`,
+	},
+	// 13: user tags and notes.
+	{
+		stmts: `
+			create table if not exists capture_tags (
+			    capture_id integer not null,
+			    tag text not null,
+			    created_at datetime not null,
+			    primary key(capture_id, tag),
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create table if not exists capture_notes (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    body text not null,
+			    created_at datetime not null,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_capture_tags_tag on capture_tags(tag);
+			create index if not exists idx_capture_notes_capture_id on capture_notes(capture_id);
+		`,
+	},
+	// 14: content hashes and linked duplicates.
+	{
+		columns: []column{
+			{"captures", "sha256", "text"},
+			{"config", "duplicate_policy", "text"},
+		},
+		stmts: `
+			create table if not exists capture_duplicates (
+			    id integer primary key autoincrement,
+			    capture_id integer not null,
+			    filename text not null,
+			    hostname text not null,
+			    scenario text not null,
+			    capture_datetime datetime not null,
+			    created_at datetime not null,
+			    foreign key(capture_id) references captures(id) on delete cascade
+			);
+			create index if not exists idx_captures_sha256 on captures(sha256);
+			create index if not exists idx_capture_duplicates_capture_id on capture_duplicates(capture_id);
+		`,
+	},
+	// 15: ingest job queue.
+	{
+		columns: []column{
+			{"config", "ingest_workers", "integer"},
+		},
+		stmts: `
+			create table if not exists ingest_jobs (
+			    id integer primary key autoincrement,
+			    path text not null,
+			    status text not null,
+			    attempts integer not null default 0,
+			    last_error text,
+			    capture_id integer,
+			    next_attempt_at datetime,
+			    created_at datetime not null,
+			    updated_at datetime not null
+			);
+			create index if not exists idx_ingest_jobs_status on ingest_jobs(status);
+			create index if not exists idx_ingest_jobs_path on ingest_jobs(path);
+		`,
+	},
+	// 16: captures whose file is gone.
+	{
+		columns: []column{
+			{"captures", "file_missing", "boolean default 0"},
+		},
+	},
+	// 17: extra watch dirs in the config.
+	{
+		columns: []column{
+			{"config", "watch_dirs", "text"},
+		},
+	},
+	// 18: watch mode and quiet period in the config.
+	{
+		columns: []column{
+			{"config", "watch_mode", "text"},
+			{"config", "poll_interval_seconds", "integer"},
+			{"config", "quiet_seconds", "integer"},
+		},
+	},
+	// 19: quarantined files.
+	{
+		columns: []column{
+			{"config", "quarantine_dir", "text"},
+		},
+		stmts: `
+			create table if not exists rejections (
+			    id integer primary key autoincrement,
+			    original_name text not null,
+			    original_dir text not null,
+			    quarantine_path text not null,
+			    sidecar_path text,
+			    reason text not null,
+			    detail text,
+			    capture_id integer,
+			    status text not null,
+			    resubmitted_as text,
+			    created_at datetime not null,
+			    resolved_at datetime
+			);
+			create index if not exists idx_rejections_status on rejections(status);
+		`,
+	},
+	// 20: corrupt captures.
+	{
+		columns: []column{
+			{"captures", "corrupt", "boolean default 0"},
+			{"captures", "corrupt_packet", "integer"},
+			{"captures", "corrupt_error", "text"},
+		},
+	},
+	// 21: the sha256 index is unique, except for copies stored under
+	// duplicate_policy allow, which point at the first capture.
+	{
+		columns: []column{
+			{"captures", "duplicate_of", "integer"},
+		},
+		stmts: `
+			update captures
+			set duplicate_of = (select min(c.id) from captures c where c.sha256 = captures.sha256)
+			where sha256 is not null
+			  and id > (select min(c.id) from captures c where c.sha256 = captures.sha256);
+			drop index if exists idx_captures_sha256;
+			create unique index idx_captures_sha256 on captures(sha256) where sha256 is not null and duplicate_of is null;
+		`,
+	},
+	// 22: sidecar tags were stored comma separated as metadata; they are
+	// capture tags now.
+	{
+		stmts: `
+			insert or ignore into capture_tags (capture_id, tag, created_at)
+			with recursive split(capture_id, tag, rest) as (
+			    select capture_id, '', value || ',' from capture_metadata where key = 'tags'
+			    union all
+			    select capture_id, substr(rest, 1, instr(rest, ',') - 1), substr(rest, instr(rest, ',') + 1)
+			    from split where rest <> ''
+			)
+			select split.capture_id, trim(split.tag), coalesce(captures.created_at, current_timestamp)
+			from split join captures on captures.id = split.capture_id
+			where trim(split.tag) <> '';
+			delete from capture_metadata where key = 'tags';
+		`,
+	},
+	// 23: sidecar_wait_seconds = 0 no longer means unset. A 0 was never
+	// stored on purpose, only the column default, which new databases no
+	// longer have.
+	{
+		stmts: `
+			update config set sidecar_wait_seconds = null where sidecar_wait_seconds = 0;
+		`,
+	},
+}
+
+// createSchema applies schema.sql to a new database, which is then at the
+// latest version.
+func createSchema(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(Schema); err != nil {
+		return fmt.Errorf("apply schema: %w", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations))); err != nil {
+		return fmt.Errorf("set schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// migrate applies the migrations an existing database doesn't have yet, each
+// in its own transaction.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	for ; version < len(migrations); version++ {
+		if err := applyMigration(db, version+1, migrations[version]); err != nil {
+			return fmt.Errorf("migrate schema to version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, version int, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range m.columns {
+		exists, err := hasColumn(tx, c.table, c.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.name, c.decl)); err != nil {
+			return fmt.Errorf("add %s.%s: %w", c.table, c.name, err)
+		}
+	}
+	if m.stmts != "" {
+		if _, err := tx.Exec(m.stmts); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func hasColumn(tx *sql.Tx, table, name string) (bool, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return false, err
+		}
+		if col == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
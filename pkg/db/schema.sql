@@ -8,7 +8,13 @@ create table captures (
 	compressed boolean default 0,
 	archived boolean default 0,
 	created_at datetime default current_timestamp,
-	updated_at datetime default current_timestamp
+	updated_at datetime default current_timestamp,
+	sha256 text,                      -- of the uncompressed capture; null if it can't be read to the end
+	file_missing boolean default 0,   -- set by reconciliation when file_path is gone
+	corrupt boolean default 0,        -- only the packets before corrupt_packet were analyzed
+	corrupt_packet integer,           -- packet parsing stopped at, counting from 1; 0 if not known
+	corrupt_error text,
+	duplicate_of integer              -- stored again under duplicate_policy allow: the first capture with this sha256
 );
 
 create table capture_stats (
@@ -27,26 +33,221 @@ create table capture_stats (
     duration_seconds integer,
     first_packet_time datetime,
     last_packet_time datetime,
+    tcp_syns integer,
+    tcp_syn_acks integer,
+    tcp_handshakes_completed integer,
+    tcp_resets integer,
+    tcp_fins integer,
+    tcp_duplicate_acks integer,
+    tcp_retransmissions integer,
+    tcp_out_of_order integer,
+    tcp_avg_handshake_rtt_ms real,
+    tcp_max_handshake_rtt_ms real,
+    analyzer_version integer not null default 1,
     created_at datetime default current_timestamp,
     foreign key(capture_id) references captures(id) on delete cascade
 );
 
+create table capture_flows (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    protocol text not null,           -- TCP, UDP, ICMP, ...
+    src_ip text not null,             -- side that sent the first packet
+    src_port integer not null default 0,
+    dst_ip text not null,
+    dst_port integer not null default 0,
+    packets_src_to_dst integer not null default 0,
+    bytes_src_to_dst integer not null default 0,
+    packets_dst_to_src integer not null default 0,
+    bytes_dst_to_src integer not null default 0,
+    tcp_flags text,                   -- flags seen in either direction: "SYN,ACK,FIN"
+    handshake_rtt_ms real,            -- SYN to handshake ACK; null without a full handshake
+    first_seen datetime not null,
+    last_seen datetime not null,
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_dns (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    query_time datetime not null,
+    response_time datetime,           -- null if no response was captured
+    client_ip text not null,
+    server_ip text not null,
+    transaction_id integer not null,
+    name text not null,               -- lowercased, no trailing dot
+    record_type text not null,        -- A, AAAA, MX, ...
+    response_code text,               -- NOERROR, NXDOMAIN, ...; null if unanswered
+    answers text,                     -- json: ["A 93.184.216.34", "CNAME example.net"]
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_tls (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    client_ip text not null,
+    client_port integer not null,
+    server_ip text not null,
+    server_port integer not null,
+    seen_at datetime not null,        -- first hello of the connection
+    sni text,
+    client_version text,              -- highest version offered: "TLS 1.3"
+    cipher_suites text,               -- json: ["TLS_AES_128_GCM_SHA256", ...]
+    ja3 text,
+    ja3_hash text,
+    version text,                     -- negotiated version, from the ServerHello
+    cipher_suite text,                -- selected cipher suite
+    ja3s text,
+    ja3s_hash text,
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_http (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    client_ip text not null,
+    client_port integer not null,
+    server_ip text not null,
+    server_port integer not null,
+    request_time datetime not null,
+    method text,                      -- null if only the response was captured
+    host text,
+    uri text,
+    user_agent text,
+    response_time datetime,           -- null if no response was captured
+    status_code integer,
+    content_type text,                -- of the response
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_timeline (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    bucket_start datetime not null,   -- one second buckets
+    protocol text not null,           -- TCP, UDP, ICMP, ARP, Other, ...
+    packets integer not null,
+    bytes integer not null,
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_findings (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    rule text not null,               -- port_scan_vertical, syn_flood, ...
+    severity text not null,           -- low, medium, high
+    summary text not null,
+    evidence text not null,           -- JSON object
+    first_seen datetime,
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_metadata (
+    capture_id integer not null,
+    key text not null,                -- operator, interface, ... or a custom key
+    value text not null,
+    primary key(capture_id, key),
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_tags (
+    capture_id integer not null,
+    tag text not null,
+    created_at datetime not null,
+    primary key(capture_id, tag),
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_notes (
+    id integer primary key autoincrement,
+    capture_id integer not null,
+    body text not null,
+    created_at datetime not null,
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table capture_duplicates (
+    id integer primary key autoincrement,
+    capture_id integer not null,      -- the capture with the same content
+    filename text not null,
+    hostname text not null,
+    scenario text not null,
+    capture_datetime datetime not null,
+    created_at datetime not null,
+    foreign key(capture_id) references captures(id) on delete cascade
+);
+
+create table ingest_jobs (
+    id integer primary key autoincrement,
+    path text not null,
+    status text not null,             -- queued, running, done, failed
+    attempts integer not null default 0,
+    last_error text,
+    capture_id integer,               -- the stored capture once done
+    next_attempt_at datetime,         -- backoff of queued retries
+    created_at datetime not null,
+    updated_at datetime not null
+);
+
+create table rejections (
+    id integer primary key autoincrement,
+    original_name text not null,
+    original_dir text not null,       -- the watch dir it was found in
+    quarantine_path text not null,
+    sidecar_path text,                -- its quarantined sidecar
+    reason text not null,             -- invalid_name, invalid_sidecar, duplicate, corrupt, orphaned_sidecar
+    detail text,
+    capture_id integer,               -- duplicate: the capture with the same content
+    status text not null,             -- quarantined, resubmitted
+    resubmitted_as text,
+    created_at datetime not null,
+    resolved_at datetime
+);
+
 create table config (
 	watch_dir text default './data/captures/incoming',
 	organized_dir text default './data/captures/organized',
 	archive_dir text default './data/captures/archive',
+	quarantine_dir text,              -- rejected files, default ./data/captures/quarantine
 	expose_service boolean default 0,
 	port integer default 13173 check(port >= 1024 and port <= 65536),
 	compression_enabled boolean default 1,
 	archive_days integer default 30,
 	max_retention_days integer default 90,
-	log_level text default 'info'
+	log_level text default 'info',
+	watch_dirs text,                  -- JSON array of extra watched directories
+	watch_mode text,                  -- notify, poll or both
+	poll_interval_seconds integer,
+	quiet_seconds integer,
+	analyzers text,                   -- JSON object of analyzer name -> enabled
+	anonymization_key text,           -- secret behind anonymized downloads
+	filename_patterns text,           -- JSON array of extra filename patterns
+	sidecar_wait_seconds integer,
+	duplicate_policy text,            -- reject, link or allow
+	ingest_workers integer
 );
 
 create index idx_captures_hostname on captures(hostname);
 create index idx_captures_scenario on captures(scenario);
 create index idx_captures_datetime on captures(capture_datetime);
 create index idx_captures_archived on captures(archived);
+-- Workers ingest concurrently, so the store itself keeps a content from being stored twice.
+create unique index idx_captures_sha256 on captures(sha256) where sha256 is not null and duplicate_of is null;
 create index idx_capture_stats_capture_id on capture_stats(capture_id);
+create index idx_capture_flows_capture_id on capture_flows(capture_id);
+create index idx_capture_dns_capture_id on capture_dns(capture_id);
+create index idx_capture_dns_name on capture_dns(name);
+create index idx_capture_tls_capture_id on capture_tls(capture_id);
+create index idx_capture_tls_sni on capture_tls(sni);
+create index idx_capture_http_capture_id on capture_http(capture_id);
+create index idx_capture_http_host on capture_http(host);
+create index idx_capture_timeline_capture_id on capture_timeline(capture_id);
+create index idx_capture_findings_capture_id on capture_findings(capture_id);
+create index idx_capture_metadata_key on capture_metadata(key);
+create index idx_capture_tags_tag on capture_tags(tag);
+create index idx_capture_notes_capture_id on capture_notes(capture_id);
+create index idx_capture_duplicates_capture_id on capture_duplicates(capture_id);
+create index idx_ingest_jobs_status on ingest_jobs(status);
+create index idx_ingest_jobs_path on ingest_jobs(path);
+create index idx_rejections_status on rejections(status);
 
 insert or ignore into config default values;
@@ -42,7 +42,7 @@ SELECT * FROM captures WHERE archived = 1;
 SELECT id, file_path, file_size, created_at, updated_at FROM captures WHERE archived = 1;
 
 -- name: GetCaptureStatsByID :one
-SELECT cs.id, cs.packet_count, cs.capture_id, cs.protocol_distribution, cs.top_src_ips, cs.top_dst_ips, cs.top_tcp_src_ports, cs.top_tcp_dst_ports, cs.top_udp_src_ports, cs.top_udp_dst_ports, cs.packet_rate, cs.avg_packet_size, cs.duration_seconds, cs.first_packet_time, cs.last_packet_time, cs.created_at, c.hostname, c.scenario, c.capture_datetime, c.file_path
+SELECT cs.id, cs.packet_count, cs.capture_id, cs.protocol_distribution, cs.top_src_ips, cs.top_dst_ips, cs.top_tcp_src_ports, cs.top_tcp_dst_ports, cs.top_udp_src_ports, cs.top_udp_dst_ports, cs.packet_rate, cs.avg_packet_size, cs.duration_seconds, cs.first_packet_time, cs.last_packet_time, cs.tcp_syns, cs.tcp_syn_acks, cs.tcp_handshakes_completed, cs.tcp_resets, cs.tcp_fins, cs.tcp_duplicate_acks, cs.tcp_retransmissions, cs.tcp_out_of_order, cs.tcp_avg_handshake_rtt_ms, cs.tcp_max_handshake_rtt_ms, cs.analyzer_version, cs.created_at, c.hostname, c.scenario, c.capture_datetime, c.file_path
 FROM captures c
 LEFT JOIN capture_stats cs ON c.id = cs.capture_id
 WHERE c.id = ?;
@@ -94,3 +94,149 @@ SELECT * FROM captures WHERE hostname = ? ORDER BY capture_datetime DESC;
 
 -- name: GetCapturesByScenario :many
 SELECT * FROM captures WHERE scenario = ? ORDER BY capture_datetime DESC;
+
+-- name: GetCapturesForReanalysis :many
+SELECT c.id, c.file_path
+FROM captures c
+LEFT JOIN capture_stats cs ON c.id = cs.capture_id
+WHERE cs.id IS NULL OR cs.analyzer_version < ?
+ORDER BY c.id ASC;
+
+-- name: GetCaptureFlows :many
+SELECT * FROM capture_flows
+WHERE capture_id = ?
+ORDER BY first_seen, id;
+
+-- name: SearchDNSByName :many
+SELECT
+    d.capture_id,
+    c.hostname,
+    c.scenario,
+    c.capture_datetime,
+    d.query_time,
+    d.response_time,
+    d.client_ip,
+    d.server_ip,
+    d.name,
+    d.record_type,
+    d.response_code,
+    d.answers
+FROM capture_dns d
+JOIN captures c ON c.id = d.capture_id
+WHERE d.name LIKE ? ESCAPE '\'
+ORDER BY c.capture_datetime DESC, d.query_time ASC;
+
+-- name: SearchDNSByExactName :many
+SELECT
+    d.capture_id,
+    c.hostname,
+    c.scenario,
+    c.capture_datetime,
+    d.query_time,
+    d.response_time,
+    d.client_ip,
+    d.server_ip,
+    d.name,
+    d.record_type,
+    d.response_code,
+    d.answers
+FROM capture_dns d
+JOIN captures c ON c.id = d.capture_id
+WHERE d.name = ?
+ORDER BY c.capture_datetime DESC, d.query_time ASC;
+
+-- name: GetCaptureTLS :many
+SELECT * FROM capture_tls
+WHERE capture_id = ?
+ORDER BY seen_at, id;
+
+-- name: GetCaptureIDsBySNI :many
+SELECT DISTINCT capture_id FROM capture_tls
+WHERE sni LIKE ? ESCAPE '\';
+
+-- name: GetCaptureHTTP :many
+SELECT * FROM capture_http
+WHERE capture_id = ?
+ORDER BY request_time, id;
+
+-- name: GetCaptureIDsByHTTP :many
+SELECT DISTINCT capture_id FROM capture_http
+WHERE (coalesce(host, '') LIKE ? ESCAPE '\')
+  AND (coalesce(uri, '') LIKE ? ESCAPE '\');
+
+-- name: GetCaptureTimeline :many
+SELECT bucket_start, protocol, packets, bytes FROM capture_timeline
+WHERE capture_id = ?
+ORDER BY bucket_start, protocol;
+
+-- name: GetCaptureFindings :many
+SELECT * FROM capture_findings
+WHERE capture_id = ?
+ORDER BY first_seen, id;
+
+-- name: GetCaptureMetadata :many
+SELECT key, value FROM capture_metadata
+WHERE capture_id = ?
+ORDER BY key;
+
+-- name: GetAllCaptureMetadata :many
+SELECT * FROM capture_metadata
+ORDER BY capture_id, key;
+
+-- name: GetCaptureTags :many
+SELECT tag FROM capture_tags
+WHERE capture_id = ?
+ORDER BY tag;
+
+-- name: GetAllCaptureTags :many
+SELECT capture_id, tag FROM capture_tags
+ORDER BY capture_id, tag;
+
+-- name: GetCaptureNotes :many
+SELECT * FROM capture_notes
+WHERE capture_id = ?
+ORDER BY created_at, id;
+
+-- name: GetCaptureIDBySHA256 :one
+SELECT id FROM captures
+WHERE sha256 = ? AND duplicate_of IS NULL
+ORDER BY id
+LIMIT 1;
+
+-- name: GetCaptureDuplicates :many
+SELECT * FROM capture_duplicates
+WHERE capture_id = ?
+ORDER BY created_at, id;
+
+-- name: GetActiveIngestJobByPath :one
+SELECT id FROM ingest_jobs
+WHERE path = ? AND status IN ('queued', 'running')
+LIMIT 1;
+
+-- name: ListIngestJobs :many
+SELECT * FROM ingest_jobs
+ORDER BY id DESC;
+
+-- name: ListRejections :many
+SELECT * FROM rejections
+ORDER BY id DESC;
+
+-- name: GetRejection :one
+SELECT * FROM rejections
+WHERE id = ?;
+
+-- name: ListFindings :many
+SELECT
+    f.id,
+    f.capture_id,
+    c.hostname,
+    c.scenario,
+    c.capture_datetime,
+    f.rule,
+    f.severity,
+    f.summary,
+    f.evidence,
+    f.first_seen
+FROM capture_findings f
+JOIN captures c ON c.id = f.capture_id
+ORDER BY c.capture_datetime DESC, f.first_seen ASC, f.id ASC;
@@ -12,7 +12,7 @@ import (
 
 const getConfig = `-- name: GetConfig :one
 
-SELECT watch_dir, organized_dir, archive_dir, expose_service, port, compression_enabled, archive_days, max_retention_days, log_level FROM config LIMIT 1
+SELECT watch_dir, organized_dir, archive_dir, quarantine_dir, expose_service, port, compression_enabled, archive_days, max_retention_days, log_level, watch_dirs, watch_mode, poll_interval_seconds, quiet_seconds, analyzers, anonymization_key, filename_patterns, sidecar_wait_seconds, duplicate_policy, ingest_workers FROM config LIMIT 1
 `
 
 // Config queries
@@ -23,12 +23,23 @@ func (q *Queries) GetConfig(ctx context.Context) (Config, error) {
 		&i.WatchDir,
 		&i.OrganizedDir,
 		&i.ArchiveDir,
+		&i.QuarantineDir,
 		&i.ExposeService,
 		&i.Port,
 		&i.CompressionEnabled,
 		&i.ArchiveDays,
 		&i.MaxRetentionDays,
 		&i.LogLevel,
+		&i.WatchDirs,
+		&i.WatchMode,
+		&i.PollIntervalSeconds,
+		&i.QuietSeconds,
+		&i.Analyzers,
+		&i.AnonymizationKey,
+		&i.FilenamePatterns,
+		&i.SidecarWaitSeconds,
+		&i.DuplicatePolicy,
+		&i.IngestWorkers,
 	)
 	return i, err
 }
@@ -38,24 +49,46 @@ UPDATE config
 SET watch_dir = ?,
 organized_dir = ?,
 archive_dir = ?,
+quarantine_dir = ?,
 expose_service = ?,
 port = ?,
 compression_enabled = ?,
 archive_days = ?,
 max_retention_days = ?,
-log_level = ?
+log_level = ?,
+watch_dirs = ?,
+watch_mode = ?,
+poll_interval_seconds = ?,
+quiet_seconds = ?,
+analyzers = ?,
+anonymization_key = ?,
+filename_patterns = ?,
+sidecar_wait_seconds = ?,
+duplicate_policy = ?,
+ingest_workers = ?
 `
 
 type UpdateConfigParams struct {
-	WatchDir           sql.NullString
-	OrganizedDir       sql.NullString
-	ArchiveDir         sql.NullString
-	ExposeService      sql.NullBool
-	Port               sql.NullInt64
-	CompressionEnabled sql.NullBool
-	ArchiveDays        sql.NullInt64
-	MaxRetentionDays   sql.NullInt64
-	LogLevel           sql.NullString
+	WatchDir            sql.NullString
+	OrganizedDir        sql.NullString
+	ArchiveDir          sql.NullString
+	QuarantineDir       sql.NullString
+	ExposeService       sql.NullBool
+	Port                sql.NullInt64
+	CompressionEnabled  sql.NullBool
+	ArchiveDays         sql.NullInt64
+	MaxRetentionDays    sql.NullInt64
+	LogLevel            sql.NullString
+	WatchDirs           sql.NullString
+	WatchMode           sql.NullString
+	PollIntervalSeconds sql.NullInt64
+	QuietSeconds        sql.NullInt64
+	Analyzers           sql.NullString
+	AnonymizationKey    sql.NullString
+	FilenamePatterns    sql.NullString
+	SidecarWaitSeconds  sql.NullInt64
+	DuplicatePolicy     sql.NullString
+	IngestWorkers       sql.NullInt64
 }
 
 func (q *Queries) UpdateConfig(ctx context.Context, arg UpdateConfigParams) error {
@@ -63,12 +96,23 @@ func (q *Queries) UpdateConfig(ctx context.Context, arg UpdateConfigParams) erro
 		arg.WatchDir,
 		arg.OrganizedDir,
 		arg.ArchiveDir,
+		arg.QuarantineDir,
 		arg.ExposeService,
 		arg.Port,
 		arg.CompressionEnabled,
 		arg.ArchiveDays,
 		arg.MaxRetentionDays,
 		arg.LogLevel,
+		arg.WatchDirs,
+		arg.WatchMode,
+		arg.PollIntervalSeconds,
+		arg.QuietSeconds,
+		arg.Analyzers,
+		arg.AnonymizationKey,
+		arg.FilenamePatterns,
+		arg.SidecarWaitSeconds,
+		arg.DuplicatePolicy,
+		arg.IngestWorkers,
 	)
 	return err
 }
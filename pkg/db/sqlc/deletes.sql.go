@@ -20,3 +20,91 @@ func (q *Queries) DeleteCapture(ctx context.Context, id int64) error {
 	_, err := q.db.ExecContext(ctx, deleteCapture, id)
 	return err
 }
+
+const deleteCaptureDNS = `-- name: DeleteCaptureDNS :exec
+DELETE FROM capture_dns
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureDNS(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureDNS, captureID)
+	return err
+}
+
+const deleteCaptureFindings = `-- name: DeleteCaptureFindings :exec
+DELETE FROM capture_findings
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureFindings(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureFindings, captureID)
+	return err
+}
+
+const deleteCaptureFlows = `-- name: DeleteCaptureFlows :exec
+DELETE FROM capture_flows
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureFlows(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureFlows, captureID)
+	return err
+}
+
+const deleteCaptureHTTP = `-- name: DeleteCaptureHTTP :exec
+DELETE FROM capture_http
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureHTTP(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureHTTP, captureID)
+	return err
+}
+
+const deleteCaptureStats = `-- name: DeleteCaptureStats :exec
+DELETE FROM capture_stats
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureStats(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureStats, captureID)
+	return err
+}
+
+const deleteCaptureTLS = `-- name: DeleteCaptureTLS :exec
+DELETE FROM capture_tls
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureTLS(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureTLS, captureID)
+	return err
+}
+
+const deleteCaptureTag = `-- name: DeleteCaptureTag :execrows
+DELETE FROM capture_tags
+WHERE capture_id = ? AND tag = ?
+`
+
+type DeleteCaptureTagParams struct {
+	CaptureID int64
+	Tag       string
+}
+
+func (q *Queries) DeleteCaptureTag(ctx context.Context, arg DeleteCaptureTagParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCaptureTag, arg.CaptureID, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteCaptureTimeline = `-- name: DeleteCaptureTimeline :exec
+DELETE FROM capture_timeline
+WHERE capture_id = ?
+`
+
+func (q *Queries) DeleteCaptureTimeline(ctx context.Context, captureID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCaptureTimeline, captureID)
+	return err
+}
@@ -11,6 +11,27 @@ import (
 	"time"
 )
 
+const addCaptureTag = `-- name: AddCaptureTag :exec
+INSERT OR IGNORE INTO capture_tags (
+    capture_id,
+    tag,
+    created_at
+) VALUES (
+    ?, ?, ?
+)
+`
+
+type AddCaptureTagParams struct {
+	CaptureID int64
+	Tag       string
+	CreatedAt time.Time
+}
+
+func (q *Queries) AddCaptureTag(ctx context.Context, arg AddCaptureTagParams) error {
+	_, err := q.db.ExecContext(ctx, addCaptureTag, arg.CaptureID, arg.Tag, arg.CreatedAt)
+	return err
+}
+
 const insertCapture = `-- name: InsertCapture :one
 INSERT INTO captures (
     hostname,
@@ -21,9 +42,14 @@ INSERT INTO captures (
     compressed,
     archived,
     created_at,
-    updated_at
+    updated_at,
+    sha256,
+    corrupt,
+    corrupt_packet,
+    corrupt_error,
+    duplicate_of
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 RETURNING id
 `
@@ -38,6 +64,11 @@ type InsertCaptureParams struct {
 	Archived        sql.NullBool
 	CreatedAt       sql.NullTime
 	UpdatedAt       sql.NullTime
+	Sha256          sql.NullString
+	Corrupt         sql.NullBool
+	CorruptPacket   sql.NullInt64
+	CorruptError    sql.NullString
+	DuplicateOf     sql.NullInt64
 }
 
 func (q *Queries) InsertCapture(ctx context.Context, arg InsertCaptureParams) (int64, error) {
@@ -51,12 +82,294 @@ func (q *Queries) InsertCapture(ctx context.Context, arg InsertCaptureParams) (i
 		arg.Archived,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.Sha256,
+		arg.Corrupt,
+		arg.CorruptPacket,
+		arg.CorruptError,
+		arg.DuplicateOf,
 	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
+const insertCaptureDNS = `-- name: InsertCaptureDNS :exec
+INSERT INTO capture_dns (
+    capture_id,
+    query_time,
+    response_time,
+    client_ip,
+    server_ip,
+    transaction_id,
+    name,
+    record_type,
+    response_code,
+    answers
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureDNSParams struct {
+	CaptureID     int64
+	QueryTime     time.Time
+	ResponseTime  sql.NullTime
+	ClientIp      string
+	ServerIp      string
+	TransactionID int64
+	Name          string
+	RecordType    string
+	ResponseCode  sql.NullString
+	Answers       sql.NullString
+}
+
+func (q *Queries) InsertCaptureDNS(ctx context.Context, arg InsertCaptureDNSParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureDNS,
+		arg.CaptureID,
+		arg.QueryTime,
+		arg.ResponseTime,
+		arg.ClientIp,
+		arg.ServerIp,
+		arg.TransactionID,
+		arg.Name,
+		arg.RecordType,
+		arg.ResponseCode,
+		arg.Answers,
+	)
+	return err
+}
+
+const insertCaptureDuplicate = `-- name: InsertCaptureDuplicate :exec
+INSERT INTO capture_duplicates (
+    capture_id,
+    filename,
+    hostname,
+    scenario,
+    capture_datetime,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureDuplicateParams struct {
+	CaptureID       int64
+	Filename        string
+	Hostname        string
+	Scenario        string
+	CaptureDatetime time.Time
+	CreatedAt       time.Time
+}
+
+func (q *Queries) InsertCaptureDuplicate(ctx context.Context, arg InsertCaptureDuplicateParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureDuplicate,
+		arg.CaptureID,
+		arg.Filename,
+		arg.Hostname,
+		arg.Scenario,
+		arg.CaptureDatetime,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const insertCaptureFinding = `-- name: InsertCaptureFinding :exec
+INSERT INTO capture_findings (
+    capture_id,
+    rule,
+    severity,
+    summary,
+    evidence,
+    first_seen
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureFindingParams struct {
+	CaptureID int64
+	Rule      string
+	Severity  string
+	Summary   string
+	Evidence  string
+	FirstSeen sql.NullTime
+}
+
+func (q *Queries) InsertCaptureFinding(ctx context.Context, arg InsertCaptureFindingParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureFinding,
+		arg.CaptureID,
+		arg.Rule,
+		arg.Severity,
+		arg.Summary,
+		arg.Evidence,
+		arg.FirstSeen,
+	)
+	return err
+}
+
+const insertCaptureFlow = `-- name: InsertCaptureFlow :exec
+INSERT INTO capture_flows (
+    capture_id,
+    protocol,
+    src_ip,
+    src_port,
+    dst_ip,
+    dst_port,
+    packets_src_to_dst,
+    bytes_src_to_dst,
+    packets_dst_to_src,
+    bytes_dst_to_src,
+    tcp_flags,
+    handshake_rtt_ms,
+    first_seen,
+    last_seen
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureFlowParams struct {
+	CaptureID       int64
+	Protocol        string
+	SrcIp           string
+	SrcPort         int64
+	DstIp           string
+	DstPort         int64
+	PacketsSrcToDst int64
+	BytesSrcToDst   int64
+	PacketsDstToSrc int64
+	BytesDstToSrc   int64
+	TcpFlags        sql.NullString
+	HandshakeRttMs  sql.NullFloat64
+	FirstSeen       time.Time
+	LastSeen        time.Time
+}
+
+func (q *Queries) InsertCaptureFlow(ctx context.Context, arg InsertCaptureFlowParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureFlow,
+		arg.CaptureID,
+		arg.Protocol,
+		arg.SrcIp,
+		arg.SrcPort,
+		arg.DstIp,
+		arg.DstPort,
+		arg.PacketsSrcToDst,
+		arg.BytesSrcToDst,
+		arg.PacketsDstToSrc,
+		arg.BytesDstToSrc,
+		arg.TcpFlags,
+		arg.HandshakeRttMs,
+		arg.FirstSeen,
+		arg.LastSeen,
+	)
+	return err
+}
+
+const insertCaptureHTTP = `-- name: InsertCaptureHTTP :exec
+INSERT INTO capture_http (
+    capture_id,
+    client_ip,
+    client_port,
+    server_ip,
+    server_port,
+    request_time,
+    method,
+    host,
+    uri,
+    user_agent,
+    response_time,
+    status_code,
+    content_type
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureHTTPParams struct {
+	CaptureID    int64
+	ClientIp     string
+	ClientPort   int64
+	ServerIp     string
+	ServerPort   int64
+	RequestTime  time.Time
+	Method       sql.NullString
+	Host         sql.NullString
+	Uri          sql.NullString
+	UserAgent    sql.NullString
+	ResponseTime sql.NullTime
+	StatusCode   sql.NullInt64
+	ContentType  sql.NullString
+}
+
+func (q *Queries) InsertCaptureHTTP(ctx context.Context, arg InsertCaptureHTTPParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureHTTP,
+		arg.CaptureID,
+		arg.ClientIp,
+		arg.ClientPort,
+		arg.ServerIp,
+		arg.ServerPort,
+		arg.RequestTime,
+		arg.Method,
+		arg.Host,
+		arg.Uri,
+		arg.UserAgent,
+		arg.ResponseTime,
+		arg.StatusCode,
+		arg.ContentType,
+	)
+	return err
+}
+
+const insertCaptureMetadata = `-- name: InsertCaptureMetadata :exec
+INSERT INTO capture_metadata (
+    capture_id,
+    key,
+    value
+) VALUES (
+    ?, ?, ?
+)
+`
+
+type InsertCaptureMetadataParams struct {
+	CaptureID int64
+	Key       string
+	Value     string
+}
+
+func (q *Queries) InsertCaptureMetadata(ctx context.Context, arg InsertCaptureMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureMetadata, arg.CaptureID, arg.Key, arg.Value)
+	return err
+}
+
+const insertCaptureNote = `-- name: InsertCaptureNote :one
+INSERT INTO capture_notes (
+    capture_id,
+    body,
+    created_at
+) VALUES (
+    ?, ?, ?
+)
+RETURNING id, capture_id, body, created_at
+`
+
+type InsertCaptureNoteParams struct {
+	CaptureID int64
+	Body      string
+	CreatedAt time.Time
+}
+
+func (q *Queries) InsertCaptureNote(ctx context.Context, arg InsertCaptureNoteParams) (CaptureNote, error) {
+	row := q.db.QueryRowContext(ctx, insertCaptureNote, arg.CaptureID, arg.Body, arg.CreatedAt)
+	var i CaptureNote
+	err := row.Scan(
+		&i.ID,
+		&i.CaptureID,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const insertCaptureStats = `-- name: InsertCaptureStats :exec
 
 INSERT INTO capture_stats (
@@ -73,27 +386,49 @@ INSERT INTO capture_stats (
     avg_packet_size,
     duration_seconds,
     first_packet_time,
-    last_packet_time
+    last_packet_time,
+    tcp_syns,
+    tcp_syn_acks,
+    tcp_handshakes_completed,
+    tcp_resets,
+    tcp_fins,
+    tcp_duplicate_acks,
+    tcp_retransmissions,
+    tcp_out_of_order,
+    tcp_avg_handshake_rtt_ms,
+    tcp_max_handshake_rtt_ms,
+    analyzer_version
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 `
 
 type InsertCaptureStatsParams struct {
-	CaptureID            int64
-	PacketCount          sql.NullInt64
-	ProtocolDistribution sql.NullString
-	TopSrcIps            sql.NullString
-	TopDstIps            sql.NullString
-	TopTcpSrcPorts       sql.NullString
-	TopTcpDstPorts       sql.NullString
-	TopUdpSrcPorts       sql.NullString
-	TopUdpDstPorts       sql.NullString
-	PacketRate           sql.NullFloat64
-	AvgPacketSize        sql.NullFloat64
-	DurationSeconds      sql.NullInt64
-	FirstPacketTime      sql.NullTime
-	LastPacketTime       sql.NullTime
+	CaptureID              int64
+	PacketCount            sql.NullInt64
+	ProtocolDistribution   sql.NullString
+	TopSrcIps              sql.NullString
+	TopDstIps              sql.NullString
+	TopTcpSrcPorts         sql.NullString
+	TopTcpDstPorts         sql.NullString
+	TopUdpSrcPorts         sql.NullString
+	TopUdpDstPorts         sql.NullString
+	PacketRate             sql.NullFloat64
+	AvgPacketSize          sql.NullFloat64
+	DurationSeconds        sql.NullInt64
+	FirstPacketTime        sql.NullTime
+	LastPacketTime         sql.NullTime
+	TcpSyns                sql.NullInt64
+	TcpSynAcks             sql.NullInt64
+	TcpHandshakesCompleted sql.NullInt64
+	TcpResets              sql.NullInt64
+	TcpFins                sql.NullInt64
+	TcpDuplicateAcks       sql.NullInt64
+	TcpRetransmissions     sql.NullInt64
+	TcpOutOfOrder          sql.NullInt64
+	TcpAvgHandshakeRttMs   sql.NullFloat64
+	TcpMaxHandshakeRttMs   sql.NullFloat64
+	AnalyzerVersion        int64
 }
 
 // Insert queries
@@ -113,6 +448,177 @@ func (q *Queries) InsertCaptureStats(ctx context.Context, arg InsertCaptureStats
 		arg.DurationSeconds,
 		arg.FirstPacketTime,
 		arg.LastPacketTime,
+		arg.TcpSyns,
+		arg.TcpSynAcks,
+		arg.TcpHandshakesCompleted,
+		arg.TcpResets,
+		arg.TcpFins,
+		arg.TcpDuplicateAcks,
+		arg.TcpRetransmissions,
+		arg.TcpOutOfOrder,
+		arg.TcpAvgHandshakeRttMs,
+		arg.TcpMaxHandshakeRttMs,
+		arg.AnalyzerVersion,
+	)
+	return err
+}
+
+const insertCaptureTLS = `-- name: InsertCaptureTLS :exec
+INSERT INTO capture_tls (
+    capture_id,
+    client_ip,
+    client_port,
+    server_ip,
+    server_port,
+    seen_at,
+    sni,
+    client_version,
+    cipher_suites,
+    ja3,
+    ja3_hash,
+    version,
+    cipher_suite,
+    ja3s,
+    ja3s_hash
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureTLSParams struct {
+	CaptureID     int64
+	ClientIp      string
+	ClientPort    int64
+	ServerIp      string
+	ServerPort    int64
+	SeenAt        time.Time
+	Sni           sql.NullString
+	ClientVersion sql.NullString
+	CipherSuites  sql.NullString
+	Ja3           sql.NullString
+	Ja3Hash       sql.NullString
+	Version       sql.NullString
+	CipherSuite   sql.NullString
+	Ja3s          sql.NullString
+	Ja3sHash      sql.NullString
+}
+
+func (q *Queries) InsertCaptureTLS(ctx context.Context, arg InsertCaptureTLSParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureTLS,
+		arg.CaptureID,
+		arg.ClientIp,
+		arg.ClientPort,
+		arg.ServerIp,
+		arg.ServerPort,
+		arg.SeenAt,
+		arg.Sni,
+		arg.ClientVersion,
+		arg.CipherSuites,
+		arg.Ja3,
+		arg.Ja3Hash,
+		arg.Version,
+		arg.CipherSuite,
+		arg.Ja3s,
+		arg.Ja3sHash,
 	)
 	return err
 }
+
+const insertCaptureTimelineBucket = `-- name: InsertCaptureTimelineBucket :exec
+INSERT INTO capture_timeline (
+    capture_id,
+    bucket_start,
+    protocol,
+    packets,
+    bytes
+) VALUES (
+    ?, ?, ?, ?, ?
+)
+`
+
+type InsertCaptureTimelineBucketParams struct {
+	CaptureID   int64
+	BucketStart time.Time
+	Protocol    string
+	Packets     int64
+	Bytes       int64
+}
+
+func (q *Queries) InsertCaptureTimelineBucket(ctx context.Context, arg InsertCaptureTimelineBucketParams) error {
+	_, err := q.db.ExecContext(ctx, insertCaptureTimelineBucket,
+		arg.CaptureID,
+		arg.BucketStart,
+		arg.Protocol,
+		arg.Packets,
+		arg.Bytes,
+	)
+	return err
+}
+
+const insertIngestJob = `-- name: InsertIngestJob :one
+INSERT INTO ingest_jobs (
+    path,
+    status,
+    created_at,
+    updated_at
+) VALUES (
+    ?, 'queued', ?2, ?2
+)
+RETURNING id
+`
+
+type InsertIngestJobParams struct {
+	Path string
+	Now  time.Time
+}
+
+func (q *Queries) InsertIngestJob(ctx context.Context, arg InsertIngestJobParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertIngestJob, arg.Path, arg.Now)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertRejection = `-- name: InsertRejection :one
+INSERT INTO rejections (
+    original_name,
+    original_dir,
+    quarantine_path,
+    sidecar_path,
+    reason,
+    detail,
+    capture_id,
+    status,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, 'quarantined', ?
+)
+RETURNING id
+`
+
+type InsertRejectionParams struct {
+	OriginalName   string
+	OriginalDir    string
+	QuarantinePath string
+	SidecarPath    sql.NullString
+	Reason         string
+	Detail         sql.NullString
+	CaptureID      sql.NullInt64
+	CreatedAt      time.Time
+}
+
+func (q *Queries) InsertRejection(ctx context.Context, arg InsertRejectionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertRejection,
+		arg.OriginalName,
+		arg.OriginalDir,
+		arg.QuarantinePath,
+		arg.SidecarPath,
+		arg.Reason,
+		arg.Detail,
+		arg.CaptureID,
+		arg.CreatedAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
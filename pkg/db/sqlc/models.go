@@ -20,35 +20,206 @@ type Capture struct {
 	Archived        sql.NullBool
 	CreatedAt       sql.NullTime
 	UpdatedAt       sql.NullTime
+	Sha256          sql.NullString
+	FileMissing     sql.NullBool
+	Corrupt         sql.NullBool
+	CorruptPacket   sql.NullInt64
+	CorruptError    sql.NullString
+	DuplicateOf     sql.NullInt64
+}
+
+type CaptureDn struct {
+	ID            int64
+	CaptureID     int64
+	QueryTime     time.Time
+	ResponseTime  sql.NullTime
+	ClientIp      string
+	ServerIp      string
+	TransactionID int64
+	Name          string
+	RecordType    string
+	ResponseCode  sql.NullString
+	Answers       sql.NullString
+}
+
+type CaptureDuplicate struct {
+	ID              int64
+	CaptureID       int64
+	Filename        string
+	Hostname        string
+	Scenario        string
+	CaptureDatetime time.Time
+	CreatedAt       time.Time
+}
+
+type CaptureFinding struct {
+	ID        int64
+	CaptureID int64
+	Rule      string
+	Severity  string
+	Summary   string
+	Evidence  string
+	FirstSeen sql.NullTime
+}
+
+type CaptureFlow struct {
+	ID              int64
+	CaptureID       int64
+	Protocol        string
+	SrcIp           string
+	SrcPort         int64
+	DstIp           string
+	DstPort         int64
+	PacketsSrcToDst int64
+	BytesSrcToDst   int64
+	PacketsDstToSrc int64
+	BytesDstToSrc   int64
+	TcpFlags        sql.NullString
+	HandshakeRttMs  sql.NullFloat64
+	FirstSeen       time.Time
+	LastSeen        time.Time
+}
+
+type CaptureHttp struct {
+	ID           int64
+	CaptureID    int64
+	ClientIp     string
+	ClientPort   int64
+	ServerIp     string
+	ServerPort   int64
+	RequestTime  time.Time
+	Method       sql.NullString
+	Host         sql.NullString
+	Uri          sql.NullString
+	UserAgent    sql.NullString
+	ResponseTime sql.NullTime
+	StatusCode   sql.NullInt64
+	ContentType  sql.NullString
+}
+
+type CaptureMetadatum struct {
+	CaptureID int64
+	Key       string
+	Value     string
+}
+
+type CaptureNote struct {
+	ID        int64
+	CaptureID int64
+	Body      string
+	CreatedAt time.Time
 }
 
 type CaptureStat struct {
-	ID                   int64
-	PacketCount          sql.NullInt64
-	CaptureID            int64
-	ProtocolDistribution sql.NullString
-	TopSrcIps            sql.NullString
-	TopDstIps            sql.NullString
-	TopTcpSrcPorts       sql.NullString
-	TopTcpDstPorts       sql.NullString
-	TopUdpSrcPorts       sql.NullString
-	TopUdpDstPorts       sql.NullString
-	PacketRate           sql.NullFloat64
-	AvgPacketSize        sql.NullFloat64
-	DurationSeconds      sql.NullInt64
-	FirstPacketTime      sql.NullTime
-	LastPacketTime       sql.NullTime
-	CreatedAt            sql.NullTime
+	ID                     int64
+	PacketCount            sql.NullInt64
+	CaptureID              int64
+	ProtocolDistribution   sql.NullString
+	TopSrcIps              sql.NullString
+	TopDstIps              sql.NullString
+	TopTcpSrcPorts         sql.NullString
+	TopTcpDstPorts         sql.NullString
+	TopUdpSrcPorts         sql.NullString
+	TopUdpDstPorts         sql.NullString
+	PacketRate             sql.NullFloat64
+	AvgPacketSize          sql.NullFloat64
+	DurationSeconds        sql.NullInt64
+	FirstPacketTime        sql.NullTime
+	LastPacketTime         sql.NullTime
+	TcpSyns                sql.NullInt64
+	TcpSynAcks             sql.NullInt64
+	TcpHandshakesCompleted sql.NullInt64
+	TcpResets              sql.NullInt64
+	TcpFins                sql.NullInt64
+	TcpDuplicateAcks       sql.NullInt64
+	TcpRetransmissions     sql.NullInt64
+	TcpOutOfOrder          sql.NullInt64
+	TcpAvgHandshakeRttMs   sql.NullFloat64
+	TcpMaxHandshakeRttMs   sql.NullFloat64
+	AnalyzerVersion        int64
+	CreatedAt              sql.NullTime
+}
+
+type CaptureTag struct {
+	CaptureID int64
+	Tag       string
+	CreatedAt time.Time
+}
+
+type CaptureTimeline struct {
+	ID          int64
+	CaptureID   int64
+	BucketStart time.Time
+	Protocol    string
+	Packets     int64
+	Bytes       int64
+}
+
+type CaptureTl struct {
+	ID            int64
+	CaptureID     int64
+	ClientIp      string
+	ClientPort    int64
+	ServerIp      string
+	ServerPort    int64
+	SeenAt        time.Time
+	Sni           sql.NullString
+	ClientVersion sql.NullString
+	CipherSuites  sql.NullString
+	Ja3           sql.NullString
+	Ja3Hash       sql.NullString
+	Version       sql.NullString
+	CipherSuite   sql.NullString
+	Ja3s          sql.NullString
+	Ja3sHash      sql.NullString
 }
 
 type Config struct {
-	WatchDir           sql.NullString
-	OrganizedDir       sql.NullString
-	ArchiveDir         sql.NullString
-	ExposeService      sql.NullBool
-	Port               sql.NullInt64
-	CompressionEnabled sql.NullBool
-	ArchiveDays        sql.NullInt64
-	MaxRetentionDays   sql.NullInt64
-	LogLevel           sql.NullString
+	WatchDir            sql.NullString
+	OrganizedDir        sql.NullString
+	ArchiveDir          sql.NullString
+	QuarantineDir       sql.NullString
+	ExposeService       sql.NullBool
+	Port                sql.NullInt64
+	CompressionEnabled  sql.NullBool
+	ArchiveDays         sql.NullInt64
+	MaxRetentionDays    sql.NullInt64
+	LogLevel            sql.NullString
+	WatchDirs           sql.NullString
+	WatchMode           sql.NullString
+	PollIntervalSeconds sql.NullInt64
+	QuietSeconds        sql.NullInt64
+	Analyzers           sql.NullString
+	AnonymizationKey    sql.NullString
+	FilenamePatterns    sql.NullString
+	SidecarWaitSeconds  sql.NullInt64
+	DuplicatePolicy     sql.NullString
+	IngestWorkers       sql.NullInt64
+}
+
+type IngestJob struct {
+	ID            int64
+	Path          string
+	Status        string
+	Attempts      int64
+	LastError     sql.NullString
+	CaptureID     sql.NullInt64
+	NextAttemptAt sql.NullTime
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type Rejection struct {
+	ID             int64
+	OriginalName   string
+	OriginalDir    string
+	QuarantinePath string
+	SidecarPath    sql.NullString
+	Reason         string
+	Detail         sql.NullString
+	CaptureID      sql.NullInt64
+	Status         string
+	ResubmittedAs  sql.NullString
+	CreatedAt      time.Time
+	ResolvedAt     sql.NullTime
 }
@@ -11,6 +11,80 @@ import (
 	"time"
 )
 
+const getActiveIngestJobByPath = `-- name: GetActiveIngestJobByPath :one
+SELECT id FROM ingest_jobs
+WHERE path = ? AND status IN ('queued', 'running')
+LIMIT 1
+`
+
+func (q *Queries) GetActiveIngestJobByPath(ctx context.Context, path string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getActiveIngestJobByPath, path)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getAllCaptureMetadata = `-- name: GetAllCaptureMetadata :many
+SELECT capture_id, "key", value FROM capture_metadata
+ORDER BY capture_id, key
+`
+
+func (q *Queries) GetAllCaptureMetadata(ctx context.Context) ([]CaptureMetadatum, error) {
+	rows, err := q.db.QueryContext(ctx, getAllCaptureMetadata)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureMetadatum
+	for rows.Next() {
+		var i CaptureMetadatum
+		if err := rows.Scan(&i.CaptureID, &i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllCaptureTags = `-- name: GetAllCaptureTags :many
+SELECT capture_id, tag FROM capture_tags
+ORDER BY capture_id, tag
+`
+
+type GetAllCaptureTagsRow struct {
+	CaptureID int64
+	Tag       string
+}
+
+func (q *Queries) GetAllCaptureTags(ctx context.Context) ([]GetAllCaptureTagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAllCaptureTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllCaptureTagsRow
+	for rows.Next() {
+		var i GetAllCaptureTagsRow
+		if err := rows.Scan(&i.CaptureID, &i.Tag); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getArchiveBrief = `-- name: GetArchiveBrief :many
 SELECT id, file_path, file_size, created_at, updated_at FROM captures WHERE archived = 1
 `
@@ -53,7 +127,7 @@ func (q *Queries) GetArchiveBrief(ctx context.Context) ([]GetArchiveBriefRow, er
 }
 
 const getArchviedCaptures = `-- name: GetArchviedCaptures :many
-SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at FROM captures WHERE archived = 1
+SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at, sha256, file_missing, corrupt, corrupt_packet, corrupt_error, duplicate_of FROM captures WHERE archived = 1
 `
 
 func (q *Queries) GetArchviedCaptures(ctx context.Context) ([]Capture, error) {
@@ -76,6 +150,12 @@ func (q *Queries) GetArchviedCaptures(ctx context.Context) ([]Capture, error) {
 			&i.Archived,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Sha256,
+			&i.FileMissing,
+			&i.Corrupt,
+			&i.CorruptPacket,
+			&i.CorruptError,
+			&i.DuplicateOf,
 		); err != nil {
 			return nil, err
 		}
@@ -91,7 +171,7 @@ func (q *Queries) GetArchviedCaptures(ctx context.Context) ([]Capture, error) {
 }
 
 const getCapture = `-- name: GetCapture :one
-SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at FROM captures WHERE id = ?
+SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at, sha256, file_missing, corrupt, corrupt_packet, corrupt_error, duplicate_of FROM captures WHERE id = ?
 `
 
 func (q *Queries) GetCapture(ctx context.Context, id int64) (Capture, error) {
@@ -108,38 +188,362 @@ func (q *Queries) GetCapture(ctx context.Context, id int64) (Capture, error) {
 		&i.Archived,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Sha256,
+		&i.FileMissing,
+		&i.Corrupt,
+		&i.CorruptPacket,
+		&i.CorruptError,
+		&i.DuplicateOf,
 	)
 	return i, err
 }
 
+const getCaptureDuplicates = `-- name: GetCaptureDuplicates :many
+SELECT id, capture_id, filename, hostname, scenario, capture_datetime, created_at FROM capture_duplicates
+WHERE capture_id = ?
+ORDER BY created_at, id
+`
+
+func (q *Queries) GetCaptureDuplicates(ctx context.Context, captureID int64) ([]CaptureDuplicate, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureDuplicates, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureDuplicate
+	for rows.Next() {
+		var i CaptureDuplicate
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.Filename,
+			&i.Hostname,
+			&i.Scenario,
+			&i.CaptureDatetime,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureFindings = `-- name: GetCaptureFindings :many
+SELECT id, capture_id, rule, severity, summary, evidence, first_seen FROM capture_findings
+WHERE capture_id = ?
+ORDER BY first_seen, id
+`
+
+func (q *Queries) GetCaptureFindings(ctx context.Context, captureID int64) ([]CaptureFinding, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureFindings, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureFinding
+	for rows.Next() {
+		var i CaptureFinding
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.Rule,
+			&i.Severity,
+			&i.Summary,
+			&i.Evidence,
+			&i.FirstSeen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureFlows = `-- name: GetCaptureFlows :many
+SELECT id, capture_id, protocol, src_ip, src_port, dst_ip, dst_port, packets_src_to_dst, bytes_src_to_dst, packets_dst_to_src, bytes_dst_to_src, tcp_flags, handshake_rtt_ms, first_seen, last_seen FROM capture_flows
+WHERE capture_id = ?
+ORDER BY first_seen, id
+`
+
+func (q *Queries) GetCaptureFlows(ctx context.Context, captureID int64) ([]CaptureFlow, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureFlows, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureFlow
+	for rows.Next() {
+		var i CaptureFlow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.Protocol,
+			&i.SrcIp,
+			&i.SrcPort,
+			&i.DstIp,
+			&i.DstPort,
+			&i.PacketsSrcToDst,
+			&i.BytesSrcToDst,
+			&i.PacketsDstToSrc,
+			&i.BytesDstToSrc,
+			&i.TcpFlags,
+			&i.HandshakeRttMs,
+			&i.FirstSeen,
+			&i.LastSeen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureHTTP = `-- name: GetCaptureHTTP :many
+SELECT id, capture_id, client_ip, client_port, server_ip, server_port, request_time, method, host, uri, user_agent, response_time, status_code, content_type FROM capture_http
+WHERE capture_id = ?
+ORDER BY request_time, id
+`
+
+func (q *Queries) GetCaptureHTTP(ctx context.Context, captureID int64) ([]CaptureHttp, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureHTTP, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureHttp
+	for rows.Next() {
+		var i CaptureHttp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.ClientIp,
+			&i.ClientPort,
+			&i.ServerIp,
+			&i.ServerPort,
+			&i.RequestTime,
+			&i.Method,
+			&i.Host,
+			&i.Uri,
+			&i.UserAgent,
+			&i.ResponseTime,
+			&i.StatusCode,
+			&i.ContentType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureIDBySHA256 = `-- name: GetCaptureIDBySHA256 :one
+SELECT id FROM captures
+WHERE sha256 = ? AND duplicate_of IS NULL
+ORDER BY id
+LIMIT 1
+`
+
+func (q *Queries) GetCaptureIDBySHA256(ctx context.Context, sha256 sql.NullString) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getCaptureIDBySHA256, sha256)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getCaptureIDsByHTTP = `-- name: GetCaptureIDsByHTTP :many
+SELECT DISTINCT capture_id FROM capture_http
+WHERE (coalesce(host, '') LIKE ? ESCAPE '\')
+  AND (coalesce(uri, '') LIKE ? ESCAPE '\')
+`
+
+type GetCaptureIDsByHTTPParams struct {
+	Host sql.NullString
+	Uri  sql.NullString
+}
+
+func (q *Queries) GetCaptureIDsByHTTP(ctx context.Context, arg GetCaptureIDsByHTTPParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureIDsByHTTP, arg.Host, arg.Uri)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var capture_id int64
+		if err := rows.Scan(&capture_id); err != nil {
+			return nil, err
+		}
+		items = append(items, capture_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureIDsBySNI = `-- name: GetCaptureIDsBySNI :many
+SELECT DISTINCT capture_id FROM capture_tls
+WHERE sni LIKE ? ESCAPE '\'
+`
+
+func (q *Queries) GetCaptureIDsBySNI(ctx context.Context, sni sql.NullString) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureIDsBySNI, sni)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var capture_id int64
+		if err := rows.Scan(&capture_id); err != nil {
+			return nil, err
+		}
+		items = append(items, capture_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureMetadata = `-- name: GetCaptureMetadata :many
+SELECT key, value FROM capture_metadata
+WHERE capture_id = ?
+ORDER BY key
+`
+
+type GetCaptureMetadataRow struct {
+	Key   string
+	Value string
+}
+
+func (q *Queries) GetCaptureMetadata(ctx context.Context, captureID int64) ([]GetCaptureMetadataRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureMetadata, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCaptureMetadataRow
+	for rows.Next() {
+		var i GetCaptureMetadataRow
+		if err := rows.Scan(&i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureNotes = `-- name: GetCaptureNotes :many
+SELECT id, capture_id, body, created_at FROM capture_notes
+WHERE capture_id = ?
+ORDER BY created_at, id
+`
+
+func (q *Queries) GetCaptureNotes(ctx context.Context, captureID int64) ([]CaptureNote, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureNotes, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureNote
+	for rows.Next() {
+		var i CaptureNote
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.Body,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getCaptureStatsByID = `-- name: GetCaptureStatsByID :one
-SELECT cs.id, cs.packet_count, cs.capture_id, cs.protocol_distribution, cs.top_src_ips, cs.top_dst_ips, cs.top_tcp_src_ports, cs.top_tcp_dst_ports, cs.top_udp_src_ports, cs.top_udp_dst_ports, cs.packet_rate, cs.avg_packet_size, cs.duration_seconds, cs.first_packet_time, cs.last_packet_time, cs.created_at, c.hostname, c.scenario, c.capture_datetime, c.file_path
+SELECT cs.id, cs.packet_count, cs.capture_id, cs.protocol_distribution, cs.top_src_ips, cs.top_dst_ips, cs.top_tcp_src_ports, cs.top_tcp_dst_ports, cs.top_udp_src_ports, cs.top_udp_dst_ports, cs.packet_rate, cs.avg_packet_size, cs.duration_seconds, cs.first_packet_time, cs.last_packet_time, cs.tcp_syns, cs.tcp_syn_acks, cs.tcp_handshakes_completed, cs.tcp_resets, cs.tcp_fins, cs.tcp_duplicate_acks, cs.tcp_retransmissions, cs.tcp_out_of_order, cs.tcp_avg_handshake_rtt_ms, cs.tcp_max_handshake_rtt_ms, cs.analyzer_version, cs.created_at, c.hostname, c.scenario, c.capture_datetime, c.file_path
 FROM captures c
 LEFT JOIN capture_stats cs ON c.id = cs.capture_id
 WHERE c.id = ?
 `
 
 type GetCaptureStatsByIDRow struct {
-	ID                   sql.NullInt64
-	PacketCount          sql.NullInt64
-	CaptureID            sql.NullInt64
-	ProtocolDistribution sql.NullString
-	TopSrcIps            sql.NullString
-	TopDstIps            sql.NullString
-	TopTcpSrcPorts       sql.NullString
-	TopTcpDstPorts       sql.NullString
-	TopUdpSrcPorts       sql.NullString
-	TopUdpDstPorts       sql.NullString
-	PacketRate           sql.NullFloat64
-	AvgPacketSize        sql.NullFloat64
-	DurationSeconds      sql.NullInt64
-	FirstPacketTime      sql.NullTime
-	LastPacketTime       sql.NullTime
-	CreatedAt            sql.NullTime
-	Hostname             string
-	Scenario             string
-	CaptureDatetime      time.Time
-	FilePath             string
+	ID                     sql.NullInt64
+	PacketCount            sql.NullInt64
+	CaptureID              sql.NullInt64
+	ProtocolDistribution   sql.NullString
+	TopSrcIps              sql.NullString
+	TopDstIps              sql.NullString
+	TopTcpSrcPorts         sql.NullString
+	TopTcpDstPorts         sql.NullString
+	TopUdpSrcPorts         sql.NullString
+	TopUdpDstPorts         sql.NullString
+	PacketRate             sql.NullFloat64
+	AvgPacketSize          sql.NullFloat64
+	DurationSeconds        sql.NullInt64
+	FirstPacketTime        sql.NullTime
+	LastPacketTime         sql.NullTime
+	TcpSyns                sql.NullInt64
+	TcpSynAcks             sql.NullInt64
+	TcpHandshakesCompleted sql.NullInt64
+	TcpResets              sql.NullInt64
+	TcpFins                sql.NullInt64
+	TcpDuplicateAcks       sql.NullInt64
+	TcpRetransmissions     sql.NullInt64
+	TcpOutOfOrder          sql.NullInt64
+	TcpAvgHandshakeRttMs   sql.NullFloat64
+	TcpMaxHandshakeRttMs   sql.NullFloat64
+	AnalyzerVersion        sql.NullInt64
+	CreatedAt              sql.NullTime
+	Hostname               string
+	Scenario               string
+	CaptureDatetime        time.Time
+	FilePath               string
 }
 
 func (q *Queries) GetCaptureStatsByID(ctx context.Context, id int64) (GetCaptureStatsByIDRow, error) {
@@ -161,6 +565,17 @@ func (q *Queries) GetCaptureStatsByID(ctx context.Context, id int64) (GetCapture
 		&i.DurationSeconds,
 		&i.FirstPacketTime,
 		&i.LastPacketTime,
+		&i.TcpSyns,
+		&i.TcpSynAcks,
+		&i.TcpHandshakesCompleted,
+		&i.TcpResets,
+		&i.TcpFins,
+		&i.TcpDuplicateAcks,
+		&i.TcpRetransmissions,
+		&i.TcpOutOfOrder,
+		&i.TcpAvgHandshakeRttMs,
+		&i.TcpMaxHandshakeRttMs,
+		&i.AnalyzerVersion,
 		&i.CreatedAt,
 		&i.Hostname,
 		&i.Scenario,
@@ -170,8 +585,124 @@ func (q *Queries) GetCaptureStatsByID(ctx context.Context, id int64) (GetCapture
 	return i, err
 }
 
+const getCaptureTLS = `-- name: GetCaptureTLS :many
+SELECT id, capture_id, client_ip, client_port, server_ip, server_port, seen_at, sni, client_version, cipher_suites, ja3, ja3_hash, version, cipher_suite, ja3s, ja3s_hash FROM capture_tls
+WHERE capture_id = ?
+ORDER BY seen_at, id
+`
+
+func (q *Queries) GetCaptureTLS(ctx context.Context, captureID int64) ([]CaptureTl, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureTLS, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CaptureTl
+	for rows.Next() {
+		var i CaptureTl
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.ClientIp,
+			&i.ClientPort,
+			&i.ServerIp,
+			&i.ServerPort,
+			&i.SeenAt,
+			&i.Sni,
+			&i.ClientVersion,
+			&i.CipherSuites,
+			&i.Ja3,
+			&i.Ja3Hash,
+			&i.Version,
+			&i.CipherSuite,
+			&i.Ja3s,
+			&i.Ja3sHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureTags = `-- name: GetCaptureTags :many
+SELECT tag FROM capture_tags
+WHERE capture_id = ?
+ORDER BY tag
+`
+
+func (q *Queries) GetCaptureTags(ctx context.Context, captureID int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureTags, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		items = append(items, tag)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCaptureTimeline = `-- name: GetCaptureTimeline :many
+SELECT bucket_start, protocol, packets, bytes FROM capture_timeline
+WHERE capture_id = ?
+ORDER BY bucket_start, protocol
+`
+
+type GetCaptureTimelineRow struct {
+	BucketStart time.Time
+	Protocol    string
+	Packets     int64
+	Bytes       int64
+}
+
+func (q *Queries) GetCaptureTimeline(ctx context.Context, captureID int64) ([]GetCaptureTimelineRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCaptureTimeline, captureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCaptureTimelineRow
+	for rows.Next() {
+		var i GetCaptureTimelineRow
+		if err := rows.Scan(
+			&i.BucketStart,
+			&i.Protocol,
+			&i.Packets,
+			&i.Bytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getCaptures = `-- name: GetCaptures :many
-SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at FROM captures
+SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at, sha256, file_missing, corrupt, corrupt_packet, corrupt_error, duplicate_of FROM captures
 `
 
 func (q *Queries) GetCaptures(ctx context.Context) ([]Capture, error) {
@@ -194,6 +725,12 @@ func (q *Queries) GetCaptures(ctx context.Context) ([]Capture, error) {
 			&i.Archived,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Sha256,
+			&i.FileMissing,
+			&i.Corrupt,
+			&i.CorruptPacket,
+			&i.CorruptError,
+			&i.DuplicateOf,
 		); err != nil {
 			return nil, err
 		}
@@ -209,7 +746,7 @@ func (q *Queries) GetCaptures(ctx context.Context) ([]Capture, error) {
 }
 
 const getCapturesByHostname = `-- name: GetCapturesByHostname :many
-SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at FROM captures WHERE hostname = ? ORDER BY capture_datetime DESC
+SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at, sha256, file_missing, corrupt, corrupt_packet, corrupt_error, duplicate_of FROM captures WHERE hostname = ? ORDER BY capture_datetime DESC
 `
 
 func (q *Queries) GetCapturesByHostname(ctx context.Context, hostname string) ([]Capture, error) {
@@ -232,6 +769,12 @@ func (q *Queries) GetCapturesByHostname(ctx context.Context, hostname string) ([
 			&i.Archived,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Sha256,
+			&i.FileMissing,
+			&i.Corrupt,
+			&i.CorruptPacket,
+			&i.CorruptError,
+			&i.DuplicateOf,
 		); err != nil {
 			return nil, err
 		}
@@ -247,7 +790,7 @@ func (q *Queries) GetCapturesByHostname(ctx context.Context, hostname string) ([
 }
 
 const getCapturesByScenario = `-- name: GetCapturesByScenario :many
-SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at FROM captures WHERE scenario = ? ORDER BY capture_datetime DESC
+SELECT id, hostname, scenario, capture_datetime, file_path, file_size, compressed, archived, created_at, updated_at, sha256, file_missing, corrupt, corrupt_packet, corrupt_error, duplicate_of FROM captures WHERE scenario = ? ORDER BY capture_datetime DESC
 `
 
 func (q *Queries) GetCapturesByScenario(ctx context.Context, scenario string) ([]Capture, error) {
@@ -270,6 +813,12 @@ func (q *Queries) GetCapturesByScenario(ctx context.Context, scenario string) ([
 			&i.Archived,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Sha256,
+			&i.FileMissing,
+			&i.Corrupt,
+			&i.CorruptPacket,
+			&i.CorruptError,
+			&i.DuplicateOf,
 		); err != nil {
 			return nil, err
 		}
@@ -359,6 +908,42 @@ func (q *Queries) GetCapturesForArchiveWithLimit(ctx context.Context, datetime i
 	return items, nil
 }
 
+const getCapturesForReanalysis = `-- name: GetCapturesForReanalysis :many
+SELECT c.id, c.file_path
+FROM captures c
+LEFT JOIN capture_stats cs ON c.id = cs.capture_id
+WHERE cs.id IS NULL OR cs.analyzer_version < ?
+ORDER BY c.id ASC
+`
+
+type GetCapturesForReanalysisRow struct {
+	ID       int64
+	FilePath string
+}
+
+func (q *Queries) GetCapturesForReanalysis(ctx context.Context, analyzerVersion int64) ([]GetCapturesForReanalysisRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCapturesForReanalysis, analyzerVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCapturesForReanalysisRow
+	for rows.Next() {
+		var i GetCapturesForReanalysisRow
+		if err := rows.Scan(&i.ID, &i.FilePath); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getOldArchivedCaptures = `-- name: GetOldArchivedCaptures :many
 SELECT id, file_path
 FROM captures
@@ -433,6 +1018,31 @@ func (q *Queries) GetPendingCompressions(ctx context.Context, limit int64) ([]Ge
 	return items, nil
 }
 
+const getRejection = `-- name: GetRejection :one
+SELECT id, original_name, original_dir, quarantine_path, sidecar_path, reason, detail, capture_id, status, resubmitted_as, created_at, resolved_at FROM rejections
+WHERE id = ?
+`
+
+func (q *Queries) GetRejection(ctx context.Context, id int64) (Rejection, error) {
+	row := q.db.QueryRowContext(ctx, getRejection, id)
+	var i Rejection
+	err := row.Scan(
+		&i.ID,
+		&i.OriginalName,
+		&i.OriginalDir,
+		&i.QuarantinePath,
+		&i.SidecarPath,
+		&i.Reason,
+		&i.Detail,
+		&i.CaptureID,
+		&i.Status,
+		&i.ResubmittedAs,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
 const getStatsByHostname = `-- name: GetStatsByHostname :many
 SELECT 
     c.hostname,
@@ -588,3 +1198,288 @@ func (q *Queries) GetSummary(ctx context.Context) (GetSummaryRow, error) {
 	)
 	return i, err
 }
+
+const listFindings = `-- name: ListFindings :many
+SELECT
+    f.id,
+    f.capture_id,
+    c.hostname,
+    c.scenario,
+    c.capture_datetime,
+    f.rule,
+    f.severity,
+    f.summary,
+    f.evidence,
+    f.first_seen
+FROM capture_findings f
+JOIN captures c ON c.id = f.capture_id
+ORDER BY c.capture_datetime DESC, f.first_seen ASC, f.id ASC
+`
+
+type ListFindingsRow struct {
+	ID              int64
+	CaptureID       int64
+	Hostname        string
+	Scenario        string
+	CaptureDatetime time.Time
+	Rule            string
+	Severity        string
+	Summary         string
+	Evidence        string
+	FirstSeen       sql.NullTime
+}
+
+func (q *Queries) ListFindings(ctx context.Context) ([]ListFindingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFindings)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFindingsRow
+	for rows.Next() {
+		var i ListFindingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CaptureID,
+			&i.Hostname,
+			&i.Scenario,
+			&i.CaptureDatetime,
+			&i.Rule,
+			&i.Severity,
+			&i.Summary,
+			&i.Evidence,
+			&i.FirstSeen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listIngestJobs = `-- name: ListIngestJobs :many
+SELECT id, path, status, attempts, last_error, capture_id, next_attempt_at, created_at, updated_at FROM ingest_jobs
+ORDER BY id DESC
+`
+
+func (q *Queries) ListIngestJobs(ctx context.Context) ([]IngestJob, error) {
+	rows, err := q.db.QueryContext(ctx, listIngestJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IngestJob
+	for rows.Next() {
+		var i IngestJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Path,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.CaptureID,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRejections = `-- name: ListRejections :many
+SELECT id, original_name, original_dir, quarantine_path, sidecar_path, reason, detail, capture_id, status, resubmitted_as, created_at, resolved_at FROM rejections
+ORDER BY id DESC
+`
+
+func (q *Queries) ListRejections(ctx context.Context) ([]Rejection, error) {
+	rows, err := q.db.QueryContext(ctx, listRejections)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Rejection
+	for rows.Next() {
+		var i Rejection
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalName,
+			&i.OriginalDir,
+			&i.QuarantinePath,
+			&i.SidecarPath,
+			&i.Reason,
+			&i.Detail,
+			&i.CaptureID,
+			&i.Status,
+			&i.ResubmittedAs,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchDNSByExactName = `-- name: SearchDNSByExactName :many
+SELECT
+    d.capture_id,
+    c.hostname,
+    c.scenario,
+    c.capture_datetime,
+    d.query_time,
+    d.response_time,
+    d.client_ip,
+    d.server_ip,
+    d.name,
+    d.record_type,
+    d.response_code,
+    d.answers
+FROM capture_dns d
+JOIN captures c ON c.id = d.capture_id
+WHERE d.name = ?
+ORDER BY c.capture_datetime DESC, d.query_time ASC
+`
+
+type SearchDNSByExactNameRow struct {
+	CaptureID       int64
+	Hostname        string
+	Scenario        string
+	CaptureDatetime time.Time
+	QueryTime       time.Time
+	ResponseTime    sql.NullTime
+	ClientIp        string
+	ServerIp        string
+	Name            string
+	RecordType      string
+	ResponseCode    sql.NullString
+	Answers         sql.NullString
+}
+
+func (q *Queries) SearchDNSByExactName(ctx context.Context, name string) ([]SearchDNSByExactNameRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchDNSByExactName, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchDNSByExactNameRow
+	for rows.Next() {
+		var i SearchDNSByExactNameRow
+		if err := rows.Scan(
+			&i.CaptureID,
+			&i.Hostname,
+			&i.Scenario,
+			&i.CaptureDatetime,
+			&i.QueryTime,
+			&i.ResponseTime,
+			&i.ClientIp,
+			&i.ServerIp,
+			&i.Name,
+			&i.RecordType,
+			&i.ResponseCode,
+			&i.Answers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchDNSByName = `-- name: SearchDNSByName :many
+SELECT
+    d.capture_id,
+    c.hostname,
+    c.scenario,
+    c.capture_datetime,
+    d.query_time,
+    d.response_time,
+    d.client_ip,
+    d.server_ip,
+    d.name,
+    d.record_type,
+    d.response_code,
+    d.answers
+FROM capture_dns d
+JOIN captures c ON c.id = d.capture_id
+WHERE d.name LIKE ? ESCAPE '\'
+ORDER BY c.capture_datetime DESC, d.query_time ASC
+`
+
+type SearchDNSByNameRow struct {
+	CaptureID       int64
+	Hostname        string
+	Scenario        string
+	CaptureDatetime time.Time
+	QueryTime       time.Time
+	ResponseTime    sql.NullTime
+	ClientIp        string
+	ServerIp        string
+	Name            string
+	RecordType      string
+	ResponseCode    sql.NullString
+	Answers         sql.NullString
+}
+
+func (q *Queries) SearchDNSByName(ctx context.Context, name string) ([]SearchDNSByNameRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchDNSByName, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchDNSByNameRow
+	for rows.Next() {
+		var i SearchDNSByNameRow
+		if err := rows.Scan(
+			&i.CaptureID,
+			&i.Hostname,
+			&i.Scenario,
+			&i.CaptureDatetime,
+			&i.QueryTime,
+			&i.ResponseTime,
+			&i.ClientIp,
+			&i.ServerIp,
+			&i.Name,
+			&i.RecordType,
+			&i.ResponseCode,
+			&i.Answers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -7,8 +7,66 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
+const claimIngestJob = `-- name: ClaimIngestJob :one
+UPDATE ingest_jobs
+SET status = 'running', attempts = attempts + 1, updated_at = ?1
+WHERE id = (
+    SELECT id FROM ingest_jobs
+    WHERE status = 'queued' AND (next_attempt_at IS NULL OR next_attempt_at <= ?1)
+    ORDER BY id
+    LIMIT 1
+)
+RETURNING id, path, status, attempts, last_error, capture_id, next_attempt_at, created_at, updated_at
+`
+
+func (q *Queries) ClaimIngestJob(ctx context.Context, now time.Time) (IngestJob, error) {
+	row := q.db.QueryRowContext(ctx, claimIngestJob, now)
+	var i IngestJob
+	err := row.Scan(
+		&i.ID,
+		&i.Path,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.CaptureID,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const finishIngestJob = `-- name: FinishIngestJob :exec
+UPDATE ingest_jobs
+SET status = ?, last_error = ?, capture_id = ?, next_attempt_at = ?, updated_at = ?
+WHERE id = ?
+`
+
+type FinishIngestJobParams struct {
+	Status        string
+	LastError     sql.NullString
+	CaptureID     sql.NullInt64
+	NextAttemptAt sql.NullTime
+	UpdatedAt     time.Time
+	ID            int64
+}
+
+func (q *Queries) FinishIngestJob(ctx context.Context, arg FinishIngestJobParams) error {
+	_, err := q.db.ExecContext(ctx, finishIngestJob,
+		arg.Status,
+		arg.LastError,
+		arg.CaptureID,
+		arg.NextAttemptAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
 const markCaptureAsArchived = `-- name: MarkCaptureAsArchived :exec
 
 UPDATE captures
@@ -33,6 +91,152 @@ func (q *Queries) MarkCaptureAsCompressed(ctx context.Context, id int64) error {
 	return err
 }
 
+const relinkCapture = `-- name: RelinkCapture :exec
+UPDATE captures
+SET file_path = ?, archived = ?, compressed = ?, file_missing = 0, updated_at = current_timestamp
+WHERE id = ?
+`
+
+type RelinkCaptureParams struct {
+	FilePath   string
+	Archived   sql.NullBool
+	Compressed sql.NullBool
+	ID         int64
+}
+
+func (q *Queries) RelinkCapture(ctx context.Context, arg RelinkCaptureParams) error {
+	_, err := q.db.ExecContext(ctx, relinkCapture,
+		arg.FilePath,
+		arg.Archived,
+		arg.Compressed,
+		arg.ID,
+	)
+	return err
+}
+
+const requeueRunningIngestJobs = `-- name: RequeueRunningIngestJobs :execrows
+UPDATE ingest_jobs
+SET status = 'queued', updated_at = ?
+WHERE status = 'running'
+`
+
+func (q *Queries) RequeueRunningIngestJobs(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, requeueRunningIngestJobs, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resolveRejection = `-- name: ResolveRejection :execrows
+UPDATE rejections
+SET status = 'resubmitted', resubmitted_as = ?, resolved_at = ?
+WHERE id = ? AND status = 'quarantined'
+`
+
+type ResolveRejectionParams struct {
+	ResubmittedAs sql.NullString
+	ResolvedAt    sql.NullTime
+	ID            int64
+}
+
+func (q *Queries) ResolveRejection(ctx context.Context, arg ResolveRejectionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resolveRejection, arg.ResubmittedAs, arg.ResolvedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryFailedIngestJobs = `-- name: RetryFailedIngestJobs :execrows
+UPDATE ingest_jobs
+SET status = 'queued', attempts = 0, next_attempt_at = NULL, updated_at = ?
+WHERE status = 'failed'
+`
+
+func (q *Queries) RetryFailedIngestJobs(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, retryFailedIngestJobs, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryIngestJob = `-- name: RetryIngestJob :execrows
+UPDATE ingest_jobs
+SET status = 'queued', attempts = 0, next_attempt_at = NULL, updated_at = ?
+WHERE id = ? AND status = 'failed'
+`
+
+type RetryIngestJobParams struct {
+	UpdatedAt time.Time
+	ID        int64
+}
+
+func (q *Queries) RetryIngestJob(ctx context.Context, arg RetryIngestJobParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, retryIngestJob, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setCaptureCorrupt = `-- name: SetCaptureCorrupt :exec
+UPDATE captures
+SET corrupt = ?, corrupt_packet = ?, corrupt_error = ?, updated_at = current_timestamp
+WHERE id = ?
+`
+
+type SetCaptureCorruptParams struct {
+	Corrupt       sql.NullBool
+	CorruptPacket sql.NullInt64
+	CorruptError  sql.NullString
+	ID            int64
+}
+
+func (q *Queries) SetCaptureCorrupt(ctx context.Context, arg SetCaptureCorruptParams) error {
+	_, err := q.db.ExecContext(ctx, setCaptureCorrupt,
+		arg.Corrupt,
+		arg.CorruptPacket,
+		arg.CorruptError,
+		arg.ID,
+	)
+	return err
+}
+
+const setCaptureFileMissing = `-- name: SetCaptureFileMissing :exec
+UPDATE captures
+SET file_missing = ?, updated_at = current_timestamp
+WHERE id = ?
+`
+
+type SetCaptureFileMissingParams struct {
+	FileMissing sql.NullBool
+	ID          int64
+}
+
+func (q *Queries) SetCaptureFileMissing(ctx context.Context, arg SetCaptureFileMissingParams) error {
+	_, err := q.db.ExecContext(ctx, setCaptureFileMissing, arg.FileMissing, arg.ID)
+	return err
+}
+
+const setCaptureSHA256 = `-- name: SetCaptureSHA256 :exec
+UPDATE captures
+SET sha256 = ?, duplicate_of = ?
+WHERE id = ?
+`
+
+type SetCaptureSHA256Params struct {
+	Sha256      sql.NullString
+	DuplicateOf sql.NullInt64
+	ID          int64
+}
+
+func (q *Queries) SetCaptureSHA256(ctx context.Context, arg SetCaptureSHA256Params) error {
+	_, err := q.db.ExecContext(ctx, setCaptureSHA256, arg.Sha256, arg.DuplicateOf, arg.ID)
+	return err
+}
+
 const updateFilePath = `-- name: UpdateFilePath :exec
 UPDATE captures
 SET file_path = ?, updated_at = current_timestamp
@@ -15,3 +15,58 @@ UPDATE captures
 SET file_path = ?, updated_at = current_timestamp
 WHERE id = ?;
 
+-- name: SetCaptureFileMissing :exec
+UPDATE captures
+SET file_missing = ?, updated_at = current_timestamp
+WHERE id = ?;
+
+-- name: RelinkCapture :exec
+UPDATE captures
+SET file_path = ?, archived = ?, compressed = ?, file_missing = 0, updated_at = current_timestamp
+WHERE id = ?;
+
+-- name: SetCaptureCorrupt :exec
+UPDATE captures
+SET corrupt = ?, corrupt_packet = ?, corrupt_error = ?, updated_at = current_timestamp
+WHERE id = ?;
+
+-- name: ClaimIngestJob :one
+UPDATE ingest_jobs
+SET status = 'running', attempts = attempts + 1, updated_at = sqlc.arg(now)
+WHERE id = (
+    SELECT id FROM ingest_jobs
+    WHERE status = 'queued' AND (next_attempt_at IS NULL OR next_attempt_at <= sqlc.arg(now))
+    ORDER BY id
+    LIMIT 1
+)
+RETURNING *;
+
+-- name: FinishIngestJob :exec
+UPDATE ingest_jobs
+SET status = ?, last_error = ?, capture_id = ?, next_attempt_at = ?, updated_at = ?
+WHERE id = ?;
+
+-- name: RequeueRunningIngestJobs :execrows
+UPDATE ingest_jobs
+SET status = 'queued', updated_at = ?
+WHERE status = 'running';
+
+-- name: RetryIngestJob :execrows
+UPDATE ingest_jobs
+SET status = 'queued', attempts = 0, next_attempt_at = NULL, updated_at = ?
+WHERE id = ? AND status = 'failed';
+
+-- name: RetryFailedIngestJobs :execrows
+UPDATE ingest_jobs
+SET status = 'queued', attempts = 0, next_attempt_at = NULL, updated_at = ?
+WHERE status = 'failed';
+
+-- name: ResolveRejection :execrows
+UPDATE rejections
+SET status = 'resubmitted', resubmitted_as = ?, resolved_at = ?
+WHERE id = ? AND status = 'quarantined';
+
+-- name: SetCaptureSHA256 :exec
+UPDATE captures
+SET sha256 = ?, duplicate_of = ?
+WHERE id = ?;
@@ -94,6 +94,9 @@ func (s *Server) ArchiveFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.ingest.ingesting.RLock()
+	defer s.ingest.ingesting.RUnlock()
+
 	renameError := os.Rename(capture.FilePath, targetPath)
 	if renameError != nil {
 		s.logger.Error("Failed to rename file", "error", renameError, "path", capture.FilePath)
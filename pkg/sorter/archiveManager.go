@@ -18,10 +18,11 @@ import (
 type ArchiveManager struct {
 	logger logger.Logger
 	cfg    config.Config
+	ingest *IngestQueue
 }
 
-func NewArchiveManager(cfg config.Config, logger logger.Logger) *ArchiveManager {
-	return &ArchiveManager{logger: logger, cfg: cfg}
+func NewArchiveManager(cfg config.Config, logger logger.Logger, ingest *IngestQueue) *ArchiveManager {
+	return &ArchiveManager{logger: logger, cfg: cfg, ingest: ingest}
 }
 func (am *ArchiveManager) InitialCheck() error {
 	if am.cfg.LogLevel == "info" {
@@ -121,6 +122,9 @@ func (am *ArchiveManager) StartPeriodicCheck() {
 }
 
 func (am *ArchiveManager) archiveFile(id int, filePath string) error {
+	am.ingest.ingesting.RLock()
+	defer am.ingest.ingesting.RUnlock()
+
 	if am.cfg.LogLevel == "info" {
 		am.logger.Info("Archiving file", "path", filePath, "id", id)
 	}
@@ -168,6 +172,9 @@ func (am *ArchiveManager) archiveFile(id int, filePath string) error {
 }
 
 func (am *ArchiveManager) compressFile(id int, filePath string) error {
+	am.ingest.ingesting.RLock()
+	defer am.ingest.ingesting.RUnlock()
+
 	if am.cfg.LogLevel == "info" {
 		am.logger.Info("Compressing file", "path", filePath, "id", id)
 	}
@@ -188,6 +195,10 @@ func (am *ArchiveManager) compressFile(id int, filePath string) error {
 		return fmt.Errorf("failed to get database queries: %w", err)
 	}
 
+	if renamed, err := renameGzipped(s, id, filePath); renamed || err != nil {
+		return err
+	}
+
 	fr, openError := os.ReadFile(filePath)
 	if openError != nil {
 		return fmt.Errorf("failed to open file: %w", openError)
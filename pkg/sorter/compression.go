@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
 )
@@ -100,6 +101,8 @@ func (s *Server) CompressTriggerHandler(w http.ResponseWriter, r *http.Request)
 
 func (s *Server) compressFile(id int, filePath string, store *db.Store) error {
 	cfg := s.GetConfig()
+	s.ingest.ingesting.RLock()
+	defer s.ingest.ingesting.RUnlock()
 
 	if cfg.LogLevel == "info" {
 		s.logger.Info("Compressing file", "path", filePath, "id", id)
@@ -116,6 +119,10 @@ func (s *Server) compressFile(id int, filePath string, store *db.Store) error {
 		return nil
 	}
 
+	if renamed, err := renameGzipped(store, id, filePath); renamed || err != nil {
+		return err
+	}
+
 	fr, openError := os.ReadFile(filePath)
 	if openError != nil {
 		return fmt.Errorf("failed to open file: %w", openError)
@@ -164,3 +171,29 @@ func (s *Server) compressFile(id int, filePath string, store *db.Store) error {
 
 	return nil
 }
+
+// renameGzipped gives a capture whose content is already gzipped, but whose
+// name lacks the .gz, the suffix instead of a second gzip layer. Older
+// versions stored gzipped input under a plain .pcap name. It reports whether
+// filePath was such a capture.
+func renameGzipped(store *db.Store, id int, filePath string) (bool, error) {
+	ext, err := capture.FileExt(filePath)
+	if err != nil || !strings.HasSuffix(ext, ".gz") {
+		return false, err
+	}
+
+	compressedPath := filePath + ".gz"
+	if err := os.Rename(filePath, compressedPath); err != nil {
+		return true, fmt.Errorf("failed to rename gzipped file: %w", err)
+	}
+	if err := store.UpdateFilePath(context.Background(), sqlc.UpdateFilePathParams{
+		FilePath: compressedPath,
+		ID:       int64(id),
+	}); err != nil {
+		return true, fmt.Errorf("failed to update file path in database: %w", err)
+	}
+	if err := store.MarkCaptureAsCompressed(context.Background(), int64(id)); err != nil {
+		return true, fmt.Errorf("failed to mark capture as compressed: %w", err)
+	}
+	return true, nil
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
 )
@@ -17,13 +18,14 @@ func (s *Server) GetConfigHandler(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
 		return
 	}
-	config, getConfigErr := store.GetConfig(context.Background())
+	cfg, getConfigErr := store.GetConfig(context.Background())
 	if getConfigErr != nil {
 		s.logger.Error("Failed to get config", "error", getConfigErr)
 		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
 		return
 	}
-	configJSON, marshalErr := json.Marshal(config)
+	cfg.AnonymizationKey.String = config.MaskSecret(cfg.AnonymizationKey.String)
+	configJSON, marshalErr := json.Marshal(cfg)
 	if marshalErr != nil {
 		s.logger.Error("Failed to marshal config", "error", marshalErr)
 		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
@@ -49,6 +51,40 @@ func (s *Server) UpdateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
 		return
 	}
+	// A config read back from GetConfigHandler has the key masked.
+	if cfg.AnonymizationKey == config.SecretMask {
+		cfg.AnonymizationKey = s.GetConfig().AnonymizationKey
+	}
+	if err := ValidateFilenamePatterns(cfg.FilenamePatterns); err != nil {
+		s.logger.Error("Invalid filename patterns", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	if err := ValidateWatchDirs(cfg); err != nil {
+		s.logger.Error("Invalid watch dirs", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	if err := ValidateWatchMode(cfg.WatchMode); err != nil {
+		s.logger.Error("Invalid watch mode", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	if err := ValidateQuarantineDir(cfg); err != nil {
+		s.logger.Error("Invalid quarantine dir", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	if err := ValidateDuplicatePolicy(cfg.DuplicatePolicy); err != nil {
+		s.logger.Error("Invalid duplicate policy", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	if err := capture.ValidateAnalyzers(cfg.Analyzers); err != nil {
+		s.logger.Error("Invalid analyzers", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
 
 	store, dbErr := db.InitIfNeeded()
 	if dbErr != nil {
@@ -73,11 +109,12 @@ func (s *Server) UpdateConfigHandler(w http.ResponseWriter, r *http.Request) {
 	oldCfg := s.GetConfig()
 	portChanged := cfg.Port != oldCfg.Port
 	exposeServiceChanged := cfg.ExposeService != oldCfg.ExposeService
+	ingestWorkersChanged := cfg.IngestWorkers != oldCfg.IngestWorkers
 	s.UpdateConfig(cfg)
 
-	if portChanged || exposeServiceChanged {
+	if portChanged || exposeServiceChanged || ingestWorkersChanged {
 		s.logger.Warn("Config updated but server restart required for changes to take effect",
-			"port_changed", portChanged, "expose_service_changed", exposeServiceChanged)
+			"port_changed", portChanged, "expose_service_changed", exposeServiceChanged, "ingest_workers_changed", ingestWorkersChanged)
 	} else {
 		s.logger.Info("Config updated and applied")
 	}
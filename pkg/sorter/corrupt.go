@@ -0,0 +1,49 @@
+package sorter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+)
+
+// analyzeStored analyzes a capture for storing. A capture that breaks off
+// after some packets is salvaged: the stats of those packets are returned
+// with the *capture.CorruptError. One with no packets to salvage is an error
+// that wraps it.
+func analyzeStored(cfg config.Config, filePath string) (capture.CaptureStats, *capture.CorruptError, error) {
+	res, err := capture.AnalyzeCaptureFile(cfg, filePath)
+	if err == nil {
+		return res, nil, nil
+	}
+	var corrupt *capture.CorruptError
+	if errors.As(err, &corrupt) && res.TotalPackets > 0 {
+		return res, corrupt, nil
+	}
+	return capture.CaptureStats{}, nil, fmt.Errorf("failed to parse capture file %s: %w", filePath, err)
+}
+
+// corruptColumns are the corrupt, corrupt_packet and corrupt_error values of
+// a capture.
+func corruptColumns(corrupt *capture.CorruptError) (sql.NullBool, sql.NullInt64, sql.NullString) {
+	if corrupt == nil {
+		return sql.NullBool{Bool: false, Valid: true}, sql.NullInt64{}, sql.NullString{}
+	}
+	return sql.NullBool{Bool: true, Valid: true},
+		sql.NullInt64{Int64: int64(corrupt.Packet), Valid: true},
+		nullString(corrupt.Err.Error())
+}
+
+// captureHash is the content hash of a capture, or "" if its content can't
+// be read to the end, like a truncated gzip stream. Whether such a capture
+// can be stored is up to the analysis.
+func captureHash(filePath string) (string, error) {
+	sum, err := capture.ContentHash(filePath)
+	var corrupt *capture.CorruptError
+	if errors.As(err, &corrupt) {
+		return "", nil
+	}
+	return sum, err
+}
@@ -0,0 +1,66 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+// duplicateError is returned by ingestFile for a capture whose content is
+// already stored, unless the duplicate policy allows it.
+type duplicateError struct {
+	CaptureID int64
+}
+
+func (e *duplicateError) Error() string {
+	return fmt.Sprintf("same content as capture %d", e.CaptureID)
+}
+
+// ValidateDuplicatePolicy reports an unknown duplicate_policy.
+func ValidateDuplicatePolicy(policy string) error {
+	switch policy {
+	case "", config.DuplicatePolicyReject, config.DuplicatePolicyLink, config.DuplicatePolicyAllow:
+		return nil
+	}
+	return fmt.Errorf("unknown duplicate_policy %q (reject, link or allow)", policy)
+}
+
+// linkDuplicate records filename as another copy of the stored capture
+// captureID, for the link duplicate policy. The copy itself isn't kept.
+func linkDuplicate(captureID int64, filename string, result FilenameValidationResult) error {
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		return fmt.Errorf("failed to get database queries: %w", err)
+	}
+	return store.InsertCaptureDuplicate(context.Background(), sqlc.InsertCaptureDuplicateParams{
+		CaptureID:       captureID,
+		Filename:        filepath.Base(filename),
+		Hostname:        result.Hostname,
+		Scenario:        result.Scenario,
+		CaptureDatetime: result.CaptureDateTime,
+		CreatedAt:       time.Now().UTC(),
+	})
+}
+
+// storeHash saves the hash of a capture stored before hashes were taken. If
+// another capture already has the content, it becomes a copy of that one, as
+// under the allow duplicate policy.
+func storeHash(ctx context.Context, store *db.Store, captureID int64, sum string) error {
+	params := sqlc.SetCaptureSHA256Params{Sha256: nullString(sum), ID: captureID}
+	err := store.SetCaptureSHA256(ctx, params)
+	if !db.IsUniqueViolation(err, "captures.sha256") {
+		return err
+	}
+	existingID, err := store.GetCaptureIDBySHA256(ctx, nullString(sum))
+	if err != nil {
+		return err
+	}
+	params.DuplicateOf = sql.NullInt64{Int64: existingID, Valid: true}
+	return store.SetCaptureSHA256(ctx, params)
+}
@@ -0,0 +1,304 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+)
+
+const (
+	defaultDiffThreshold = 0.5
+	defaultDiffLimit     = 50
+	// diffMinPackets keeps entries with a handful of packets on both sides,
+	// where any difference is a large ratio, out of the changed list.
+	diffMinPackets = 10
+)
+
+// diffProfile is what a diff compares of a capture, or the per-capture
+// average of several. All values are packet counts.
+type diffProfile struct {
+	packets   float64
+	hosts     map[string]float64
+	ports     map[string]float64
+	protocols map[string]float64
+	flows     map[string]float64
+}
+
+func newDiffProfile() *diffProfile {
+	return &diffProfile{
+		hosts:     make(map[string]float64),
+		ports:     make(map[string]float64),
+		protocols: make(map[string]float64),
+		flows:     make(map[string]float64),
+	}
+}
+
+// add adds the stats and flows of a capture to the profile. Hosts and
+// protocols come from the stats, which count all of them. Ports and
+// conversations come from the flows, as the stats only keep the top ports;
+// without flows the diff falls back to those.
+func (p *diffProfile) add(ctx context.Context, store *db.Store, captureID int64) error {
+	statsRow, err := store.GetCaptureStatsByID(ctx, captureID)
+	if err != nil {
+		return err
+	}
+
+	p.packets += float64(statsRow.PacketCount.Int64)
+	addJSONCounts(p.protocols, statsRow.ProtocolDistribution, "")
+	addJSONCounts(p.hosts, statsRow.TopSrcIps, "")
+	addJSONCounts(p.hosts, statsRow.TopDstIps, "")
+
+	flows, err := store.GetCaptureFlows(ctx, captureID)
+	if err != nil {
+		return err
+	}
+	if len(flows) == 0 {
+		addJSONCounts(p.ports, statsRow.TopTcpDstPorts, "TCP/")
+		addJSONCounts(p.ports, statsRow.TopUdpDstPorts, "UDP/")
+		return nil
+	}
+
+	for _, flow := range flows {
+		packets := float64(flow.PacketsSrcToDst + flow.PacketsDstToSrc)
+		switch flow.Protocol {
+		case "TCP", "UDP":
+			// Conversations are keyed by the server side only, client ports
+			// differ between captures of the same traffic.
+			p.ports[fmt.Sprintf("%s/%d", flow.Protocol, flow.DstPort)] += packets
+			p.flows[fmt.Sprintf("%s %s to %s:%d", flow.Protocol, flow.SrcIp, flow.DstIp, flow.DstPort)] += packets
+		default:
+			p.flows[fmt.Sprintf("%s %s to %s", flow.Protocol, flow.SrcIp, flow.DstIp)] += packets
+		}
+	}
+	return nil
+}
+
+func addJSONCounts(counts map[string]float64, raw sql.NullString, prefix string) {
+	if !raw.Valid || raw.String == "" {
+		return
+	}
+	var parsed map[string]int
+	if err := json.Unmarshal([]byte(raw.String), &parsed); err != nil {
+		return
+	}
+	for key, count := range parsed {
+		counts[prefix+key] += float64(count)
+	}
+}
+
+// average turns the sums of n captures into per-capture values.
+func (p *diffProfile) average(n int) {
+	if n <= 1 {
+		return
+	}
+	p.packets /= float64(n)
+	for _, counts := range []map[string]float64{p.hosts, p.ports, p.protocols, p.flows} {
+		for key := range counts {
+			counts[key] /= float64(n)
+		}
+	}
+}
+
+func roundDiff(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// diffCounts lists the keys only in b as new, only in a as missing and the
+// ones whose packet count changed by at least threshold (relative to a) as
+// changed. Each list is sorted by the size of the difference and cut to limit
+// entries unless limit is 0.
+func diffCounts(a, b map[string]float64, threshold float64, limit int) DiffSection {
+	section := DiffSection{New: []DiffEntry{}, Missing: []DiffEntry{}, Changed: []DiffEntry{}}
+
+	for key, countB := range b {
+		countA, ok := a[key]
+		if !ok {
+			section.New = append(section.New, DiffEntry{Key: key, PacketsB: roundDiff(countB)})
+			continue
+		}
+		change := (countB - countA) / countA
+		if math.Abs(change) < threshold || max(countA, countB) < diffMinPackets {
+			continue
+		}
+		change = roundDiff(change)
+		section.Changed = append(section.Changed, DiffEntry{
+			Key:      key,
+			PacketsA: roundDiff(countA),
+			PacketsB: roundDiff(countB),
+			Change:   &change,
+		})
+	}
+	for key, countA := range a {
+		if _, ok := b[key]; !ok {
+			section.Missing = append(section.Missing, DiffEntry{Key: key, PacketsA: roundDiff(countA)})
+		}
+	}
+
+	for _, entries := range [][]DiffEntry{section.New, section.Missing, section.Changed} {
+		sort.Slice(entries, func(i, j int) bool {
+			di := math.Abs(entries[i].PacketsB - entries[i].PacketsA)
+			dj := math.Abs(entries[j].PacketsB - entries[j].PacketsA)
+			if di != dj {
+				return di > dj
+			}
+			return entries[i].Key < entries[j].Key
+		})
+	}
+
+	if limit > 0 {
+		for _, entries := range []*[]DiffEntry{&section.New, &section.Missing, &section.Changed} {
+			if len(*entries) > limit {
+				*entries = (*entries)[:limit]
+				section.Truncated = true
+			}
+		}
+	}
+	return section
+}
+
+// DiffHandler compares capture b with capture a, or with the per-capture
+// average of all captures of the same hostname in the baseline scenario.
+func (s *Server) DiffHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	bID, err := strconv.ParseInt(query.Get("b"), 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "b", query.Get("b"))
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	var aID int64
+	baseline := query.Get("baseline")
+	if aParam := query.Get("a"); aParam != "" {
+		aID, err = strconv.ParseInt(aParam, 10, 64)
+		if err != nil {
+			s.logger.Error("Invalid capture ID", "error", err, "a", aParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+	if (aID == 0) == (baseline == "") {
+		s.logger.Error("Diff needs either a or baseline", "a", query.Get("a"), "baseline", baseline)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	threshold := defaultDiffThreshold
+	if thresholdParam := query.Get("threshold"); thresholdParam != "" {
+		threshold, err = strconv.ParseFloat(thresholdParam, 64)
+		if err != nil || threshold < 0 {
+			s.logger.Error("Invalid diff threshold", "error", err, "threshold", thresholdParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	limit := defaultDiffLimit
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 0 {
+			s.logger.Error("Invalid diff limit", "error", err, "limit", limitParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	ctx := context.Background()
+	captureB, err := store.GetCapture(ctx, bID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			s.logger.Error("Failed to get capture", "error", err, "id", bID)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	sideA := DiffSide{CaptureIDs: []int64{}}
+	if aID != 0 {
+		captureA, err := store.GetCapture(ctx, aID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+			} else {
+				s.logger.Error("Failed to get capture", "error", err, "id", aID)
+				jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			}
+			return
+		}
+		sideA.CaptureIDs = append(sideA.CaptureIDs, aID)
+		sideA.Hostname = captureA.Hostname
+		sideA.Scenario = captureA.Scenario
+	} else {
+		captures, err := store.GetCapturesByHostname(ctx, captureB.Hostname)
+		if err != nil {
+			s.logger.Error("Failed to get captures by hostname", "error", err, "hostname", captureB.Hostname)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			return
+		}
+		for _, c := range captures {
+			if c.Scenario == baseline && c.ID != bID {
+				sideA.CaptureIDs = append(sideA.CaptureIDs, c.ID)
+			}
+		}
+		if len(sideA.CaptureIDs) == 0 {
+			s.logger.Error("No baseline captures", "hostname", captureB.Hostname, "scenario", baseline)
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+			return
+		}
+		sort.Slice(sideA.CaptureIDs, func(i, j int) bool { return sideA.CaptureIDs[i] < sideA.CaptureIDs[j] })
+		sideA.Hostname = captureB.Hostname
+		sideA.Scenario = baseline
+	}
+
+	profileA := newDiffProfile()
+	for _, id := range sideA.CaptureIDs {
+		if err := profileA.add(ctx, store, id); err != nil {
+			s.logger.Error("Failed to load capture stats", "error", err, "id", id)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			return
+		}
+	}
+	profileA.average(len(sideA.CaptureIDs))
+	sideA.Packets = roundDiff(profileA.packets)
+
+	profileB := newDiffProfile()
+	if err := profileB.add(ctx, store, bID); err != nil {
+		s.logger.Error("Failed to load capture stats", "error", err, "id", bID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	result := DiffRes{
+		A: sideA,
+		B: DiffSide{
+			CaptureIDs: []int64{bID},
+			Hostname:   captureB.Hostname,
+			Scenario:   captureB.Scenario,
+			Packets:    profileB.packets,
+		},
+		Threshold: threshold,
+		Hosts:     diffCounts(profileA.hosts, profileB.hosts, threshold, limit),
+		Ports:     diffCounts(profileA.ports, profileB.ports, threshold, limit),
+		Protocols: diffCounts(profileA.protocols, profileB.protocols, threshold, limit),
+		Flows:     diffCounts(profileA.flows, profileB.flows, threshold, limit),
+	}
+
+	jsonResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,104 @@
+package sorter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// domainPattern turns a domain filter into a LIKE pattern. A leading "*."
+// matches every subdomain of the name, but not the name itself.
+func domainPattern(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		return "%." + likeEscaper.Replace(rest)
+	}
+	return likeEscaper.Replace(name)
+}
+
+// searchDNS returns the DNS queries for a domain filter. Names without a
+// wildcard are compared with =, which unlike LIKE can use the index on name.
+func searchDNS(ctx context.Context, store *db.Store, name string) ([]sqlc.SearchDNSByNameRow, error) {
+	exact := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if strings.HasPrefix(exact, "*.") {
+		return store.SearchDNSByName(ctx, domainPattern(name))
+	}
+	rows, err := store.SearchDNSByExactName(ctx, exact)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]sqlc.SearchDNSByNameRow, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, sqlc.SearchDNSByNameRow(row))
+	}
+	return results, nil
+}
+
+func (s *Server) SearchDNSHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if strings.TrimSpace(name) == "" {
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	rows, err := searchDNS(context.Background(), store, name)
+	if err != nil {
+		s.logger.Error("Failed to search DNS queries", "error", err, "name", name)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	results := make([]DNSResult, 0, len(rows))
+	captureIDs := make([]int64, 0)
+	seen := make(map[int64]bool)
+	for _, row := range rows {
+		result := DNSResult{
+			CaptureID:       row.CaptureID,
+			Hostname:        row.Hostname,
+			Scenario:        row.Scenario,
+			CaptureDatetime: row.CaptureDatetime.Format(time.RFC3339),
+			QueryTime:       row.QueryTime.Format(time.RFC3339Nano),
+			ClientIP:        row.ClientIp,
+			ServerIP:        row.ServerIp,
+			Name:            row.Name,
+			RecordType:      row.RecordType,
+			ResponseCode:    row.ResponseCode.String,
+		}
+		if row.ResponseTime.Valid {
+			result.ResponseTime = row.ResponseTime.Time.Format(time.RFC3339Nano)
+		}
+		if row.Answers.Valid && row.Answers.String != "" {
+			var answers []string
+			if err := json.Unmarshal([]byte(row.Answers.String), &answers); err == nil {
+				result.Answers = answers
+			}
+		}
+		results = append(results, result)
+
+		if !seen[row.CaptureID] {
+			seen[row.CaptureID] = true
+			captureIDs = append(captureIDs, row.CaptureID)
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, DNSSearchRes{
+		Name:       name,
+		CaptureIDs: captureIDs,
+		Results:    results,
+		Count:      len(results),
+	})
+}
@@ -17,6 +17,12 @@ import (
 func (s *Server) ExportStoreHandler(w http.ResponseWriter, r *http.Request) {
 	cfg := s.GetConfig()
 
+	anonymizer, err := s.anonymizerFromQuery(r.URL.Query())
+	if err != nil {
+		s.writeAnonymizeError(w, err)
+		return
+	}
+
 	tmpFile, err := os.CreateTemp("", "pcapstore-export-*.tar.gz")
 	if err != nil {
 		s.logger.Error("Failed to create temporary file", "error", err)
@@ -46,7 +52,10 @@ func (s *Server) ExportStoreHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	dbPath := filepath.Join(cwd, "pcapStore.db")
-	if _, err := os.Stat(dbPath); err == nil {
+	if anonymizer != nil {
+		// The flow, DNS and TLS tables hold the original addresses.
+		s.logger.Warn("Leaving the database out of the anonymized export")
+	} else if _, err := os.Stat(dbPath); err == nil {
 		if err := addFileToTar(tarWriter, dbPath, "pcapStore.db"); err != nil {
 			s.logger.Error("Failed to add database to archive", "error", err)
 			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
@@ -92,14 +101,26 @@ func (s *Server) ExportStoreHandler(w http.ResponseWriter, r *http.Request) {
 			relPath = filepath.Join("organized", relPath)
 		}
 
+		if anonymizer != nil {
+			relPath = filepath.Join(filepath.Dir(relPath), pcapFileName(filePath, ""))
+			if err := addAnonymizedToTar(tarWriter, anonymizer, filePath, relPath); err != nil {
+				s.logger.Warn("Failed to add anonymized file to archive", "path", filePath, "error", err)
+			}
+			continue
+		}
+
 		if err := addFileToTar(tarWriter, filePath, relPath); err != nil {
 			s.logger.Warn("Failed to add file to archive", "path", filePath, "error", err)
 			continue
 		}
 	}
 
-	if err := addDirectoryToTar(tarWriter, cfg.ArchiveDir, "archive"); err != nil {
-		s.logger.Warn("Failed to add archive directory", "error", err)
+	// Only stored captures can be anonymized, anything else in the archive
+	// directory stays out.
+	if anonymizer == nil {
+		if err := addDirectoryToTar(tarWriter, cfg.ArchiveDir, "archive"); err != nil {
+			s.logger.Warn("Failed to add archive directory", "error", err)
+		}
 	}
 
 	tarWriter.Close()
@@ -0,0 +1,125 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+)
+
+// pcapFileName derives the name of a pcap written from a stored file, e.g.
+// "host-http-....pcap.gz" with suffix "extract" becomes
+// "host-http-...-extract.pcap".
+func pcapFileName(filePath, suffix string) string {
+	name := strings.TrimSuffix(filepath.Base(filePath), ".gz")
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if suffix != "" {
+		name += "-" + suffix
+	}
+	return name + ".pcap"
+}
+
+// ExtractFileHandler streams a pcap holding only the packets of a stored
+// capture that match the bpf filter and fall into the from/to window,
+// anonymized if asked to.
+func (s *Server) ExtractFileHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	captureID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	query := r.URL.Query()
+	var opts capture.ExtractOptions
+	if expr := strings.TrimSpace(query.Get("bpf")); expr != "" {
+		opts.Filter, err = capture.ParseFilter(expr)
+		if err != nil {
+			s.logger.Error("Invalid filter expression", "error", err, "bpf", expr)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+	if from := query.Get("from"); from != "" {
+		opts.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			s.logger.Error("Invalid from time", "error", err, "from", from)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		opts.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			s.logger.Error("Invalid to time", "error", err, "to", to)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+	if !opts.From.IsZero() && !opts.To.IsZero() && !opts.To.After(opts.From) {
+		s.logger.Error("Extract window is empty", "from", opts.From, "to", opts.To)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	opts.Anonymizer, err = s.anonymizerFromQuery(query)
+	if err != nil {
+		s.writeAnonymizeError(w, err)
+		return
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	captureRow, err := store.GetCapture(context.Background(), captureID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			s.logger.Error("Failed to get capture", "error", err, "id", captureID)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	src, closer, err := capture.OpenFile(captureRow.FilePath)
+	if err != nil {
+		s.logger.Error("Failed to open capture", "error", err, "path", captureRow.FilePath)
+		if errors.Is(err, os.ErrNotExist) {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+	defer closer.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", pcapFileName(captureRow.FilePath, "extract")))
+	w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+
+	// Headers are gone once packets are streamed, errors can only be logged.
+	written, err := capture.Extract(src, w, opts)
+	if err != nil {
+		s.logger.Error("Failed to extract capture", "error", err, "id", captureID, "packets_written", written)
+		return
+	}
+
+	cfg := s.GetConfig()
+	if cfg.LogLevel == "info" {
+		s.logger.Info("Extracted packets", "id", captureID, "packets", written)
+	}
+}
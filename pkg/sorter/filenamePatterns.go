@@ -0,0 +1,112 @@
+package sorter
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+)
+
+// defaultFilenamePattern is the {hostname}_{scenario}_{YYYYMMDD_HHmmss}
+// format, which is always tried first.
+var defaultFilenamePattern = config.FilenamePattern{
+	Name:            "default",
+	Regex:           `^\{(?P<hostname>[a-zA-Z0-9_-]+)\}_\{(?P<scenario>[a-zA-Z0-9_-]+)\}_\{(?P<timestamp>\d{8}_\d{6})\}$`,
+	TimestampLayout: "20060102_150405",
+}
+
+type filenamePattern struct {
+	config.FilenamePattern
+	re *regexp.Regexp
+}
+
+// compileFilenamePatterns compiles the built-in pattern followed by the
+// configured ones and checks that each can produce a hostname and scenario.
+func compileFilenamePatterns(patterns []config.FilenamePattern) ([]filenamePattern, error) {
+	all := append([]config.FilenamePattern{defaultFilenamePattern}, patterns...)
+	compiled := make([]filenamePattern, 0, len(all))
+	for i, pattern := range all {
+		re, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("filename pattern %d (%s): %w", i, pattern.Name, err)
+		}
+		if re.SubexpIndex("hostname") < 0 && pattern.Hostname == "" {
+			return nil, fmt.Errorf("filename pattern %d (%s) has neither a hostname group nor a hostname", i, pattern.Name)
+		}
+		if re.SubexpIndex("scenario") < 0 && pattern.Scenario == "" {
+			return nil, fmt.Errorf("filename pattern %d (%s) has neither a scenario group nor a scenario", i, pattern.Name)
+		}
+		if re.SubexpIndex("timestamp") >= 0 && pattern.TimestampLayout == "" {
+			return nil, fmt.Errorf("filename pattern %d (%s) has a timestamp group but no timestamp_layout", i, pattern.Name)
+		}
+		compiled = append(compiled, filenamePattern{FilenamePattern: pattern, re: re})
+	}
+	return compiled, nil
+}
+
+// ValidateFilenamePatterns reports configured patterns that can't be used.
+func ValidateFilenamePatterns(patterns []config.FilenamePattern) error {
+	_, err := compileFilenamePatterns(patterns)
+	return err
+}
+
+// match fills in result from base, the filename without its extension. The
+// returned bool reports whether the pattern matched at all.
+func (p filenamePattern) match(base string, result *FilenameValidationResult) bool {
+	matches := p.re.FindStringSubmatch(base)
+	if matches == nil {
+		return false
+	}
+	group := func(name string) string {
+		if i := p.re.SubexpIndex(name); i >= 0 {
+			return matches[i]
+		}
+		return ""
+	}
+
+	result.Pattern = p.Name
+	result.Hostname = group("hostname")
+	if result.Hostname == "" {
+		result.Hostname = p.Hostname
+	}
+	result.Scenario = group("scenario")
+	if result.Scenario == "" {
+		result.Scenario = p.Scenario
+	}
+	// Both end up in paths of the organized directory.
+	if !validNamePart.MatchString(result.Hostname) || !validNamePart.MatchString(result.Scenario) {
+		result.Error = fmt.Sprintf("Invalid hostname or scenario %q/%q (pattern %s)", result.Hostname, result.Scenario, p.Name)
+		return true
+	}
+
+	timestamp := group("timestamp")
+	if timestamp == "" {
+		result.IsValid = true
+		result.TimeFromPackets = true
+		return true
+	}
+	captureDateTime, err := time.Parse(p.TimestampLayout, timestamp)
+	if err != nil {
+		result.Error = fmt.Sprintf("Invalid datetime (pattern %s): %s", p.Name, err)
+		return true
+	}
+	result.IsValid = true
+	result.CaptureDateTime = captureDateTime.UTC()
+	return true
+}
+
+// setTimeFromPackets fills in the capture time of a file whose name doesn't
+// carry one from its first packet. The returned error is why it couldn't,
+// a *capture.CorruptError if the capture can't be read.
+func setTimeFromPackets(filePath string, result *FilenameValidationResult) error {
+	firstPacket, err := capture.FirstPacketTime(filePath)
+	if err != nil {
+		result.IsValid = false
+		result.Error = "No datetime in filename and none in the capture: " + err.Error()
+		return err
+	}
+	result.CaptureDateTime = firstPacket.UTC().Truncate(time.Second)
+	return nil
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
 )
 
@@ -64,7 +70,42 @@ func (s *Server) GetFileHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	captureJSON, marshalErr := json.Marshal(capture)
+	metadataRows, err := store.GetCaptureMetadata(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture metadata", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	tags, err := store.GetCaptureTags(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get tags", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	duplicates, err := store.GetCaptureDuplicates(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get duplicates", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	res := FileRes{Capture: capture, Tags: tags}
+	for _, dup := range duplicates {
+		res.Duplicates = append(res.Duplicates, DuplicateResult{
+			Filename:        dup.Filename,
+			Hostname:        dup.Hostname,
+			Scenario:        dup.Scenario,
+			CaptureDatetime: dup.CaptureDatetime.Format(time.RFC3339),
+			CreatedAt:       dup.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	if len(metadataRows) > 0 {
+		res.Metadata = make(map[string]string, len(metadataRows))
+		for _, row := range metadataRows {
+			res.Metadata[row.Key] = row.Value
+		}
+	}
+
+	captureJSON, marshalErr := json.Marshal(res)
 	if marshalErr != nil {
 		s.logger.Error("Failed to marshal capture", "error", marshalErr)
 		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
@@ -127,6 +168,12 @@ func (s *Server) FileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	anonymizer, err := s.anonymizerFromQuery(r.URL.Query())
+	if err != nil {
+		s.writeAnonymizeError(w, err)
+		return
+	}
+
 	store, dbErr := db.InitIfNeeded()
 	if dbErr != nil {
 		s.logger.Error("Failed to initialize database", "error", dbErr)
@@ -145,6 +192,11 @@ func (s *Server) FileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if anonymizer != nil {
+		s.serveAnonymized(w, capture, anonymizer)
+		return
+	}
+
 	filePath := capture.FilePath
 	fileName := filepath.Base(filePath)
 
@@ -173,3 +225,164 @@ func (s *Server) FileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 
 	http.ServeContent(w, r, fileName, fileStat.ModTime(), file)
 }
+
+func (s *Server) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		s.logger.Error("Failed to read multipart upload", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	cfg := s.GetConfig()
+	results := []UploadResult{}
+	statusCode := http.StatusCreated
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.logger.Error("Failed to read multipart part", "error", err)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		result := s.uploadPart(cfg, part)
+		part.Close()
+		switch result.Status {
+		case "invalid":
+			if statusCode == http.StatusCreated {
+				statusCode = http.StatusUnprocessableEntity
+			}
+		case "corrupt":
+			// A salvaged capture was stored and counts as created.
+			if result.CaptureID == 0 && statusCode == http.StatusCreated {
+				statusCode = http.StatusUnprocessableEntity
+			}
+		case "duplicate":
+			if statusCode == http.StatusCreated {
+				statusCode = http.StatusConflict
+			}
+		case "error":
+			statusCode = http.StatusInternalServerError
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	jsonResponse(w, statusCode, UploadRes{
+		Results: results,
+		Count:   len(results),
+	})
+}
+
+// uploadPart streams a single uploaded file into a hidden staging file inside
+// the watch dir and runs it through the same pipeline as watched files.
+func (s *Server) uploadPart(cfg config.Config, part *multipart.Part) UploadResult {
+	filename := filepath.Base(part.FileName())
+	result := UploadResult{Filename: filename}
+
+	// Uploads are staged in the watch dir, so its catch-all pattern applies.
+	validation, _ := parseFilename(filename, filenamePatternsFor(cfg, filepath.Join(cfg.WatchDir, filename)))
+	if !validation.IsValid {
+		if cfg.LogLevel == "info" {
+			s.logger.Warn("Rejected upload", "filename", filename, "error", validation.Error)
+		}
+		result.Status = "invalid"
+		result.Error = validation.Error
+		return result
+	}
+
+	tmpFile, err := os.CreateTemp(cfg.WatchDir, ".upload-*")
+	if err != nil {
+		s.logger.Error("Failed to create upload file", "error", err)
+		result.Status = "error"
+		result.Error = "failed to create upload file"
+		return result
+	}
+	tmpPath := tmpFile.Name()
+
+	_, copyErr := io.Copy(tmpFile, part)
+	closeErr := tmpFile.Close()
+	if copyErr != nil || closeErr != nil {
+		os.Remove(tmpPath)
+		s.logger.Error("Failed to write upload", "filename", filename, "copy_error", copyErr, "close_error", closeErr)
+		result.Status = "error"
+		result.Error = "failed to write upload"
+		return result
+	}
+
+	if validation.TimeFromPackets {
+		timeErr := setTimeFromPackets(tmpPath, &validation)
+		if !validation.IsValid {
+			os.Remove(tmpPath)
+			if cfg.LogLevel == "info" {
+				s.logger.Warn("Rejected upload", "filename", filename, "error", validation.Error)
+			}
+			result.Status = "invalid"
+			var corrupt *capture.CorruptError
+			if errors.As(timeErr, &corrupt) {
+				result.Status = "corrupt"
+			}
+			result.Error = validation.Error
+			return result
+		}
+	}
+
+	s.ingest.ingesting.RLock()
+	captureID, corrupt, ingestErr := ingestFile(cfg, tmpPath, validation, nil, nil, s.logger)
+	s.ingest.ingesting.RUnlock()
+	var dup *duplicateError
+	if errors.As(ingestErr, &dup) {
+		os.Remove(tmpPath)
+		result.CaptureID = dup.CaptureID
+		if cfg.DuplicatePolicy == config.DuplicatePolicyLink {
+			if err := linkDuplicate(dup.CaptureID, filename, validation); err != nil {
+				s.logger.Error("Failed to link duplicate upload", "filename", filename, "error", err)
+				result.Status = "error"
+				result.Error = "failed to link duplicate"
+				return result
+			}
+			result.Status = "linked"
+			return result
+		}
+		result.Status = "duplicate"
+		result.Error = dup.Error()
+		return result
+	}
+	if errors.As(ingestErr, &corrupt) {
+		os.Remove(tmpPath)
+		if cfg.LogLevel == "info" {
+			s.logger.Warn("Rejected unreadable upload", "filename", filename, "error", ingestErr)
+		}
+		result.Status = "corrupt"
+		result.Error = corrupt.Error()
+		return result
+	}
+	if ingestErr != nil {
+		os.Remove(tmpPath)
+		s.logger.Error("Failed to ingest upload", "filename", filename, "error", ingestErr)
+		result.Status = "error"
+		result.Error = ingestErr.Error()
+		return result
+	}
+
+	result.Status = "ok"
+	result.CaptureID = captureID
+	if corrupt != nil {
+		// Stored with the packets before the damage.
+		result.Status = "corrupt"
+		result.Error = corrupt.Error()
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+)
+
+func findingResult(id, captureID int64, rule, severity, summary, evidence string, firstSeen sql.NullTime) FindingResult {
+	result := FindingResult{
+		ID:        id,
+		CaptureID: captureID,
+		Rule:      rule,
+		Severity:  severity,
+		Summary:   summary,
+	}
+	if evidence != "" {
+		var parsed map[string]any
+		if err := json.Unmarshal([]byte(evidence), &parsed); err == nil {
+			result.Evidence = parsed
+		}
+	}
+	if firstSeen.Valid {
+		result.FirstSeen = firstSeen.Time.Format(time.RFC3339Nano)
+	}
+	return result
+}
+
+// GetFindingsHandler lists detector findings across all captures. The
+// severity filter is a minimum, so severity=medium also returns high.
+func (s *Server) GetFindingsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	rule := strings.ToLower(query.Get("rule"))
+	hostname := query.Get("hostname")
+	scenario := query.Get("scenario")
+
+	var minSeverity int
+	if severity := strings.ToLower(query.Get("severity")); severity != "" {
+		minSeverity = capture.SeverityRank(severity)
+		if minSeverity == 0 {
+			s.logger.Error("Invalid severity", "severity", severity)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	var captureID int64
+	if idParam := query.Get("capture_id"); idParam != "" {
+		var err error
+		captureID, err = strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	rows, err := store.ListFindings(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to list findings", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	findings := make([]FindingResult, 0, len(rows))
+	for _, row := range rows {
+		if rule != "" && row.Rule != rule {
+			continue
+		}
+		if minSeverity != 0 && capture.SeverityRank(row.Severity) < minSeverity {
+			continue
+		}
+		if captureID != 0 && row.CaptureID != captureID {
+			continue
+		}
+		if hostname != "" && row.Hostname != hostname {
+			continue
+		}
+		if scenario != "" && row.Scenario != scenario {
+			continue
+		}
+
+		result := findingResult(row.ID, row.CaptureID, row.Rule, row.Severity, row.Summary, row.Evidence, row.FirstSeen)
+		result.Hostname = row.Hostname
+		result.Scenario = row.Scenario
+		result.CaptureDatetime = row.CaptureDatetime.Format(time.RFC3339)
+		findings = append(findings, result)
+	}
+
+	jsonResponse(w, http.StatusOK, FindingsRes{
+		Findings: findings,
+		Count:    len(findings),
+	})
+}
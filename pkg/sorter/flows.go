@@ -0,0 +1,177 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+// flowSortKeys maps the ?sort= values to a "less" function. Volume based keys
+// sort descending by default, time based keys ascending.
+var flowSortKeys = map[string]func(a, b sqlc.CaptureFlow) bool{
+	"bytes": func(a, b sqlc.CaptureFlow) bool {
+		return a.BytesSrcToDst+a.BytesDstToSrc < b.BytesSrcToDst+b.BytesDstToSrc
+	},
+	"packets": func(a, b sqlc.CaptureFlow) bool {
+		return a.PacketsSrcToDst+a.PacketsDstToSrc < b.PacketsSrcToDst+b.PacketsDstToSrc
+	},
+	"duration": func(a, b sqlc.CaptureFlow) bool {
+		return a.LastSeen.Sub(a.FirstSeen) < b.LastSeen.Sub(b.FirstSeen)
+	},
+	"first_seen": func(a, b sqlc.CaptureFlow) bool {
+		return a.FirstSeen.Before(b.FirstSeen)
+	},
+	"last_seen": func(a, b sqlc.CaptureFlow) bool {
+		return a.LastSeen.Before(b.LastSeen)
+	},
+	"rtt": func(a, b sqlc.CaptureFlow) bool {
+		return a.HandshakeRttMs.Float64 < b.HandshakeRttMs.Float64
+	},
+}
+
+func (s *Server) GetFileFlowsHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	captureID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	query := r.URL.Query()
+	protocol := strings.ToUpper(query.Get("protocol"))
+	ip := query.Get("ip")
+
+	var port int64 = -1
+	if portParam := query.Get("port"); portParam != "" {
+		port, err = strconv.ParseInt(portParam, 10, 64)
+		if err != nil || port < 0 || port > 65535 {
+			s.logger.Error("Invalid port", "error", err, "port", portParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	sortKey := query.Get("sort")
+	if sortKey == "" {
+		sortKey = "first_seen"
+	}
+	less, ok := flowSortKeys[sortKey]
+	if !ok {
+		s.logger.Error("Invalid sort key", "sort", sortKey)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	descending := sortKey == "bytes" || sortKey == "packets" || sortKey == "duration" || sortKey == "rtt"
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		s.logger.Error("Invalid sort order", "order", query.Get("order"))
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	limit := 0
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 0 {
+			s.logger.Error("Invalid limit", "error", err, "limit", limitParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	if _, err := store.GetCapture(context.Background(), captureID); err != nil {
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			s.logger.Error("Failed to get capture", "error", err, "id", captureID)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	flows, err := store.GetCaptureFlows(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture flows", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	filtered := make([]sqlc.CaptureFlow, 0, len(flows))
+	for _, flow := range flows {
+		if protocol != "" && strings.ToUpper(flow.Protocol) != protocol {
+			continue
+		}
+		if ip != "" && flow.SrcIp != ip && flow.DstIp != ip {
+			continue
+		}
+		if port >= 0 && flow.SrcPort != port && flow.DstPort != port {
+			continue
+		}
+		filtered = append(filtered, flow)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if descending {
+			return less(filtered[j], filtered[i])
+		}
+		return less(filtered[i], filtered[j])
+	})
+
+	total := len(filtered)
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+
+	results := make([]FlowResult, 0, len(filtered))
+	for _, flow := range filtered {
+		var handshakeRTT *float64
+		if flow.HandshakeRttMs.Valid {
+			handshakeRTT = &flow.HandshakeRttMs.Float64
+		}
+		results = append(results, FlowResult{
+			Protocol:        flow.Protocol,
+			SrcIP:           flow.SrcIp,
+			SrcPort:         flow.SrcPort,
+			DstIP:           flow.DstIp,
+			DstPort:         flow.DstPort,
+			PacketsSrcToDst: flow.PacketsSrcToDst,
+			BytesSrcToDst:   flow.BytesSrcToDst,
+			PacketsDstToSrc: flow.PacketsDstToSrc,
+			BytesDstToSrc:   flow.BytesDstToSrc,
+			TCPFlags:        flow.TcpFlags.String,
+			HandshakeRTTMs:  handshakeRTT,
+			FirstSeen:       flow.FirstSeen.Format(time.RFC3339Nano),
+			LastSeen:        flow.LastSeen.Format(time.RFC3339Nano),
+			DurationSeconds: flow.LastSeen.Sub(flow.FirstSeen).Seconds(),
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, FlowsRes{
+		CaptureID: captureID,
+		Flows:     results,
+		Count:     len(results),
+		Total:     total,
+	})
+}
@@ -0,0 +1,104 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+)
+
+func (s *Server) GetFileHTTPHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	captureID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	query := r.URL.Query()
+	method := strings.ToUpper(query.Get("method"))
+	host := strings.ToLower(query.Get("host"))
+	uri := query.Get("uri")
+
+	var status int64
+	if statusParam := query.Get("status"); statusParam != "" {
+		status, err = strconv.ParseInt(statusParam, 10, 64)
+		if err != nil {
+			s.logger.Error("Invalid status code", "error", err, "status", statusParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	if _, err := store.GetCapture(context.Background(), captureID); err != nil {
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			s.logger.Error("Failed to get capture", "error", err, "id", captureID)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	rows, err := store.GetCaptureHTTP(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture HTTP transactions", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	results := make([]HTTPResult, 0, len(rows))
+	for _, row := range rows {
+		if method != "" && row.Method.String != method {
+			continue
+		}
+		if host != "" && row.Host.String != host {
+			continue
+		}
+		if uri != "" && !strings.Contains(row.Uri.String, uri) {
+			continue
+		}
+		if status != 0 && row.StatusCode.Int64 != status {
+			continue
+		}
+
+		result := HTTPResult{
+			ClientIP:    row.ClientIp,
+			ClientPort:  row.ClientPort,
+			ServerIP:    row.ServerIp,
+			ServerPort:  row.ServerPort,
+			RequestTime: row.RequestTime.Format(time.RFC3339Nano),
+			Method:      row.Method.String,
+			Host:        row.Host.String,
+			URI:         row.Uri.String,
+			UserAgent:   row.UserAgent.String,
+			ContentType: row.ContentType.String,
+		}
+		if row.ResponseTime.Valid {
+			result.ResponseTime = row.ResponseTime.Time.Format(time.RFC3339Nano)
+		}
+		if row.StatusCode.Valid {
+			result.StatusCode = &row.StatusCode.Int64
+		}
+		results = append(results, result)
+	}
+
+	jsonResponse(w, http.StatusOK, HTTPRes{
+		CaptureID:    captureID,
+		Transactions: results,
+		Count:        len(results),
+	})
+}
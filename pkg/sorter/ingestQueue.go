@@ -0,0 +1,182 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/logger"
+)
+
+const (
+	defaultIngestWorkers = 4
+	maxIngestAttempts    = 5
+	// Retries wait ingestRetryBase, doubled per attempt up to ingestRetryMax.
+	ingestRetryBase = 30 * time.Second
+	ingestRetryMax  = 15 * time.Minute
+	// ingestPollInterval picks up retries whose backoff ran out.
+	ingestPollInterval = 5 * time.Second
+)
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// IngestQueue runs watched files through processFile on a fixed number of
+// workers. Jobs live in the ingest_jobs table, so queued work survives a
+// restart.
+type IngestQueue struct {
+	logger logger.Logger
+	// config returns the current config, so jobs see updates made through
+	// the API. Only ingest_workers needs a restart.
+	config func() config.Config
+	store  *db.Store
+	wake   chan struct{}
+	// ingesting is held for reading by everything that puts a file into the
+	// store, moves a stored one or rewrites its rows (jobs, uploads, merges,
+	// reanalysis, compression and archiving) and for writing by Reconcile,
+	// which mustn't see a file halfway.
+	ingesting sync.RWMutex
+}
+
+func NewIngestQueue(config func() config.Config, logger logger.Logger) *IngestQueue {
+	workers := config().IngestWorkers
+	if workers <= 0 {
+		workers = defaultIngestWorkers
+	}
+	return &IngestQueue{logger: logger, config: config, wake: make(chan struct{}, workers)}
+}
+
+// jobTime is the time stored in and compared against job rows. Whole UTC
+// seconds keep the stored strings comparable.
+func jobTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Start requeues jobs that were running when the server stopped and starts
+// the workers. The workers share one database handle, as they poll.
+func (q *IngestQueue) Start() error {
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		return err
+	}
+	q.store = store
+	requeued, err := store.RequeueRunningIngestJobs(context.Background(), jobTime())
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		q.logger.Info("Requeued interrupted ingest jobs", "count", requeued)
+	}
+
+	for i := 0; i < cap(q.wake); i++ {
+		go q.work()
+	}
+	return nil
+}
+
+// Enqueue adds a job for path unless one is already queued or running. It
+// reports whether it added one.
+func (q *IngestQueue) Enqueue(path string) (bool, error) {
+	ctx := context.Background()
+	if _, err := q.store.GetActiveIngestJobByPath(ctx, path); err == nil {
+		return false, nil
+	} else if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	id, err := q.store.InsertIngestJob(ctx, sqlc.InsertIngestJobParams{Path: path, Now: jobTime()})
+	if err != nil {
+		return false, err
+	}
+	if q.config().LogLevel == "info" {
+		q.logger.Info("Queued file", "path", path, "job", id)
+	}
+	q.Wake()
+	return true, nil
+}
+
+// Wake lets an idle worker look for jobs right away.
+func (q *IngestQueue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *IngestQueue) work() {
+	ticker := time.NewTicker(ingestPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for q.runNext() {
+		}
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and runs one job. It returns false if there was none.
+func (q *IngestQueue) runNext() bool {
+	ctx := context.Background()
+	job, err := q.store.ClaimIngestJob(ctx, jobTime())
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		q.logger.Error("Failed to claim ingest job", "error", err)
+		return false
+	}
+
+	// The wait for a sidecar doesn't hold the lock, so Reconcile isn't held
+	// up by captures that have none.
+	cfg := q.config()
+	sidecarPath := awaitSidecar(cfg, job.Path)
+
+	q.ingesting.RLock()
+	defer q.ingesting.RUnlock()
+
+	params := sqlc.FinishIngestJobParams{ID: job.ID, Status: JobDone}
+	if _, statErr := os.Stat(job.Path); errors.Is(statErr, os.ErrNotExist) {
+		// Nothing a retry could fix.
+		params.Status = JobFailed
+		params.LastError = nullString("file no longer exists")
+	} else if captureID, err := processFile(cfg, job.Path, sidecarPath, q.logger); err != nil {
+		q.logger.Error("Failed to ingest file", "path", job.Path, "job", job.ID, "attempt", job.Attempts, "error", err)
+		params.LastError = nullString(err.Error())
+		if job.Attempts >= maxIngestAttempts {
+			params.Status = JobFailed
+		} else {
+			params.Status = JobQueued
+			params.NextAttemptAt = sql.NullTime{Time: jobTime().Add(ingestBackoff(job.Attempts)), Valid: true}
+		}
+	} else if captureID != 0 {
+		params.CaptureID = sql.NullInt64{Int64: captureID, Valid: true}
+	}
+
+	params.UpdatedAt = jobTime()
+	if err := q.store.FinishIngestJob(ctx, params); err != nil {
+		q.logger.Error("Failed to update ingest job", "job", job.ID, "error", err)
+	}
+	return true
+}
+
+// ingestBackoff is the wait before the retry after the given attempt.
+func ingestBackoff(attempt int64) time.Duration {
+	backoff := ingestRetryBase
+	for i := int64(1); i < attempt && backoff < ingestRetryMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, ingestRetryMax)
+}
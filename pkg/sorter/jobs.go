@@ -0,0 +1,134 @@
+package sorter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+func jobResult(job sqlc.IngestJob) JobResult {
+	res := JobResult{
+		ID:        job.ID,
+		Path:      job.Path,
+		Status:    job.Status,
+		Attempts:  job.Attempts,
+		LastError: job.LastError.String,
+		CaptureID: job.CaptureID.Int64,
+		CreatedAt: job.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: job.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if job.NextAttemptAt.Valid {
+		res.NextAttemptAt = job.NextAttemptAt.Time.UTC().Format(time.RFC3339)
+	}
+	return res
+}
+
+// GetJobsHandler lists ingest jobs, newest first. The status parameter limits
+// the list to jobs in that state; the counts always cover every job.
+func (s *Server) GetJobsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", JobQueued, JobRunning, JobDone, JobFailed:
+	default:
+		s.logger.Error("Invalid job status", "status", status)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	jobs, err := store.ListIngestJobs(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to list ingest jobs", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	res := JobsRes{
+		Jobs:   []JobResult{},
+		Counts: map[string]int{JobQueued: 0, JobRunning: 0, JobDone: 0, JobFailed: 0},
+	}
+	for _, job := range jobs {
+		res.Counts[job.Status]++
+		if status != "" && job.Status != status {
+			continue
+		}
+		res.Jobs = append(res.Jobs, jobResult(job))
+	}
+	res.Count = len(res.Jobs)
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// RetryJobHandler puts a failed job back in the queue with a fresh set of
+// attempts. Jobs that aren't failed are left alone and give a 404.
+func (s *Server) RetryJobHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid job ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	retried, err := store.RetryIngestJob(context.Background(), sqlc.RetryIngestJobParams{UpdatedAt: jobTime(), ID: id})
+	if err != nil {
+		s.logger.Error("Failed to retry ingest job", "error", err, "id", id)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	if retried == 0 {
+		jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		return
+	}
+
+	s.wakeIngest()
+	jsonResponse(w, http.StatusOK, JobRetryRes{Status: "ok", Retried: retried})
+}
+
+// RetryFailedJobsHandler puts every failed job back in the queue.
+func (s *Server) RetryFailedJobsHandler(w http.ResponseWriter, r *http.Request) {
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	retried, err := store.RetryFailedIngestJobs(context.Background(), jobTime())
+	if err != nil {
+		s.logger.Error("Failed to retry ingest jobs", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	if retried > 0 {
+		s.wakeIngest()
+	}
+	jsonResponse(w, http.StatusOK, JobRetryRes{Status: "ok", Retried: retried})
+}
+
+// wakeIngest gets the workers onto retried jobs without waiting for their
+// next poll.
+func (s *Server) wakeIngest() {
+	if s.ingest != nil {
+		s.ingest.Wake()
+	}
+}
@@ -0,0 +1,257 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+// validNamePart matches what the filename format allows for hostnames and
+// scenarios.
+var validNamePart = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// mergeTarget derives hostname and scenario of an ingested merge from its
+// sources unless the request names them: a value shared by all sources is
+// kept, anything else becomes "merged". The scenario gets a "-merged" suffix.
+func mergeTarget(req MergeReq, captures []sqlc.Capture) FilenameValidationResult {
+	hostname, scenario := captures[0].Hostname, captures[0].Scenario
+	start := captures[0].CaptureDatetime
+	for _, c := range captures[1:] {
+		if c.Hostname != hostname {
+			hostname = "merged"
+		}
+		if c.Scenario != scenario {
+			scenario = ""
+		}
+		if c.CaptureDatetime.Before(start) {
+			start = c.CaptureDatetime
+		}
+	}
+	if scenario == "" {
+		scenario = "merged"
+	} else {
+		scenario += "-merged"
+	}
+
+	if req.Hostname != "" {
+		hostname = req.Hostname
+	}
+	if req.Scenario != "" {
+		scenario = req.Scenario
+	}
+	return FilenameValidationResult{
+		IsValid:         true,
+		Hostname:        hostname,
+		Scenario:        scenario,
+		CaptureDateTime: start,
+	}
+}
+
+func (s *Server) MergeHandler(w http.ResponseWriter, r *http.Request) {
+	var req MergeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("Failed to decode merge request", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Format == "" {
+		req.Format = capture.MergeFormatPcapng
+	}
+	if req.Format != capture.MergeFormatPcap && req.Format != capture.MergeFormatPcapng {
+		s.logger.Error("Invalid merge format", "format", req.Format)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	if len(req.IDs) == 0 && len(req.Search) == 0 {
+		s.logger.Error("Merge request names no captures")
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	for _, name := range []string{req.Hostname, req.Scenario} {
+		if name != "" && !validNamePart.MatchString(name) {
+			s.logger.Error("Invalid hostname or scenario for merged capture", "name", name)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	var captures []sqlc.Capture
+	for _, id := range req.IDs {
+		captureRow, err := store.GetCapture(context.Background(), id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+			} else {
+				s.logger.Error("Failed to get capture", "error", err, "id", id)
+				jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			}
+			return
+		}
+		captures = append(captures, captureRow)
+	}
+	if len(req.Search) > 0 {
+		params := url.Values{}
+		for key, value := range req.Search {
+			params.Set(key, value)
+		}
+		found, err := searchCaptures(context.Background(), store, params)
+		if err != nil {
+			s.logger.Error("Failed to search captures", "error", err)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			return
+		}
+		captures = append(captures, found...)
+	}
+
+	// Sources named twice (by ID and by the search) are merged once.
+	seen := make(map[int64]bool)
+	unique := captures[:0]
+	for _, c := range captures {
+		if !seen[c.ID] {
+			seen[c.ID] = true
+			unique = append(unique, c)
+		}
+	}
+	captures = unique
+	if len(captures) == 0 {
+		jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		return
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].ID < captures[j].ID })
+
+	sources := make([]capture.MergeSource, 0, len(captures))
+	sourceIDs := make([]int64, 0, len(captures))
+	for _, c := range captures {
+		src, closer, err := capture.OpenFile(c.FilePath)
+		if err != nil {
+			s.logger.Error("Failed to open capture", "error", err, "path", c.FilePath)
+			if errors.Is(err, os.ErrNotExist) {
+				jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+			} else {
+				jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			}
+			return
+		}
+		defer closer.Close()
+		sources = append(sources, capture.MergeSource{
+			Name:   fmt.Sprintf("%s/%s/%d", c.Hostname, c.Scenario, c.ID),
+			Reader: src,
+		})
+		sourceIDs = append(sourceIDs, c.ID)
+	}
+
+	if req.Ingest {
+		s.mergeAndIngest(w, req, captures, sources, sourceIDs)
+		return
+	}
+
+	if req.Format == capture.MergeFormatPcap {
+		// Checked up front, the status can't change once packets are streamed.
+		for _, source := range sources[1:] {
+			if source.Reader.LinkType() != sources[0].Reader.LinkType() {
+				s.logger.Error("Failed to merge captures", "error", capture.ErrMixedLinkTypes)
+				jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=merged.%s", req.Format))
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	written, err := capture.Merge(sources, w, req.Format)
+	if err != nil {
+		s.logger.Error("Failed to merge captures", "error", err, "ids", sourceIDs, "packets_written", written)
+		return
+	}
+
+	cfg := s.GetConfig()
+	if cfg.LogLevel == "info" {
+		s.logger.Info("Merged captures", "ids", sourceIDs, "packets", written)
+	}
+}
+
+// mergeAndIngest writes the merge into the watch directory and stores it as
+// a new capture instead of streaming it back.
+func (s *Server) mergeAndIngest(w http.ResponseWriter, req MergeReq, captures []sqlc.Capture, sources []capture.MergeSource, sourceIDs []int64) {
+	cfg := s.GetConfig()
+
+	tmpFile, err := os.CreateTemp(cfg.WatchDir, ".merge-*")
+	if err != nil {
+		s.logger.Error("Failed to create merge file", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	tmpPath := tmpFile.Name()
+
+	written, mergeErr := capture.Merge(sources, tmpFile, req.Format)
+	closeErr := tmpFile.Close()
+	if mergeErr != nil || closeErr != nil {
+		os.Remove(tmpPath)
+		s.logger.Error("Failed to merge captures", "ids", sourceIDs, "merge_error", mergeErr, "close_error", closeErr)
+		if errors.Is(mergeErr, capture.ErrMixedLinkTypes) {
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		} else {
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	target := mergeTarget(req, captures)
+	scenario := target.Scenario
+	var captureID int64
+	var exists *existsError
+	for n := 2; ; n++ {
+		s.ingest.ingesting.RLock()
+		captureID, _, err = ingestFile(cfg, tmpPath, target, nil, nil, s.logger)
+		s.ingest.ingesting.RUnlock()
+		// Merges that share their earliest capture get the same derived
+		// name, so it is counted up. A name the request asked for is kept.
+		if !errors.As(err, &exists) || req.Scenario != "" {
+			break
+		}
+		target.Scenario = fmt.Sprintf("%s-%d", scenario, n)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		s.logger.Error("Failed to ingest merged capture", "error", err, "ids", sourceIDs)
+		var dup *duplicateError
+		switch {
+		case errors.As(err, &dup):
+			jsonResponse(w, http.StatusConflict, StatusRes{Status: "duplicate"})
+		case errors.As(err, &exists):
+			jsonResponse(w, http.StatusConflict, MergeConflictRes{Status: "exists", Path: exists.Path})
+		default:
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, MergeRes{
+		CaptureID: captureID,
+		Hostname:  target.Hostname,
+		Scenario:  target.Scenario,
+		Sources:   sourceIDs,
+		Packets:   written,
+	})
+}
@@ -14,4 +14,3 @@ func (s *Server) simpleAuthMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
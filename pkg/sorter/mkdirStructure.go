@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
 )
 
@@ -12,6 +13,10 @@ func loadRootDirs(cfg config.Config) error {
 		cfg.WatchDir,
 		cfg.OrganizedDir,
 		cfg.ArchiveDir,
+		quarantineDir(cfg),
+	}
+	for _, dir := range cfg.WatchDirs {
+		dirs = append(dirs, dir.Path)
 	}
 
 	for _, dir := range dirs {
@@ -26,9 +31,13 @@ func loadRootDirs(cfg config.Config) error {
 
 func checkIfStructureExists(cfg config.Config) error {
 	dirs := map[string]string{
-		"watch":     cfg.WatchDir,
-		"organized": cfg.OrganizedDir,
-		"archive":   cfg.ArchiveDir,
+		"watch":      cfg.WatchDir,
+		"organized":  cfg.OrganizedDir,
+		"archive":    cfg.ArchiveDir,
+		"quarantine": quarantineDir(cfg),
+	}
+	for i, dir := range cfg.WatchDirs {
+		dirs[fmt.Sprintf("watch_dirs %d", i)] = dir.Path
 	}
 
 	for name, dir := range dirs {
@@ -52,10 +61,34 @@ func ValidateConfigPaths(cfg config.Config) error {
 		return fmt.Errorf("archive_dir is empty")
 	}
 
+	if err := ValidateWatchDirs(cfg); err != nil {
+		return err
+	}
+
 	if err := checkIfStructureExists(cfg); err != nil {
 		return err
 	}
 
+	if err := ValidateFilenamePatterns(cfg.FilenamePatterns); err != nil {
+		return err
+	}
+
+	if err := ValidateWatchMode(cfg.WatchMode); err != nil {
+		return err
+	}
+
+	if err := ValidateQuarantineDir(cfg); err != nil {
+		return err
+	}
+
+	if err := ValidateDuplicatePolicy(cfg.DuplicatePolicy); err != nil {
+		return err
+	}
+
+	if err := capture.ValidateAnalyzers(cfg.Analyzers); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -0,0 +1,83 @@
+package sorter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+const maxNoteLength = 4096
+
+func noteResult(note sqlc.CaptureNote) NoteResult {
+	return NoteResult{
+		ID:        note.ID,
+		CaptureID: note.CaptureID,
+		Text:      note.Body,
+		CreatedAt: note.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// AddNoteHandler adds a timestamped note to a capture.
+func (s *Server) AddNoteHandler(w http.ResponseWriter, r *http.Request) {
+	var req NoteReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("Failed to decode note request", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	defer r.Body.Close()
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" || len(text) > maxNoteLength {
+		s.logger.Error("Invalid note", "length", len(text))
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, capture, ok := s.lookupCapture(w, r)
+	if !ok {
+		return
+	}
+
+	note, err := store.InsertCaptureNote(context.Background(), sqlc.InsertCaptureNoteParams{
+		CaptureID: capture.ID,
+		Body:      text,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Error("Failed to add note", "error", err, "id", capture.ID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, noteResult(note))
+}
+
+// GetNotesHandler lists the notes of a capture, oldest first.
+func (s *Server) GetNotesHandler(w http.ResponseWriter, r *http.Request) {
+	store, capture, ok := s.lookupCapture(w, r)
+	if !ok {
+		return
+	}
+
+	notes, err := store.GetCaptureNotes(context.Background(), capture.ID)
+	if err != nil {
+		s.logger.Error("Failed to get notes", "error", err, "id", capture.ID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	results := make([]NoteResult, 0, len(notes))
+	for _, note := range notes {
+		results = append(results, noteResult(note))
+	}
+	jsonResponse(w, http.StatusOK, NotesRes{
+		CaptureID: capture.ID,
+		Notes:     results,
+		Count:     len(results),
+	})
+}
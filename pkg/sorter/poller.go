@@ -0,0 +1,95 @@
+package sorter
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/logger"
+)
+
+const defaultPollIntervalSeconds = 10
+
+// ValidateWatchMode reports an unknown watch_mode.
+func ValidateWatchMode(mode string) error {
+	switch mode {
+	case "", config.WatchModeNotify, config.WatchModePoll, config.WatchModeBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown watch_mode %q (notify, poll or both)", mode)
+}
+
+// polledFile is what the last scans saw of a file.
+type polledFile struct {
+	size    int64
+	modTime time.Time
+	// since is when the file was first seen with this size and mtime. It
+	// uses the local clock, as the mtime of a network mount comes from
+	// another one.
+	since  time.Time
+	queued bool
+}
+
+// pollWatchDirs scans the watch dirs every poll interval, for filesystems
+// that don't send events. A capture is queued once it has kept its size and
+// mtime for quietPeriod, and not again until either changes.
+func pollWatchDirs(cfg config.Config, queue *IngestQueue, logger logger.Logger) {
+	interval := time.Duration(cfg.PollIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultPollIntervalSeconds * time.Second
+	}
+	for _, dir := range allWatchDirs(cfg) {
+		logger.Info("Polling for changes", "directory", dir.Path, "recursive", dir.Recursive, "interval", interval)
+	}
+
+	files := make(map[string]*polledFile)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		seen := make(map[string]bool, len(files))
+		for _, dir := range allWatchDirs(cfg) {
+			err := walkWatchDir(dir, func(path string, isDir bool) {
+				if isDir || ignoreWatched(path) {
+					return
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					return
+				}
+				seen[path] = true
+
+				file, ok := files[path]
+				if !ok || file.size != info.Size() || !file.modTime.Equal(info.ModTime()) {
+					files[path] = &polledFile{size: info.Size(), modTime: info.ModTime(), since: now}
+					return
+				}
+				if file.queued || now.Sub(file.since) < quietPeriod(cfg) {
+					return
+				}
+
+				if cfg.LogLevel == "info" {
+					logger.Info("File finished writing", "file", path)
+				}
+				if _, err := queue.Enqueue(path); err != nil {
+					logger.Error("Failed to queue file", "path", path, "error", err)
+					return
+				}
+				file.queued = true
+			})
+			if err != nil {
+				logger.Error("Failed to scan watch directory", "path", dir.Path, "error", err)
+			}
+		}
+
+		for path := range files {
+			if !seen[path] {
+				delete(files, path)
+			}
+		}
+
+		<-ticker.C
+	}
+}
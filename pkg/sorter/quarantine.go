@@ -0,0 +1,309 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/logger"
+)
+
+const defaultQuarantineDir = "./data/captures/quarantine"
+
+const (
+	RejectInvalidName     = "invalid_name"
+	RejectInvalidSidecar  = "invalid_sidecar"
+	RejectDuplicate       = "duplicate"
+	RejectCorrupt         = "corrupt"
+	RejectOrphanedSidecar = "orphaned_sidecar"
+)
+
+const (
+	RejectionQuarantined = "quarantined"
+	RejectionResubmitted = "resubmitted"
+)
+
+func quarantineDir(cfg config.Config) string {
+	if cfg.QuarantineDir == "" {
+		return defaultQuarantineDir
+	}
+	return cfg.QuarantineDir
+}
+
+// ValidateQuarantineDir reports a quarantine dir the sorter would pick
+// rejected files up from again, as a watch dir or as untracked stored files.
+func ValidateQuarantineDir(cfg config.Config) error {
+	dir := quarantineDir(cfg)
+	for _, watched := range allWatchDirs(cfg) {
+		if filepath.Clean(watched.Path) == filepath.Clean(dir) || (watched.Recursive && isWithin(watched.Path, dir)) {
+			return fmt.Errorf("quarantine_dir %s is watched by %s", dir, watched.Path)
+		}
+	}
+	for _, stored := range []string{cfg.OrganizedDir, cfg.ArchiveDir} {
+		if stored != "" && isWithin(stored, dir) {
+			return fmt.Errorf("quarantine_dir %s is inside %s", dir, stored)
+		}
+	}
+	return nil
+}
+
+// quarantineName is a free name in the quarantine dir for a file called name,
+// and for its sidecar with sidecarExt if it has one.
+func quarantineName(dir, name, sidecarExt string) string {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for i := 0; ; i++ {
+		candidate := filepath.Join(dir, stamp+"-"+name)
+		if i > 0 {
+			candidate = filepath.Join(dir, fmt.Sprintf("%s-%d-%s", stamp, i, name))
+		}
+		if _, err := os.Stat(candidate); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if sidecarExt != "" {
+			if _, err := os.Stat(candidate + sidecarExt); !errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+		return candidate
+	}
+}
+
+// rejectFile moves a watched file the sorter won't take, and its sidecar, to
+// the quarantine dir and records why in the rejections table. If it can't be
+// moved it is renamed in place to .INCORRECT or .DUPLICATE instead, so the
+// watcher leaves it alone either way.
+func rejectFile(cfg config.Config, path, sidecarPath, reason, detail string, captureID int64, logger logger.Logger) {
+	err := quarantineFile(cfg, path, sidecarPath, reason, detail, captureID, logger)
+	if err == nil {
+		return
+	}
+	logger.Error("Failed to quarantine file", "path", path, "error", err)
+
+	suffix := ".INCORRECT"
+	if reason == RejectDuplicate {
+		suffix = ".DUPLICATE"
+	}
+	for _, p := range []string{path, sidecarPath} {
+		if p == "" {
+			continue
+		}
+		if err := os.Rename(p, p+suffix); err != nil {
+			logger.Error("Failed to rename file", "from", p, "to", p+suffix, "error", err)
+		}
+	}
+}
+
+func quarantineFile(cfg config.Config, path, sidecarPath, reason, detail string, captureID int64, logger logger.Logger) error {
+	dir := quarantineDir(cfg)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create quarantine dir: %w", err)
+	}
+
+	sidecarExt := strings.TrimPrefix(sidecarPath, path)
+	target := quarantineName(dir, filepath.Base(path), sidecarExt)
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+
+	params := sqlc.InsertRejectionParams{
+		OriginalName:   filepath.Base(path),
+		OriginalDir:    filepath.Dir(path),
+		QuarantinePath: target,
+		Reason:         reason,
+		Detail:         nullString(detail),
+		CaptureID:      sql.NullInt64{Int64: captureID, Valid: captureID != 0},
+		CreatedAt:      time.Now().UTC(),
+	}
+	if sidecarPath != "" {
+		if err := os.Rename(sidecarPath, target+sidecarExt); err != nil {
+			logger.Error("Failed to quarantine sidecar", "path", sidecarPath, "error", err)
+		} else {
+			params.SidecarPath = nullString(target + sidecarExt)
+		}
+	}
+
+	// The file is out of the watch dir from here on, so a failed insert
+	// only loses the record.
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		logger.Error("Failed to get database queries", "error", err)
+		return nil
+	}
+	id, err := store.InsertRejection(context.Background(), params)
+	if err != nil {
+		logger.Error("Failed to record rejection", "path", target, "error", err)
+		return nil
+	}
+	if cfg.LogLevel == "info" {
+		logger.Warn("Quarantined file", "from", path, "to", target, "reason", reason, "detail", detail, "rejection", id)
+	}
+	return nil
+}
+
+func rejectionResult(rejection sqlc.Rejection) RejectionResult {
+	res := RejectionResult{
+		ID:             rejection.ID,
+		OriginalName:   rejection.OriginalName,
+		OriginalDir:    rejection.OriginalDir,
+		QuarantinePath: rejection.QuarantinePath,
+		SidecarPath:    rejection.SidecarPath.String,
+		Reason:         rejection.Reason,
+		Detail:         rejection.Detail.String,
+		CaptureID:      rejection.CaptureID.Int64,
+		Status:         rejection.Status,
+		ResubmittedAs:  rejection.ResubmittedAs.String,
+		CreatedAt:      rejection.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if rejection.ResolvedAt.Valid {
+		res.ResolvedAt = rejection.ResolvedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return res
+}
+
+// GetRejectionsHandler lists rejected files, newest first. The status and
+// reason parameters filter the list.
+func (s *Server) GetRejectionsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	reason := r.URL.Query().Get("reason")
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	rejections, err := store.ListRejections(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to list rejections", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	res := RejectionsRes{Rejections: []RejectionResult{}}
+	for _, rejection := range rejections {
+		if (status != "" && rejection.Status != status) || (reason != "" && rejection.Reason != reason) {
+			continue
+		}
+		res.Rejections = append(res.Rejections, rejectionResult(rejection))
+	}
+	res.Count = len(res.Rejections)
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// RetryRejectionHandler moves a quarantined file back into the watch dir it
+// came from, under a new name if the request gives one, so it goes through
+// the pipeline again. Its sidecar goes with it.
+func (s *Server) RetryRejectionHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid rejection ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	var req RejectionRetryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.logger.Error("Failed to decode retry request", "error", err)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+	defer r.Body.Close()
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	ctx := context.Background()
+
+	rejection, err := store.GetRejection(ctx, id)
+	if err == sql.ErrNoRows {
+		jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get rejection", "error", err, "id", id)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	if rejection.Status != RejectionQuarantined {
+		jsonResponse(w, http.StatusConflict, StatusRes{Status: "error"})
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = rejection.OriginalName
+	}
+	if name != filepath.Base(name) || ignoreWatched(name) {
+		jsonResponse(w, http.StatusBadRequest, RejectionRetryRes{Status: "invalid", Error: "not a plain capture filename"})
+		return
+	}
+
+	cfg := s.GetConfig()
+	target := filepath.Join(rejection.OriginalDir, name)
+	// The watch dirs may have changed since the file was rejected.
+	if _, ok := watchDirFor(cfg, target); !ok {
+		target = filepath.Join(cfg.WatchDir, name)
+	}
+	if result, _ := parseFilename(name, filenamePatternsFor(cfg, target)); !result.IsValid {
+		jsonResponse(w, http.StatusBadRequest, RejectionRetryRes{Status: "invalid", Error: result.Error})
+		return
+	}
+	if _, err := os.Stat(target); !errors.Is(err, os.ErrNotExist) {
+		jsonResponse(w, http.StatusConflict, RejectionRetryRes{Status: "exists", Path: target})
+		return
+	}
+
+	// The sidecar goes first, so it's there when the capture shows up.
+	sidecarTarget := ""
+	if rejection.SidecarPath.Valid {
+		sidecarTarget = target + strings.TrimPrefix(rejection.SidecarPath.String, rejection.QuarantinePath)
+		if err := os.Rename(rejection.SidecarPath.String, sidecarTarget); err != nil {
+			s.logger.Error("Failed to move sidecar back", "from", rejection.SidecarPath.String, "error", err)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+			return
+		}
+	}
+	if err := os.Rename(rejection.QuarantinePath, target); err != nil {
+		s.logger.Error("Failed to move file back", "from", rejection.QuarantinePath, "to", target, "error", err)
+		// Keep the sidecar with the quarantined capture for the next retry.
+		if sidecarTarget != "" {
+			if err := os.Rename(sidecarTarget, rejection.SidecarPath.String); err != nil {
+				s.logger.Error("Failed to return sidecar to quarantine", "from", sidecarTarget, "to", rejection.SidecarPath.String, "error", err)
+			}
+		}
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	if _, err := store.ResolveRejection(ctx, sqlc.ResolveRejectionParams{
+		ResubmittedAs: nullString(target),
+		ResolvedAt:    sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:            id,
+	}); err != nil {
+		s.logger.Error("Failed to resolve rejection", "error", err, "id", id)
+	}
+	if cfg.LogLevel == "info" {
+		s.logger.Info("Resubmitted rejected file", "rejection", id, "path", target)
+	}
+
+	jsonResponse(w, http.StatusOK, RejectionRetryRes{Status: RejectionResubmitted, Path: target})
+}
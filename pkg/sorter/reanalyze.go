@@ -0,0 +1,152 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+func (s *Server) ReanalyzeFileHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	captureID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, dbErr := db.InitIfNeeded()
+	if dbErr != nil {
+		s.logger.Error("Failed to initialize database", "error", dbErr)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	captureRow, getCaptureErr := store.GetCapture(context.Background(), captureID)
+	if getCaptureErr != nil {
+		s.logger.Error("Failed to get capture", "error", getCaptureErr, "id", captureID)
+		if getCaptureErr == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	if err := s.reanalyzeCapture(captureRow.ID, captureRow.FilePath, store); err != nil {
+		s.logger.Error("Failed to reanalyze capture", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, ReanalyzeRes{
+		CaptureID:       captureID,
+		AnalyzerVersion: capture.AnalyzerVersion,
+		Status:          "ok",
+	})
+}
+
+func (s *Server) ReanalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	versionLt := int64(capture.AnalyzerVersion)
+	if versionParam := r.URL.Query().Get("analyzer_version_lt"); versionParam != "" {
+		parsed, err := strconv.ParseInt(versionParam, 10, 64)
+		if err != nil || parsed <= 0 {
+			s.logger.Error("Invalid analyzer version", "error", err, "analyzer_version_lt", versionParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+		versionLt = parsed
+	}
+
+	store, dbErr := db.InitIfNeeded()
+	if dbErr != nil {
+		s.logger.Error("Failed to initialize database", "error", dbErr)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	rows, err := store.GetCapturesForReanalysis(context.Background(), versionLt)
+	if err != nil {
+		s.logger.Error("Failed to get captures for reanalysis", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	if !s.reanalyzing.CompareAndSwap(false, true) {
+		jsonResponse(w, http.StatusConflict, StatusRes{Status: "busy"})
+		return
+	}
+
+	go s.reanalyzeAll(rows, store)
+
+	jsonResponse(w, http.StatusAccepted, ReanalyzeTriggerRes{
+		Queued:            len(rows),
+		AnalyzerVersionLt: versionLt,
+		AnalyzerVersion:   capture.AnalyzerVersion,
+	})
+}
+
+func (s *Server) reanalyzeAll(rows []sqlc.GetCapturesForReanalysisRow, store *db.Store) {
+	defer s.reanalyzing.Store(false)
+
+	cfg := s.GetConfig()
+	if cfg.LogLevel == "info" {
+		s.logger.Info("Starting bulk reanalysis", "count", len(rows))
+	}
+
+	failed := 0
+	for _, row := range rows {
+		if err := s.reanalyzeCapture(row.ID, row.FilePath, store); err != nil {
+			failed++
+			s.logger.Error("Failed to reanalyze capture", "error", err, "id", row.ID)
+		}
+	}
+
+	if cfg.LogLevel == "info" {
+		s.logger.Info("Completed bulk reanalysis", "processed", len(rows)-failed, "failed", failed)
+	}
+}
+
+func (s *Server) reanalyzeCapture(id int64, filePath string, store *db.Store) error {
+	cfg := s.GetConfig()
+
+	if cfg.LogLevel == "info" {
+		s.logger.Info("Reanalyzing capture", "path", filePath, "id", id)
+	}
+
+	s.ingest.ingesting.RLock()
+	defer s.ingest.ingesting.RUnlock()
+
+	res, corrupt, err := analyzeStored(cfg, filePath)
+	if err != nil {
+		return err
+	}
+
+	analysisParams, err := buildAnalysisParams(res)
+	if err != nil {
+		return err
+	}
+
+	if err := store.ReplaceCaptureAnalysis(context.Background(), id, analysisParams); err != nil {
+		return fmt.Errorf("failed to replace capture analysis: %w", err)
+	}
+
+	isCorrupt, corruptPacket, corruptError := corruptColumns(corrupt)
+	if err := store.SetCaptureCorrupt(context.Background(), sqlc.SetCaptureCorruptParams{
+		Corrupt:       isCorrupt,
+		CorruptPacket: corruptPacket,
+		CorruptError:  corruptError,
+		ID:            id,
+	}); err != nil {
+		return fmt.Errorf("failed to update corrupt flag: %w", err)
+	}
+
+	return nil
+}
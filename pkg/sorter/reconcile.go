@@ -0,0 +1,352 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+const (
+	ReconcileQueued     = "queued"
+	ReconcileRegistered = "registered"
+	ReconcileRelinked   = "relinked"
+	ReconcileMissing    = "missing"
+	ReconcileFound      = "found"
+	ReconcileHashed     = "hashed"
+	ReconcileOrphaned   = "orphaned"
+	ReconcileSkipped    = "skipped"
+)
+
+// parseStoredPath reads hostname, scenario and datetime back from the path of
+// a file in the organized or archive dir, which ingestFile lays out as
+// <hostname>/<datetime>/<hostname>-<scenario>-<datetime>.pcap[ng][.gz].
+func parseStoredPath(root, path string) (FilenameValidationResult, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return FilenameValidationResult{}, false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) != 3 {
+		return FilenameValidationResult{}, false
+	}
+	hostname, datetime := parts[0], parts[1]
+	captureDateTime, err := time.Parse(time.RFC3339, datetime)
+	if err != nil {
+		return FilenameValidationResult{}, false
+	}
+
+	name := strings.TrimSuffix(parts[2], ".gz")
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".pcapng"), ".pcap")
+	scenario, ok := strings.CutPrefix(name, hostname+"-")
+	if !ok {
+		return FilenameValidationResult{}, false
+	}
+	scenario, ok = strings.CutSuffix(scenario, "-"+datetime)
+	if !ok || scenario == "" {
+		return FilenameValidationResult{}, false
+	}
+
+	return FilenameValidationResult{
+		IsValid:         true,
+		Hostname:        hostname,
+		Scenario:        scenario,
+		CaptureDateTime: captureDateTime,
+	}, true
+}
+
+// Reconcile brings the database and the directories back in line after
+// changes the watcher didn't see, e.g. while the server was down:
+//
+//   - captures waiting in watch_dir and watch_dirs are queued
+//   - files in the organized and archive dirs without a capture are
+//     registered, or relinked to a missing capture with the same content
+//   - captures whose file is gone are marked file_missing, and unmarked once
+//     it is back
+//   - captures stored before hashes were taken are hashed
+//   - sidecars in the watch dirs whose capture is gone are quarantined
+//
+// No jobs run while it looks at the stored files.
+func (q *IngestQueue) Reconcile() (ReconcileRes, error) {
+	cfg := q.config()
+	res := ReconcileRes{Actions: []ReconcileAction{}}
+	add := func(action ReconcileAction) {
+		switch action.Action {
+		case ReconcileQueued:
+			res.Queued++
+		case ReconcileRegistered:
+			res.Registered++
+		case ReconcileRelinked:
+			res.Relinked++
+		case ReconcileMissing:
+			res.Missing++
+		case ReconcileFound:
+			res.Found++
+		case ReconcileHashed:
+			res.Hashed++
+		case ReconcileOrphaned:
+			res.Orphaned++
+		case ReconcileSkipped:
+			res.Skipped++
+		}
+		if cfg.LogLevel == "info" {
+			q.logger.Info("Reconciled file", "action", action.Action, "path", action.Path, "capture_id", action.CaptureID, "reason", action.Reason)
+		}
+		res.Actions = append(res.Actions, action)
+	}
+
+	if err := q.reconcileStored(cfg, add); err != nil {
+		return res, err
+	}
+
+	for _, dir := range allWatchDirs(cfg) {
+		var pending, orphaned []string
+		err := walkWatchDir(dir, func(path string, isDir bool) {
+			if isDir {
+				return
+			}
+			if isOrphanedSidecar(cfg, path) {
+				orphaned = append(orphaned, path)
+				return
+			}
+			if ignoreWatched(path) {
+				return
+			}
+			info, err := os.Stat(path)
+			// Still being written; the watcher queues it once it's done.
+			if err != nil || time.Since(info.ModTime()) < quietPeriod(cfg) {
+				return
+			}
+			pending = append(pending, path)
+		})
+		if err != nil {
+			return res, fmt.Errorf("failed to read watch dir %s: %w", dir.Path, err)
+		}
+		for _, path := range pending {
+			queued, err := q.Enqueue(path)
+			if err != nil {
+				return res, fmt.Errorf("failed to queue %s: %w", path, err)
+			}
+			if queued {
+				add(ReconcileAction{Action: ReconcileQueued, Path: path})
+			}
+		}
+		q.quarantineOrphans(cfg, orphaned, add)
+	}
+
+	return res, nil
+}
+
+// isOrphanedSidecar reports whether path is a sidecar whose capture isn't in
+// the watch dir, and that has been there longer than a capture waits for its
+// sidecar, so the capture was already ingested without it or never arrived.
+func isOrphanedSidecar(cfg config.Config, path string) bool {
+	if !isSidecar(filepath.Base(path)) {
+		return false
+	}
+	if _, err := os.Stat(orphanedCapture(path)); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > quietPeriod(cfg)+sidecarWait(cfg)
+}
+
+// quarantineOrphans quarantines orphaned sidecars. A running job may have
+// just moved the capture of one and not yet removed it, so they are checked
+// again once no jobs run.
+func (q *IngestQueue) quarantineOrphans(cfg config.Config, paths []string, add func(ReconcileAction)) {
+	if len(paths) == 0 {
+		return
+	}
+	q.ingesting.Lock()
+	defer q.ingesting.Unlock()
+
+	for _, path := range paths {
+		if !isOrphanedSidecar(cfg, path) {
+			continue
+		}
+		rejectFile(cfg, path, "", RejectOrphanedSidecar, "no capture "+filepath.Base(orphanedCapture(path)), 0, q.logger)
+		add(ReconcileAction{Action: ReconcileOrphaned, Path: path})
+	}
+}
+
+// orphanedCapture is the path of the capture the sidecar at path belongs to.
+func orphanedCapture(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// reconcileStored compares the captures table with the organized and archive
+// dirs.
+func (q *IngestQueue) reconcileStored(cfg config.Config, add func(ReconcileAction)) error {
+	q.ingesting.Lock()
+	defer q.ingesting.Unlock()
+
+	ctx := context.Background()
+	captures, err := q.store.GetCaptures(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get captures: %w", err)
+	}
+
+	tracked := make(map[string]bool, len(captures))
+	missing := make(map[int64]bool)
+	missingBySum := make(map[string]int64)
+	for _, c := range captures {
+		tracked[c.FilePath] = true
+		if _, err := os.Stat(c.FilePath); errors.Is(err, os.ErrNotExist) {
+			missing[c.ID] = true
+			if c.Sha256.Valid {
+				missingBySum[c.Sha256.String] = c.ID
+			}
+			continue
+		}
+		if c.FileMissing.Bool {
+			if err := q.store.SetCaptureFileMissing(ctx, sqlc.SetCaptureFileMissingParams{
+				FileMissing: sql.NullBool{Bool: false, Valid: true},
+				ID:          c.ID,
+			}); err != nil {
+				return fmt.Errorf("failed to unmark capture %d: %w", c.ID, err)
+			}
+			add(ReconcileAction{Action: ReconcileFound, Path: c.FilePath, CaptureID: c.ID})
+		}
+		// Corrupt captures without a hash couldn't be hashed at ingest.
+		if !c.Sha256.Valid && !c.Corrupt.Bool {
+			if action, ok := q.reconcileHash(c); ok {
+				add(action)
+			}
+		}
+	}
+
+	for _, root := range []string{cfg.OrganizedDir, cfg.ArchiveDir} {
+		archived := root == cfg.ArchiveDir
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || tracked[path] {
+				return nil
+			}
+			// Compression stopped before it removed the original, which is
+			// still the tracked file.
+			if strings.HasSuffix(path, ".gz") {
+				if _, err := os.Stat(strings.TrimSuffix(path, ".gz")); err == nil {
+					add(ReconcileAction{Action: ReconcileSkipped, Path: path, Reason: "uncompressed file still exists"})
+					return nil
+				}
+			}
+			add(q.reconcileUntracked(cfg, root, path, archived, missing, missingBySum))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to walk %s: %w", root, err)
+		}
+	}
+
+	for _, c := range captures {
+		if !missing[c.ID] || c.FileMissing.Bool {
+			continue
+		}
+		if err := q.store.SetCaptureFileMissing(ctx, sqlc.SetCaptureFileMissingParams{
+			FileMissing: sql.NullBool{Bool: true, Valid: true},
+			ID:          c.ID,
+		}); err != nil {
+			return fmt.Errorf("failed to mark capture %d as missing: %w", c.ID, err)
+		}
+		add(ReconcileAction{Action: ReconcileMissing, Path: c.FilePath, CaptureID: c.ID})
+	}
+	return nil
+}
+
+// reconcileHash hashes a capture stored before hashes were taken. It returns
+// false for content that can't be read to the end, which stays unhashed as
+// at ingest.
+func (q *IngestQueue) reconcileHash(c sqlc.Capture) (ReconcileAction, bool) {
+	skipped := func(reason string) (ReconcileAction, bool) {
+		return ReconcileAction{Action: ReconcileSkipped, Path: c.FilePath, CaptureID: c.ID, Reason: reason}, true
+	}
+
+	sum, err := captureHash(c.FilePath)
+	if err != nil {
+		return skipped(err.Error())
+	}
+	if sum == "" {
+		return ReconcileAction{}, false
+	}
+	if err := storeHash(context.Background(), q.store, c.ID, sum); err != nil {
+		return skipped(fmt.Sprintf("failed to store hash: %v", err))
+	}
+	return ReconcileAction{Action: ReconcileHashed, Path: c.FilePath, CaptureID: c.ID}, true
+}
+
+// reconcileUntracked relinks or registers a stored file the database doesn't
+// know. A relinked capture is taken out of missing.
+func (q *IngestQueue) reconcileUntracked(cfg config.Config, root, path string, archived bool, missing map[int64]bool, missingBySum map[string]int64) ReconcileAction {
+	ctx := context.Background()
+	skipped := func(reason string) ReconcileAction {
+		return ReconcileAction{Action: ReconcileSkipped, Path: path, Reason: reason}
+	}
+
+	result, ok := parseStoredPath(root, path)
+	if !ok {
+		return skipped("not named like a stored capture")
+	}
+	sum, err := captureHash(path)
+	if err != nil {
+		return skipped(err.Error())
+	}
+
+	if id, ok := missingBySum[sum]; ok {
+		if err := q.store.RelinkCapture(ctx, sqlc.RelinkCaptureParams{
+			FilePath:   path,
+			Archived:   sql.NullBool{Bool: archived, Valid: true},
+			Compressed: sql.NullBool{Bool: strings.HasSuffix(path, ".gz"), Valid: true},
+			ID:         id,
+		}); err != nil {
+			return skipped(fmt.Sprintf("failed to relink capture %d: %v", id, err))
+		}
+		delete(missing, id)
+		delete(missingBySum, sum)
+		return ReconcileAction{Action: ReconcileRelinked, Path: path, CaptureID: id}
+	}
+
+	if sum != "" && cfg.DuplicatePolicy != config.DuplicatePolicyAllow {
+		existingID, err := q.store.GetCaptureIDBySHA256(ctx, nullString(sum))
+		if err == nil {
+			return skipped((&duplicateError{CaptureID: existingID}).Error())
+		}
+		if err != sql.ErrNoRows {
+			return skipped(fmt.Sprintf("failed to look up capture hash: %v", err))
+		}
+	}
+
+	id, corrupt, err := storeCapture(cfg, q.store, path, result, nil, nil, sum, archived)
+	if err != nil {
+		return skipped(err.Error())
+	}
+	action := ReconcileAction{Action: ReconcileRegistered, Path: path, CaptureID: id}
+	if corrupt != nil {
+		action.Reason = "stored corrupt: " + corrupt.Error()
+	}
+	return action
+}
+
+// ReconcileHandler runs a reconciliation pass and reports what it did.
+func (s *Server) ReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := s.ingest.Reconcile()
+	if err != nil {
+		s.logger.Error("Failed to reconcile store", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	jsonResponse(w, http.StatusOK, res)
+}
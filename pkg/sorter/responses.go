@@ -3,6 +3,8 @@ package sorter
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
 )
 
 // ============================================================================
@@ -44,6 +46,59 @@ type MinMaxPercent struct {
 // File Types
 // ============================================================================
 
+// FileRes is a stored capture with the metadata from its sidecar and its
+// tags.
+type FileRes struct {
+	sqlc.Capture
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	// Duplicates are copies with the same content that were linked to the
+	// capture instead of being stored.
+	Duplicates []DuplicateResult `json:"duplicates,omitempty"`
+}
+
+type DuplicateResult struct {
+	Filename        string `json:"filename"`
+	Hostname        string `json:"hostname"`
+	Scenario        string `json:"scenario"`
+	CaptureDatetime string `json:"capture_datetime"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type TagsRes struct {
+	CaptureID int64    `json:"capture_id"`
+	Tags      []string `json:"tags"`
+}
+
+type NoteReq struct {
+	Text string `json:"text"`
+}
+
+type NoteResult struct {
+	ID        int64  `json:"id"`
+	CaptureID int64  `json:"capture_id"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+}
+
+type NotesRes struct {
+	CaptureID int64        `json:"capture_id"`
+	Notes     []NoteResult `json:"notes"`
+	Count     int          `json:"count"`
+}
+
+type UploadRes struct {
+	Results []UploadResult `json:"results"`
+	Count   int            `json:"count"`
+}
+
+type UploadResult struct {
+	Filename  string `json:"filename"`
+	Status    string `json:"status"`
+	CaptureID int64  `json:"capture_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
 // ============================================================================
 // Archiving Types
 // ============================================================================
@@ -83,24 +138,78 @@ type SummaryRes struct {
 }
 
 type FileStatsRes struct {
-	CaptureID            int64          `json:"capture_id"`
-	Hostname             string         `json:"hostname"`
-	Scenario             string         `json:"scenario"`
-	CaptureDatetime      string         `json:"capture_datetime"`
-	FilePath             string         `json:"file_path"`
-	PacketCount          *int64         `json:"packet_count,omitempty"`
-	ProtocolDistribution map[string]int `json:"protocol_distribution,omitempty"`
-	TopSrcIps            map[string]int `json:"top_src_ips,omitempty"`
-	TopDstIps            map[string]int `json:"top_dst_ips,omitempty"`
-	TopTcpSrcPorts       map[string]int `json:"top_tcp_src_ports,omitempty"`
-	TopTcpDstPorts       map[string]int `json:"top_tcp_dst_ports,omitempty"`
-	TopUdpSrcPorts       map[string]int `json:"top_udp_src_ports,omitempty"`
-	TopUdpDstPorts       map[string]int `json:"top_udp_dst_ports,omitempty"`
-	PacketRate           *float64       `json:"packet_rate,omitempty"`
-	AvgPacketSize        *float64       `json:"avg_packet_size,omitempty"`
-	DurationSeconds      *int64         `json:"duration_seconds,omitempty"`
-	FirstPacketTime      *string        `json:"first_packet_time,omitempty"`
-	LastPacketTime       *string        `json:"last_packet_time,omitempty"`
+	CaptureID            int64              `json:"capture_id"`
+	Hostname             string             `json:"hostname"`
+	Scenario             string             `json:"scenario"`
+	CaptureDatetime      string             `json:"capture_datetime"`
+	FilePath             string             `json:"file_path"`
+	PacketCount          *int64             `json:"packet_count,omitempty"`
+	ProtocolDistribution map[string]int     `json:"protocol_distribution,omitempty"`
+	TopSrcIps            map[string]int     `json:"top_src_ips,omitempty"`
+	TopDstIps            map[string]int     `json:"top_dst_ips,omitempty"`
+	TopTcpSrcPorts       map[string]int     `json:"top_tcp_src_ports,omitempty"`
+	TopTcpDstPorts       map[string]int     `json:"top_tcp_dst_ports,omitempty"`
+	TopUdpSrcPorts       map[string]int     `json:"top_udp_src_ports,omitempty"`
+	TopUdpDstPorts       map[string]int     `json:"top_udp_dst_ports,omitempty"`
+	PacketRate           *float64           `json:"packet_rate,omitempty"`
+	AvgPacketSize        *float64           `json:"avg_packet_size,omitempty"`
+	DurationSeconds      *int64             `json:"duration_seconds,omitempty"`
+	FirstPacketTime      *string            `json:"first_packet_time,omitempty"`
+	LastPacketTime       *string            `json:"last_packet_time,omitempty"`
+	AnalyzerVersion      *int64             `json:"analyzer_version,omitempty"`
+	TCPHealth            *TCPHealthResult   `json:"tcp_health,omitempty"`
+	TLSSessions          []TLSSessionResult `json:"tls_sessions,omitempty"`
+	Findings             []FindingResult    `json:"findings,omitempty"`
+}
+
+type TCPHealthResult struct {
+	SYNs                int64    `json:"syns"`
+	SYNACKs             int64    `json:"syn_acks"`
+	HandshakesCompleted int64    `json:"handshakes_completed"`
+	Resets              int64    `json:"resets"`
+	FINs                int64    `json:"fins"`
+	DuplicateACKs       int64    `json:"duplicate_acks"`
+	Retransmissions     int64    `json:"retransmissions"`
+	OutOfOrder          int64    `json:"out_of_order"`
+	AvgHandshakeRTTMs   *float64 `json:"avg_handshake_rtt_ms,omitempty"`
+	MaxHandshakeRTTMs   *float64 `json:"max_handshake_rtt_ms,omitempty"`
+}
+
+type TimelineRes struct {
+	CaptureID     int64                  `json:"capture_id"`
+	BucketSeconds int64                  `json:"bucket_seconds"`
+	Start         string                 `json:"start,omitempty"`
+	End           string                 `json:"end,omitempty"`
+	Buckets       []TimelineBucketResult `json:"buckets"`
+}
+
+type TimelineBucketResult struct {
+	Start     string                    `json:"start"`
+	Packets   int64                     `json:"packets"`
+	Bytes     int64                     `json:"bytes"`
+	Protocols map[string]TimelineCounts `json:"protocols"`
+}
+
+type TimelineCounts struct {
+	Packets int64 `json:"packets"`
+	Bytes   int64 `json:"bytes"`
+}
+
+type TLSSessionResult struct {
+	ClientIP      string   `json:"client_ip"`
+	ClientPort    int64    `json:"client_port"`
+	ServerIP      string   `json:"server_ip"`
+	ServerPort    int64    `json:"server_port"`
+	SeenAt        string   `json:"seen_at"`
+	SNI           string   `json:"sni,omitempty"`
+	ClientVersion string   `json:"client_version,omitempty"`
+	CipherSuites  []string `json:"cipher_suites,omitempty"`
+	JA3           string   `json:"ja3,omitempty"`
+	JA3Hash       string   `json:"ja3_hash,omitempty"`
+	Version       string   `json:"version,omitempty"`
+	CipherSuite   string   `json:"cipher_suite,omitempty"`
+	JA3S          string   `json:"ja3s,omitempty"`
+	JA3SHash      string   `json:"ja3s_hash,omitempty"`
 }
 
 type StatsByHostnameRes struct {
@@ -123,6 +232,55 @@ type StatsByScenarioRes struct {
 	TotalDurationSeconds int64   `json:"total_duration_seconds"`
 }
 
+// ============================================================================
+// Flow Types
+// ============================================================================
+
+type FlowsRes struct {
+	CaptureID int64        `json:"capture_id"`
+	Flows     []FlowResult `json:"flows"`
+	Count     int          `json:"count"`
+	Total     int          `json:"total"`
+}
+
+type FlowResult struct {
+	Protocol        string   `json:"protocol"`
+	SrcIP           string   `json:"src_ip"`
+	SrcPort         int64    `json:"src_port"`
+	DstIP           string   `json:"dst_ip"`
+	DstPort         int64    `json:"dst_port"`
+	PacketsSrcToDst int64    `json:"packets_src_to_dst"`
+	BytesSrcToDst   int64    `json:"bytes_src_to_dst"`
+	PacketsDstToSrc int64    `json:"packets_dst_to_src"`
+	BytesDstToSrc   int64    `json:"bytes_dst_to_src"`
+	TCPFlags        string   `json:"tcp_flags,omitempty"`
+	HandshakeRTTMs  *float64 `json:"handshake_rtt_ms,omitempty"`
+	FirstSeen       string   `json:"first_seen"`
+	LastSeen        string   `json:"last_seen"`
+	DurationSeconds float64  `json:"duration_seconds"`
+}
+
+type HTTPRes struct {
+	CaptureID    int64        `json:"capture_id"`
+	Transactions []HTTPResult `json:"transactions"`
+	Count        int          `json:"count"`
+}
+
+type HTTPResult struct {
+	ClientIP     string `json:"client_ip"`
+	ClientPort   int64  `json:"client_port"`
+	ServerIP     string `json:"server_ip"`
+	ServerPort   int64  `json:"server_port"`
+	RequestTime  string `json:"request_time"`
+	Method       string `json:"method,omitempty"`
+	Host         string `json:"host,omitempty"`
+	URI          string `json:"uri,omitempty"`
+	UserAgent    string `json:"user_agent,omitempty"`
+	ResponseTime string `json:"response_time,omitempty"`
+	StatusCode   *int64 `json:"status_code,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+}
+
 // ============================================================================
 // Query & Search Types
 // ============================================================================
@@ -133,16 +291,61 @@ type SearchRes struct {
 }
 
 type SearchResult struct {
-	ID              int64  `json:"id"`
-	Hostname        string `json:"hostname"`
-	Scenario        string `json:"scenario"`
-	CaptureDatetime string `json:"capture_datetime"`
-	FilePath        string `json:"file_path"`
-	FileSize        int64  `json:"file_size"`
-	Compressed      bool   `json:"compressed"`
-	Archived        bool   `json:"archived"`
-	CreatedAt       string `json:"created_at,omitempty"`
-	UpdatedAt       string `json:"updated_at,omitempty"`
+	ID              int64             `json:"id"`
+	Hostname        string            `json:"hostname"`
+	Scenario        string            `json:"scenario"`
+	CaptureDatetime string            `json:"capture_datetime"`
+	FilePath        string            `json:"file_path"`
+	FileSize        int64             `json:"file_size"`
+	Compressed      bool              `json:"compressed"`
+	Archived        bool              `json:"archived"`
+	Corrupt         bool              `json:"corrupt"`
+	CorruptPacket   int64             `json:"corrupt_packet,omitempty"`
+	CorruptError    string            `json:"corrupt_error,omitempty"`
+	CreatedAt       string            `json:"created_at,omitempty"`
+	UpdatedAt       string            `json:"updated_at,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Tags            []string          `json:"tags,omitempty"`
+}
+
+type DNSSearchRes struct {
+	Name       string      `json:"name"`
+	CaptureIDs []int64     `json:"capture_ids"`
+	Results    []DNSResult `json:"results"`
+	Count      int         `json:"count"`
+}
+
+type DNSResult struct {
+	CaptureID       int64    `json:"capture_id"`
+	Hostname        string   `json:"hostname"`
+	Scenario        string   `json:"scenario"`
+	CaptureDatetime string   `json:"capture_datetime"`
+	QueryTime       string   `json:"query_time"`
+	ResponseTime    string   `json:"response_time,omitempty"`
+	ClientIP        string   `json:"client_ip"`
+	ServerIP        string   `json:"server_ip"`
+	Name            string   `json:"name"`
+	RecordType      string   `json:"record_type"`
+	ResponseCode    string   `json:"response_code,omitempty"`
+	Answers         []string `json:"answers,omitempty"`
+}
+
+type FindingsRes struct {
+	Findings []FindingResult `json:"findings"`
+	Count    int             `json:"count"`
+}
+
+type FindingResult struct {
+	ID              int64          `json:"id"`
+	CaptureID       int64          `json:"capture_id"`
+	Hostname        string         `json:"hostname,omitempty"`
+	Scenario        string         `json:"scenario,omitempty"`
+	CaptureDatetime string         `json:"capture_datetime,omitempty"`
+	Rule            string         `json:"rule"`
+	Severity        string         `json:"severity"`
+	Summary         string         `json:"summary"`
+	Evidence        map[string]any `json:"evidence,omitempty"`
+	FirstSeen       string         `json:"first_seen,omitempty"`
 }
 
 type SQLQueryReq struct {
@@ -167,6 +370,203 @@ type CompressionTriggerRes struct {
 	Errors    []string `json:"errors,omitempty"`
 }
 
+// ============================================================================
+// Merge Types
+// ============================================================================
+
+type MergeReq struct {
+	IDs      []int64           `json:"ids,omitempty"`
+	Search   map[string]string `json:"search,omitempty"`
+	Format   string            `json:"format,omitempty"`
+	Ingest   bool              `json:"ingest,omitempty"`
+	Hostname string            `json:"hostname,omitempty"`
+	Scenario string            `json:"scenario,omitempty"`
+}
+
+type MergeRes struct {
+	CaptureID int64   `json:"capture_id"`
+	Hostname  string  `json:"hostname"`
+	Scenario  string  `json:"scenario"`
+	Sources   []int64 `json:"sources"`
+	Packets   int     `json:"packets"`
+}
+
+// MergeConflictRes is returned for an ingested merge whose requested name is
+// already taken by the stored capture at Path.
+type MergeConflictRes struct {
+	Status string `json:"status"`
+	Path   string `json:"path"`
+}
+
+// ============================================================================
+// Diff Types
+// ============================================================================
+
+type DiffRes struct {
+	A         DiffSide    `json:"a"`
+	B         DiffSide    `json:"b"`
+	Threshold float64     `json:"threshold"`
+	Hosts     DiffSection `json:"hosts"`
+	Ports     DiffSection `json:"ports"`
+	Protocols DiffSection `json:"protocols"`
+	Flows     DiffSection `json:"flows"`
+}
+
+// DiffSide describes one side of a diff. A baseline side covers several
+// captures and holds their per-capture average.
+type DiffSide struct {
+	CaptureIDs []int64 `json:"capture_ids"`
+	Hostname   string  `json:"hostname"`
+	Scenario   string  `json:"scenario"`
+	Packets    float64 `json:"packets"`
+}
+
+type DiffSection struct {
+	New       []DiffEntry `json:"new"`
+	Missing   []DiffEntry `json:"missing"`
+	Changed   []DiffEntry `json:"changed"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+type DiffEntry struct {
+	Key      string   `json:"key"`
+	PacketsA float64  `json:"packets_a"`
+	PacketsB float64  `json:"packets_b"`
+	Change   *float64 `json:"change,omitempty"`
+}
+
+// ============================================================================
+// Reanalysis Types
+// ============================================================================
+
+type ReanalyzeRes struct {
+	CaptureID       int64  `json:"capture_id"`
+	AnalyzerVersion int    `json:"analyzer_version"`
+	Status          string `json:"status"`
+}
+
+type ReanalyzeTriggerRes struct {
+	Queued            int   `json:"queued"`
+	AnalyzerVersionLt int64 `json:"analyzer_version_lt"`
+	AnalyzerVersion   int   `json:"analyzer_version"`
+}
+
+// ============================================================================
+// Verification Types
+// ============================================================================
+
+type VerifyRes struct {
+	Checked    int             `json:"checked"`
+	OK         int             `json:"ok"`
+	Missing    int             `json:"missing"`
+	Mismatched int             `json:"mismatched"`
+	Unreadable int             `json:"unreadable"`
+	Hashed     int             `json:"hashed"`
+	Unhashed   int             `json:"unhashed"`
+	Problems   []VerifyProblem `json:"problems"`
+}
+
+// VerifyProblem is a stored capture that failed verification. Status is
+// missing, mismatch, unreadable or unhashed.
+type VerifyProblem struct {
+	CaptureID int64  `json:"capture_id"`
+	FilePath  string `json:"file_path"`
+	Status    string `json:"status"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ============================================================================
+// Ingest Job Types
+// ============================================================================
+
+type JobsRes struct {
+	Jobs   []JobResult    `json:"jobs"`
+	Count  int            `json:"count"`
+	Counts map[string]int `json:"counts"`
+}
+
+// JobResult is one ingest_jobs row. Status is queued, running, done or failed.
+type JobResult struct {
+	ID            int64  `json:"id"`
+	Path          string `json:"path"`
+	Status        string `json:"status"`
+	Attempts      int64  `json:"attempts"`
+	LastError     string `json:"last_error,omitempty"`
+	CaptureID     int64  `json:"capture_id,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+type JobRetryRes struct {
+	Status  string `json:"status"`
+	Retried int64  `json:"retried"`
+}
+
+// ============================================================================
+// Rejection Types
+// ============================================================================
+
+type RejectionsRes struct {
+	Rejections []RejectionResult `json:"rejections"`
+	Count      int               `json:"count"`
+}
+
+// RejectionResult is a file the sorter moved to the quarantine dir. Reason is
+// invalid_name, invalid_sidecar, duplicate, corrupt or orphaned_sidecar;
+// status is quarantined or resubmitted.
+type RejectionResult struct {
+	ID             int64  `json:"id"`
+	OriginalName   string `json:"original_name"`
+	OriginalDir    string `json:"original_dir"`
+	QuarantinePath string `json:"quarantine_path"`
+	SidecarPath    string `json:"sidecar_path,omitempty"`
+	Reason         string `json:"reason"`
+	Detail         string `json:"detail,omitempty"`
+	CaptureID      int64  `json:"capture_id,omitempty"`
+	Status         string `json:"status"`
+	ResubmittedAs  string `json:"resubmitted_as,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	ResolvedAt     string `json:"resolved_at,omitempty"`
+}
+
+type RejectionRetryReq struct {
+	Name string `json:"name"`
+}
+
+type RejectionRetryRes struct {
+	Status string `json:"status"`
+	Path   string `json:"path,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ============================================================================
+// Reconciliation Types
+// ============================================================================
+
+type ReconcileRes struct {
+	Queued     int               `json:"queued"`
+	Registered int               `json:"registered"`
+	Relinked   int               `json:"relinked"`
+	Missing    int               `json:"missing"`
+	Found      int               `json:"found"`
+	Hashed     int               `json:"hashed"`
+	Orphaned   int               `json:"orphaned"`
+	Skipped    int               `json:"skipped"`
+	Actions    []ReconcileAction `json:"actions"`
+}
+
+// ReconcileAction is one change made by reconciliation. Action is queued,
+// registered, relinked, missing, found, hashed, orphaned or skipped.
+type ReconcileAction struct {
+	Action    string `json:"action"`
+	Path      string `json:"path"`
+	CaptureID int64  `json:"capture_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
 // ============================================================================
 // Cleanup Types
 // ============================================================================
@@ -2,8 +2,11 @@ package sorter
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -12,24 +15,73 @@ import (
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
 )
 
-func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
-	store, err := db.InitIfNeeded()
-	if err != nil {
-		s.logger.Error("Failed to initialize database", "error", err)
-		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
-		return
+// searchCaptures returns the captures matching the search filters (hostname,
+// scenario, archived, compressed, corrupt, sni, http_host, http_uri, meta, tag).
+func searchCaptures(ctx context.Context, store *db.Store, params url.Values) ([]sqlc.Capture, error) {
+	hostname := params.Get("hostname")
+	scenario := params.Get("scenario")
+	archivedParam := params.Get("archived")
+	compressedParam := params.Get("compressed")
+	corruptParam := params.Get("corrupt")
+	sniParam := params.Get("sni")
+	httpHostParam := params.Get("http_host")
+	httpURIParam := params.Get("http_uri")
+
+	var sniCaptures map[int64]bool
+	if sniParam != "" {
+		ids, err := store.GetCaptureIDsBySNI(ctx, sql.NullString{String: domainPattern(sniParam), Valid: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search TLS sessions: %w", err)
+		}
+		sniCaptures = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			sniCaptures[id] = true
+		}
 	}
 
-	hostname := r.URL.Query().Get("hostname")
-	scenario := r.URL.Query().Get("scenario")
-	archivedParam := r.URL.Query().Get("archived")
-	compressedParam := r.URL.Query().Get("compressed")
+	var httpCaptures map[int64]bool
+	if httpHostParam != "" || httpURIParam != "" {
+		params := sqlc.GetCaptureIDsByHTTPParams{
+			Host: sql.NullString{String: "%", Valid: true},
+			Uri:  sql.NullString{String: "%", Valid: true},
+		}
+		if httpHostParam != "" {
+			params.Host.String = domainPattern(httpHostParam)
+		}
+		if httpURIParam != "" {
+			params.Uri.String = "%" + likeEscaper.Replace(httpURIParam) + "%"
+		}
+		ids, err := store.GetCaptureIDsByHTTP(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search HTTP transactions: %w", err)
+		}
+		httpCaptures = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			httpCaptures[id] = true
+		}
+	}
 
-	allCaptures, err := store.GetCaptures(context.Background())
+	var metadata map[int64]map[string]string
+	metaFilters := params["meta"]
+	if len(metaFilters) > 0 {
+		var err error
+		if metadata, err = captureMetadata(ctx, store); err != nil {
+			return nil, err
+		}
+	}
+
+	var tags map[int64][]string
+	tagFilters := params["tag"]
+	if len(tagFilters) > 0 {
+		var err error
+		if tags, err = captureTags(ctx, store); err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+	}
+
+	allCaptures, err := store.GetCaptures(ctx)
 	if err != nil {
-		s.logger.Error("Failed to get captures", "error", err)
-		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
-		return
+		return nil, fmt.Errorf("failed to get captures: %w", err)
 	}
 
 	var filtered []sqlc.Capture
@@ -40,6 +92,18 @@ func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
 		if scenario != "" && capture.Scenario != scenario {
 			continue
 		}
+		if sniCaptures != nil && !sniCaptures[capture.ID] {
+			continue
+		}
+		if httpCaptures != nil && !httpCaptures[capture.ID] {
+			continue
+		}
+		if len(metaFilters) > 0 && !matchesMetadata(metadata[capture.ID], metaFilters) {
+			continue
+		}
+		if len(tagFilters) > 0 && !hasTags(tags[capture.ID], tagFilters) {
+			continue
+		}
 		if archivedParam != "" {
 			archived, err := strconv.ParseBool(archivedParam)
 			if err == nil {
@@ -56,8 +120,62 @@ func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
+		if corruptParam != "" {
+			corrupt, err := strconv.ParseBool(corruptParam)
+			if err == nil {
+				if capture.Corrupt.Bool != corrupt {
+					continue
+				}
+			}
+		}
 		filtered = append(filtered, capture)
 	}
+	return filtered, nil
+}
+
+// captureMetadata returns the metadata of all captures by capture ID.
+func captureMetadata(ctx context.Context, store *db.Store) (map[int64]map[string]string, error) {
+	rows, err := store.GetAllCaptureMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get capture metadata: %w", err)
+	}
+	metadata := make(map[int64]map[string]string)
+	for _, row := range rows {
+		if metadata[row.CaptureID] == nil {
+			metadata[row.CaptureID] = make(map[string]string)
+		}
+		metadata[row.CaptureID][row.Key] = row.Value
+	}
+	return metadata, nil
+}
+
+func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	filtered, err := searchCaptures(context.Background(), store, r.URL.Query())
+	if err != nil {
+		s.logger.Error("Failed to search captures", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	metadata, err := captureMetadata(context.Background(), store)
+	if err != nil {
+		s.logger.Error("Failed to search captures", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	tags, err := captureTags(context.Background(), store)
+	if err != nil {
+		s.logger.Error("Failed to get tags", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
 
 	results := make([]SearchResult, 0, len(filtered))
 	for _, capture := range filtered {
@@ -70,6 +188,11 @@ func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
 			FileSize:        capture.FileSize,
 			Compressed:      capture.Compressed.Bool,
 			Archived:        capture.Archived.Bool,
+			Corrupt:         capture.Corrupt.Bool,
+			CorruptPacket:   capture.CorruptPacket.Int64,
+			CorruptError:    capture.CorruptError.String,
+			Metadata:        metadata[capture.ID],
+			Tags:            tags[capture.ID],
 		}
 		if capture.CreatedAt.Valid {
 			result.CreatedAt = capture.CreatedAt.Time.Format(time.RFC3339)
@@ -118,6 +241,9 @@ func (s *Server) GetFilesByHostnameHandler(w http.ResponseWriter, r *http.Reques
 			FileSize:        capture.FileSize,
 			Compressed:      capture.Compressed.Bool,
 			Archived:        capture.Archived.Bool,
+			Corrupt:         capture.Corrupt.Bool,
+			CorruptPacket:   capture.CorruptPacket.Int64,
+			CorruptError:    capture.CorruptError.String,
 		}
 		if capture.CreatedAt.Valid {
 			result.CreatedAt = capture.CreatedAt.Time.Format(time.RFC3339)
@@ -166,6 +292,9 @@ func (s *Server) GetFilesByScenarioHandler(w http.ResponseWriter, r *http.Reques
 			FileSize:        capture.FileSize,
 			Compressed:      capture.Compressed.Bool,
 			Archived:        capture.Archived.Bool,
+			Corrupt:         capture.Corrupt.Bool,
+			CorruptPacket:   capture.CorruptPacket.Int64,
+			CorruptError:    capture.CorruptError.String,
 		}
 		if capture.CreatedAt.Valid {
 			result.CreatedAt = capture.CreatedAt.Time.Format(time.RFC3339)
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
@@ -20,6 +21,9 @@ type Server struct {
 	cfg      config.Config
 	cfgMu    sync.RWMutex
 	password string
+	ingest   *IngestQueue
+
+	reanalyzing atomic.Bool
 }
 
 func (s *Server) GetConfig() config.Config {
@@ -34,11 +38,15 @@ func (s *Server) UpdateConfig(cfg config.Config) {
 	s.cfg = cfg
 }
 
-func startHTTPServer(lg logger.Logger, cfg config.Config) {
-	s := &Server{
+func newServer(lg logger.Logger, cfg config.Config) *Server {
+	return &Server{
 		logger: lg,
 		cfg:    cfg,
 	}
+}
+
+func startHTTPServer(s *Server) {
+	cfg := s.GetConfig()
 
 	r := chi.NewRouter()
 	var listener net.Listener
@@ -79,10 +87,20 @@ func startHTTPServer(lg logger.Logger, cfg config.Config) {
 	// File Endpoints
 	fileRoutes := func(r chi.Router) {
 		r.Get("/files/{id}/download", s.FileDownloadHandler)
+		r.Get("/files/{id}/extract", s.ExtractFileHandler)
 		r.Get("/files/{id}/stats", s.GetFileStatsHandler)
+		r.Get("/files/{id}/flows", s.GetFileFlowsHandler)
+		r.Get("/files/{id}/http", s.GetFileHTTPHandler)
+		r.Get("/files/{id}/timeline", s.GetFileTimelineHandler)
+		r.Post("/files/{id}/reanalyze", s.ReanalyzeFileHandler)
 		r.Get("/files", s.GetFilesHandler)
+		r.Post("/files", s.UploadFileHandler)
 		r.Get("/file/{id}", s.GetFileHandler)
 		r.Delete("/file/{id}", s.DeleteFileHandler)
+		r.Put("/file/{id}/tags/{tag}", s.AddTagHandler)
+		r.Delete("/file/{id}/tags/{tag}", s.RemoveTagHandler)
+		r.Get("/file/{id}/notes", s.GetNotesHandler)
+		r.Post("/file/{id}/notes", s.AddNoteHandler)
 	}
 
 	archiveRoutes := func(r chi.Router) {
@@ -108,6 +126,7 @@ func startHTTPServer(lg logger.Logger, cfg config.Config) {
 		r.Get("/summary", s.GetSummaryHandler)
 		r.Get("/stats/by-hostname", s.GetStatsByHostnameHandler)
 		r.Get("/stats/by-scenario", s.GetStatsByScenarioHandler)
+		r.Get("/diff", s.DiffHandler)
 	}
 
 	// Compression Endpoints
@@ -119,9 +138,40 @@ func startHTTPServer(lg logger.Logger, cfg config.Config) {
 	// Search & Query Endpoints
 	searchRoutes := func(r chi.Router) {
 		r.Get("/search", s.SearchHandler)
+		r.Get("/dns", s.SearchDNSHandler)
+		r.Get("/findings", s.GetFindingsHandler)
 		r.Get("/files/by-hostname/{host}", s.GetFilesByHostnameHandler)
 		r.Get("/files/by-scenario/{scenario}", s.GetFilesByScenarioHandler)
 		r.Post("/query", s.QuerySQLHandler)
+		r.Post("/merge", s.MergeHandler)
+	}
+
+	// Reanalysis Endpoints
+	reanalyzeRoutes := func(r chi.Router) {
+		r.Post("/reanalyze", s.ReanalyzeHandler)
+	}
+
+	// Ingest Job Endpoints
+	jobRoutes := func(r chi.Router) {
+		r.Get("/jobs", s.GetJobsHandler)
+		r.Post("/jobs/retry", s.RetryFailedJobsHandler)
+		r.Post("/jobs/{id}/retry", s.RetryJobHandler)
+	}
+
+	// Verification Endpoints
+	verifyRoutes := func(r chi.Router) {
+		r.Post("/verify", s.VerifyHandler)
+	}
+
+	// Rejection Endpoints
+	rejectionRoutes := func(r chi.Router) {
+		r.Get("/rejections", s.GetRejectionsHandler)
+		r.Post("/rejections/{id}/retry", s.RetryRejectionHandler)
+	}
+
+	// Reconciliation Endpoints
+	reconcileRoutes := func(r chi.Router) {
+		r.Post("/reconcile", s.ReconcileHandler)
 	}
 
 	// Export Endpoints
@@ -138,7 +188,12 @@ func startHTTPServer(lg logger.Logger, cfg config.Config) {
 		statsRoutes(r)
 		compressionRoutes(r)
 		searchRoutes(r)
+		reanalyzeRoutes(r)
 		exportRoutes(r)
+		verifyRoutes(r)
+		jobRoutes(r)
+		reconcileRoutes(r)
+		rejectionRoutes(r)
 	})
 
 	if cfg.LogLevel == "info" {
@@ -0,0 +1,189 @@
+package sorter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+)
+
+// sidecarExtensions are tried in order after the full capture filename, so
+// {SRV1}_{http}_{20250101_120000}.pcap goes with
+// {SRV1}_{http}_{20250101_120000}.pcap.json.
+var sidecarExtensions = []string{".json", ".toml"}
+
+// sidecarQuiet is how long a sidecar has to stay unchanged before it is read,
+// it may still be written when its capture is done.
+const sidecarQuiet = time.Second
+
+const defaultSidecarWaitSeconds = 5
+
+// validMetadataKey keeps custom keys usable in meta=key=value search filters.
+var validMetadataKey = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// sidecar is the document capture producers drop next to a capture. Custom
+// values may be strings, numbers or booleans.
+type sidecar struct {
+	Operator      string         `json:"operator" toml:"operator"`
+	Interface     string         `json:"interface" toml:"interface"`
+	CaptureFilter string         `json:"capture_filter" toml:"capture_filter"`
+	Description   string         `json:"description" toml:"description"`
+	Tags          []string       `json:"tags" toml:"tags"`
+	Custom        map[string]any `json:"custom" toml:"custom"`
+}
+
+// isSidecar reports whether filename is the sidecar of a capture.
+func isSidecar(filename string) bool {
+	for _, ext := range sidecarExtensions {
+		if strings.HasSuffix(filename, ext) && pcapExtRegex.MatchString(strings.TrimSuffix(filename, ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sidecarWait is how long a finished capture waits for its sidecar.
+func sidecarWait(cfg config.Config) time.Duration {
+	if cfg.SidecarWaitSeconds == nil {
+		return defaultSidecarWaitSeconds * time.Second
+	}
+	return time.Duration(max(*cfg.SidecarWaitSeconds, 0)) * time.Second
+}
+
+// awaitSidecar returns the sidecar of a watched capture, waiting for it like
+// findSidecar. Files that won't be ingested as captures don't wait.
+func awaitSidecar(cfg config.Config, path string) string {
+	name := filepath.Base(path)
+	if isSidecar(name) {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	if result, _ := parseFilename(name, filenamePatternsFor(cfg, path)); !result.IsValid {
+		return ""
+	}
+	return findSidecar(cfg, path)
+}
+
+// findSidecar returns the path of the sidecar of the capture at path, or ""
+// if there is none after waiting sidecarWait for one.
+func findSidecar(cfg config.Config, path string) string {
+	deadline := time.Now().Add(sidecarWait(cfg))
+	for {
+		for _, ext := range sidecarExtensions {
+			info, err := os.Stat(path + ext)
+			if err != nil {
+				continue
+			}
+			if wait := sidecarQuiet - time.Since(info.ModTime()); wait > 0 {
+				time.Sleep(wait)
+			}
+			return path + ext
+		}
+		if time.Now().After(deadline) {
+			return ""
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// existingSidecar returns the sidecar of the capture at path if there already
+// is one.
+func existingSidecar(path string) string {
+	for _, ext := range sidecarExtensions {
+		if _, err := os.Stat(path + ext); err == nil {
+			return path + ext
+		}
+	}
+	return ""
+}
+
+// readSidecar parses a JSON or TOML sidecar into the key/values and the tags
+// stored with the capture. The tags are added like user tags.
+func readSidecar(path string) (map[string]string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var doc sidecar
+	switch filepath.Ext(path) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&doc)
+	default:
+		err = errors.New("unknown sidecar format")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := make(map[string]string)
+	known := map[string]string{
+		"operator":       doc.Operator,
+		"interface":      doc.Interface,
+		"capture_filter": doc.CaptureFilter,
+		"description":    doc.Description,
+	}
+	for key, value := range known {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+
+	tags := make([]string, 0, len(doc.Tags))
+	for _, tag := range doc.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if !validTag.MatchString(tag) {
+			return nil, nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		tags = append(tags, tag)
+	}
+
+	for key, value := range doc.Custom {
+		if !validMetadataKey.MatchString(key) {
+			return nil, nil, fmt.Errorf("invalid custom key %q", key)
+		}
+		if _, ok := known[key]; ok || key == "tags" {
+			return nil, nil, fmt.Errorf("custom key %q shadows a built-in field", key)
+		}
+		switch value.(type) {
+		case string, bool, json.Number, float64, int64:
+			metadata[key] = fmt.Sprint(value)
+		default:
+			return nil, nil, fmt.Errorf("custom key %q must be a string, number or boolean", key)
+		}
+	}
+	return metadata, tags, nil
+}
+
+// matchesMetadata reports whether metadata satisfies all meta filters. A
+// filter is either key=value for an exact match or just key for any value.
+func matchesMetadata(metadata map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		actual, ok := metadata[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
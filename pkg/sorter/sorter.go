@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,12 +19,21 @@ import (
 	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/logger"
 )
 
+// pcapExtRegex matches the capture extensions: .pcap, .pcapng, rotated
+// .pcap1 and each of them gzipped.
+var pcapExtRegex = regexp.MustCompile(`\.pcap(ng)?\d*(?:\.gz)?$`)
+
 type FilenameValidationResult struct {
 	IsValid         bool
 	Hostname        string
 	Scenario        string
 	CaptureDateTime time.Time
 	Error           string
+	// Pattern is the name of the filename pattern that matched.
+	Pattern string
+	// TimeFromPackets is set when the filename carries no datetime and it
+	// has to come from the first packet.
+	TimeFromPackets bool
 }
 
 func StartSorter() {
@@ -40,249 +50,444 @@ func StartSorter() {
 	if err := InitSorter(cfg); err != nil {
 		lg.Fatal("Failed to initialize sorter", "error", err)
 	}
-	am := NewArchiveManager(cfg, lg)
+	s := newServer(lg, cfg)
+	queue := NewIngestQueue(s.GetConfig, lg)
+	s.ingest = queue
+	am := NewArchiveManager(cfg, lg, queue)
 
 	if err := am.InitialCheck(); err != nil {
 		lg.Fatal("Failed to initially check for pending archive tasks", "error", err)
 	}
 
-	go startHTTPServer(lg, cfg)
+	if err := queue.Start(); err != nil {
+		lg.Fatal("Failed to start ingest workers", "error", err)
+	}
+
+	// Catch up on whatever happened while the server was down.
+	go func() {
+		res, err := queue.Reconcile()
+		if err != nil {
+			lg.Error("Failed to reconcile store", "error", err)
+			return
+		}
+		lg.Info("Reconciled store", "queued", res.Queued, "registered", res.Registered, "relinked", res.Relinked, "missing", res.Missing, "found", res.Found, "hashed", res.Hashed, "skipped", res.Skipped)
+	}()
+
+	go startHTTPServer(s)
 	go am.StartPeriodicCheck()
-	Watcher(cfg, lg)
+	Watcher(cfg, queue, lg)
 }
 
 func ValidateFilename(filePath string, cfg config.Config, logger logger.Logger) FilenameValidationResult {
+	result, reject := parseFilename(filepath.Base(filePath), filenamePatternsFor(cfg, filePath))
+	var timeErr error
+	if result.IsValid && result.TimeFromPackets {
+		timeErr = setTimeFromPackets(filePath, &result)
+		reject = !result.IsValid
+	}
+	if result.IsValid {
+		if cfg.LogLevel == "info" {
+			logger.Info("Valid filename", "pattern", result.Pattern, "hostname", result.Hostname, "scenario", result.Scenario, "datetime", result.CaptureDateTime.Format(time.RFC3339), "time_from_packets", result.TimeFromPackets)
+		}
+		return result
+	}
+	if !reject {
+		return result
+	}
+
+	reason := RejectInvalidName
+	var corrupt *capture.CorruptError
+	if errors.As(timeErr, &corrupt) {
+		reason = RejectCorrupt
+	}
+	rejectFile(cfg, filePath, existingSidecar(filePath), reason, result.Error, 0, logger)
+	return result
+}
+
+// parseFilename checks a capture filename against the filename patterns
+// without touching the file on disk. The returned bool reports whether the
+// file should be marked as incorrect. Results with TimeFromPackets set still
+// need their capture time from setTimeFromPackets.
+func parseFilename(filename string, patterns []config.FilenamePattern) (FilenameValidationResult, bool) {
 	result := FilenameValidationResult{IsValid: false}
-	filename := filepath.Base(filePath)
 
 	// Skip directories and hidden files
 	if filename == "" || strings.HasPrefix(filename, ".") {
 		result.Error = "Invalid filename: empty or hidden"
-		return result
+		return result, false
 	}
 
 	if strings.HasSuffix(filename, ".INCORRECT") {
 		result.Error = "Already marked as incorrect"
-		return result
+		return result, false
 	}
 
-	// Remove extensions in order: .gz, .pcapng, .pcap
-	pcapRegex := regexp.MustCompile(`\.pcap(ng)?\d*(?:\.gz)?$`)
-	base := pcapRegex.ReplaceAllString(filename, "")
+	if strings.HasSuffix(filename, ".DUPLICATE") {
+		result.Error = "Already marked as duplicate"
+		return result, false
+	}
+
+	// Sidecars are picked up together with their capture.
+	if isSidecar(filename) {
+		result.Error = "Sidecar metadata file"
+		return result, false
+	}
+
+	base := pcapExtRegex.ReplaceAllString(filename, "")
 
 	if base == filename {
-		newPath := filePath + ".INCORRECT"
-		os.Rename(filePath, newPath)
 		result.Error = "No valid pcap extension found"
-		if cfg.LogLevel == "info" {
-			logger.Info("Renamed file - no pcap extension", "from", filePath, "to", newPath)
-		}
-		return result
+		return result, true
 	}
 
-	// Regex: {hostname}_{scenario}_{YYYYMMDD_HHmmss}
-	re := regexp.MustCompile(`^\{([a-zA-Z0-9_-]+)\}_\{([a-zA-Z0-9_-]+)\}_\{(\d{8})_(\d{6})\}$`)
-	matches := re.FindStringSubmatch(base)
-
-	if len(matches) != 5 {
-		newPath := filePath + ".INCORRECT"
-		if err := os.Rename(filePath, newPath); err != nil {
-			result.Error = "Failed to rename file: " + err.Error()
-			logger.Error("Failed to rename file", "from", filePath, "to", newPath, "error", err)
-			return result
-		}
+	compiled, err := compileFilenamePatterns(patterns)
+	if err != nil {
+		// A broken config is no reason to reject the file.
+		result.Error = "Invalid filename patterns: " + err.Error()
+		return result, false
+	}
 
-		result.Error = "Invalid filename format"
-		if cfg.LogLevel == "info" {
-			logger.Info("Renamed invalid filename", "from", filePath, "to", newPath)
+	// The first pattern that matches decides, a later one could read the
+	// same name differently.
+	for _, pattern := range compiled {
+		if pattern.match(base, &result) {
+			return result, !result.IsValid
 		}
-		return result
 	}
 
-	result.Hostname = matches[1]
-	result.Scenario = matches[2]
-	dateStr := matches[3]
-	timeStr := matches[4]
+	result.Error = "Invalid filename format"
+	return result, true
+}
 
-	// Validate date/time format (basic sanity check)
-	if !isValidDatetime(dateStr, timeStr) {
-		newPath := filePath + ".INCORRECT"
-		os.Rename(filePath, newPath)
-		result.Error = "Invalid datetime values"
+// processFile validates and ingests a watched file with the sidecar
+// awaitSidecar found for it, if any. It returns the new capture ID, or 0 if
+// the file was handled without storing it (sidecars, invalid names,
+// duplicates and unreadable captures). Errors are failures that are worth
+// retrying; the file is left where it was.
+func processFile(cfg config.Config, path, sidecarPath string, logger logger.Logger) (int64, error) {
+	if isSidecar(filepath.Base(path)) {
+		return 0, nil
+	}
+	if cfg.LogLevel == "info" {
+		logger.Info("Processing file", "path", path)
+	}
+	result := ValidateFilename(path, cfg, logger)
+	if !result.IsValid {
 		if cfg.LogLevel == "info" {
-			logger.Info("Renamed file - invalid datetime", "from", filePath, "to", newPath)
+			logger.Warn("Filename is invalid", "error", result.Error)
+		}
+		return 0, nil
+	}
+
+	var metadata map[string]string
+	var tags []string
+	if sidecarPath == "" {
+		// It may have shown up since.
+		sidecarPath = existingSidecar(path)
+	}
+	if sidecarPath != "" {
+		var err error
+		metadata, tags, err = readSidecar(sidecarPath)
+		if err != nil {
+			// The capture isn't ingested without the metadata meant for it.
+			logger.Error("Invalid sidecar, rejecting capture", "path", sidecarPath, "error", err)
+			rejectFile(cfg, path, sidecarPath, RejectInvalidSidecar, err.Error(), 0, logger)
+			return 0, nil
+		}
+		if cfg.LogLevel == "info" {
+			logger.Info("Read sidecar", "path", sidecarPath, "keys", len(metadata), "tags", len(tags))
 		}
-		return result
 	}
 
-	datetimeStr := dateStr + timeStr
-	captureDateTime, err := time.Parse("20060102150405", datetimeStr)
+	captureID, corrupt, err := ingestFile(cfg, path, result, metadata, tags, logger)
 	if err != nil {
-		newPath := filePath + ".INCORRECT"
-		os.Rename(filePath, newPath)
-		result.Error = "Invalid datetime: " + err.Error()
-		if cfg.LogLevel == "info" {
-			logger.Info("Renamed invalid datetime", "from", filePath, "to", newPath)
+		var dup *duplicateError
+		if errors.As(err, &dup) {
+			handleDuplicate(cfg, path, sidecarPath, result, dup.CaptureID, logger)
+			return 0, nil
 		}
-		return result
+		// Nothing could be salvaged, and retrying won't change that.
+		if errors.As(err, &corrupt) {
+			logger.Error("Unreadable capture, rejecting it", "path", path, "error", err)
+			rejectFile(cfg, path, sidecarPath, RejectCorrupt, corrupt.Error(), 0, logger)
+			return 0, nil
+		}
+		return 0, err
 	}
-
-	result.IsValid = true
-	result.CaptureDateTime = captureDateTime
-	if cfg.LogLevel == "info" {
-		logger.Info("Valid filename", "hostname", result.Hostname, "scenario", result.Scenario, "datetime", result.CaptureDateTime.Format(time.RFC3339))
+	if corrupt != nil {
+		logger.Warn("Stored corrupt capture", "path", path, "id", captureID, "packet", corrupt.Packet, "error", corrupt.Err)
 	}
-
-	return result
+	if sidecarPath != "" {
+		// The metadata lives in the database from here on.
+		if err := os.Remove(sidecarPath); err != nil {
+			logger.Error("Failed to remove sidecar", "path", sidecarPath, "error", err)
+		}
+	}
+	return captureID, nil
 }
 
-func isValidDatetime(dateStr, timeStr string) bool {
-	if len(dateStr) != 8 || len(timeStr) != 6 {
-		return false
+// handleDuplicate applies the duplicate policy to a watched capture whose
+// content is already stored as captureID: link records it and removes the
+// copy, reject quarantines it with its sidecar.
+func handleDuplicate(cfg config.Config, path, sidecarPath string, result FilenameValidationResult, captureID int64, logger logger.Logger) {
+	paths := []string{path}
+	if sidecarPath != "" {
+		paths = append(paths, sidecarPath)
 	}
 
-	month := dateStr[4:6]
-	day := dateStr[6:8]
-
-	hour := timeStr[0:2]
-	min := timeStr[2:4]
-	sec := timeStr[4:6]
-
-	if month < "01" || month > "12" {
-		return false
-	}
-	if day < "01" || day > "31" {
-		return false
-	}
-	if hour > "23" {
-		return false
-	}
-	if min > "59" {
-		return false
-	}
-	if sec > "59" {
-		return false
+	if cfg.DuplicatePolicy == config.DuplicatePolicyLink {
+		if err := linkDuplicate(captureID, path, result); err != nil {
+			logger.Error("Failed to link duplicate", "path", path, "capture_id", captureID, "error", err)
+			return
+		}
+		for _, p := range paths {
+			if err := os.Remove(p); err != nil {
+				logger.Error("Failed to remove duplicate", "path", p, "error", err)
+			}
+		}
+		if cfg.LogLevel == "info" {
+			logger.Info("Linked duplicate capture", "path", path, "capture_id", captureID)
+		}
+		return
 	}
 
-	return true
+	rejectFile(cfg, path, sidecarPath, RejectDuplicate, (&duplicateError{CaptureID: captureID}).Error(), captureID, logger)
+	if cfg.LogLevel == "info" {
+		logger.Warn("Rejected duplicate capture", "path", path, "capture_id", captureID)
+	}
 }
 
-func processFile(cfg config.Config, path string, logger logger.Logger) {
-	if cfg.LogLevel == "info" {
-		logger.Info("Processing file", "path", path)
+// ingestFile moves an already validated capture into the organized directory,
+// analyzes it and stores it together with its stats, metadata and tags. It
+// returns the new capture ID, or a *duplicateError if the content is already
+// stored and the duplicate policy doesn't allow that, or an *existsError if
+// its name is taken by another capture. A capture that is stored
+// corrupt comes with its *capture.CorruptError; one that couldn't be stored
+// because nothing could be salvaged returns an error wrapping it.
+func ingestFile(cfg config.Config, path string, result FilenameValidationResult, metadata map[string]string, tags []string, logger logger.Logger) (int64, *capture.CorruptError, error) {
+	s, getQeuryErr := db.InitIfNeeded()
+	if getQeuryErr != nil {
+		return 0, nil, fmt.Errorf("failed to get database queries: %w", getQeuryErr)
 	}
-	result := ValidateFilename(path, cfg, logger)
-	if !result.IsValid {
-		if cfg.LogLevel == "info" {
-			logger.Warn("Filename is invalid", "error", result.Error)
+
+	sum, hashErr := captureHash(path)
+	if hashErr != nil {
+		return 0, nil, fmt.Errorf("failed to hash capture file: %w", hashErr)
+	}
+	if sum != "" && cfg.DuplicatePolicy != config.DuplicatePolicyAllow {
+		existingID, err := s.GetCaptureIDBySHA256(context.Background(), nullString(sum))
+		if err == nil {
+			return 0, nil, &duplicateError{CaptureID: existingID}
+		}
+		if err != sql.ErrNoRows {
+			return 0, nil, fmt.Errorf("failed to look up capture hash: %w", err)
 		}
-		return
 	}
+
 	organizedPath := filepath.Join(cfg.OrganizedDir, result.Hostname, result.CaptureDateTime.UTC().Format(time.RFC3339))
 	if _, err := os.Stat(organizedPath); os.IsNotExist(err) {
 		if err := os.MkdirAll(organizedPath, os.ModePerm); err != nil {
-			logger.Error("Failed to create organized directory", "path", organizedPath, "error", err)
-			return
+			return 0, nil, fmt.Errorf("failed to create organized directory %s: %w", organizedPath, err)
 		}
 	}
-	organizedFilePath := filepath.Join(organizedPath, fmt.Sprintf("%s-%s-%s.pcap", result.Hostname, result.Scenario, result.CaptureDateTime.UTC().Format(time.RFC3339)))
+	// The name keeps the format and compression of the content, so the
+	// archive manager doesn't compress a gzipped capture again.
+	ext, extErr := capture.FileExt(path)
+	if extErr != nil {
+		return 0, nil, extErr
+	}
+	organizedFilePath := filepath.Join(organizedPath, fmt.Sprintf("%s-%s-%s%s", result.Hostname, result.Scenario, result.CaptureDateTime.UTC().Format(time.RFC3339), ext))
+	// Rename would silently replace the stored capture of the same name, so
+	// the name is claimed first. Another worker can't claim it in between.
+	placeholder, claimErr := os.OpenFile(organizedFilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(claimErr, os.ErrExist) {
+		return 0, nil, &existsError{Path: organizedFilePath}
+	}
+	if claimErr != nil {
+		return 0, nil, fmt.Errorf("failed to create organized file %s: %w", organizedFilePath, claimErr)
+	}
+	placeholder.Close()
 	renameErr := os.Rename(path, organizedFilePath)
 	if renameErr != nil {
-		logger.Error("Failed to rename file", "from", path, "to", organizedFilePath, "error", renameErr)
-		return
+		os.Remove(organizedFilePath)
+		return 0, nil, fmt.Errorf("failed to rename file to %s: %w", organizedFilePath, renameErr)
+	}
+	// Failures from here on put the file back, so a retry finds it again.
+	restore := func() {
+		if err := os.Rename(organizedFilePath, path); err != nil {
+			logger.Error("Failed to move file back", "from", organizedFilePath, "to", path, "error", err)
+		}
 	}
 
-	info, infoErr := os.Stat(organizedFilePath)
+	captureID, corrupt, err := storeCapture(cfg, s, organizedFilePath, result, metadata, tags, sum, false)
+	if err != nil {
+		restore()
+		return 0, nil, err
+	}
+
+	if cfg.LogLevel == "info" {
+		logger.Info("Successfully processed file", "path", organizedFilePath, "id", captureID)
+	}
+	return captureID, corrupt, nil
+}
+
+// existsError is returned by ingestFile when another capture is already
+// stored under the name the capture would get.
+type existsError struct {
+	Path string
+}
+
+func (e *existsError) Error() string {
+	return fmt.Sprintf("organized file %s already exists", e.Path)
+}
+
+// storeCapture analyzes a capture that is already in its final place and
+// stores it together with its stats, metadata and tags. sum is its content
+// hash. A capture that breaks off is stored flagged corrupt with the stats of
+// the packets before the damage, see analyzeStored.
+func storeCapture(cfg config.Config, s *db.Store, filePath string, result FilenameValidationResult, metadata map[string]string, tags []string, sum string, archived bool) (int64, *capture.CorruptError, error) {
+	info, infoErr := os.Stat(filePath)
 	if infoErr != nil {
-		logger.Error("Failed to get file info", "path", organizedFilePath, "error", infoErr)
-		return
+		return 0, nil, fmt.Errorf("failed to get file info: %w", infoErr)
 	}
 
-	s, getQeuryErr := db.InitIfNeeded()
-	if getQeuryErr != nil {
-		logger.Error("Failed to get database queries", "error", getQeuryErr)
-		return
+	ext, extErr := capture.FileExt(filePath)
+	if extErr != nil {
+		return 0, nil, extErr
 	}
 
+	res, corrupt, parseErr := analyzeStored(cfg, filePath)
+	if parseErr != nil {
+		return 0, nil, parseErr
+	}
+	isCorrupt, corruptPacket, corruptError := corruptColumns(corrupt)
+
 	caputureParams := sqlc.InsertCaptureParams{
 		Hostname:        result.Hostname,
 		Scenario:        result.Scenario,
 		CaptureDatetime: result.CaptureDateTime,
-		FilePath:        organizedFilePath,
+		FilePath:        filePath,
 		FileSize:        info.Size(),
-		Compressed:      sql.NullBool{Bool: false, Valid: true},
-		Archived:        sql.NullBool{Bool: false, Valid: true},
+		Compressed:      sql.NullBool{Bool: strings.HasSuffix(ext, ".gz"), Valid: true},
+		Archived:        sql.NullBool{Bool: archived, Valid: true},
 		CreatedAt:       sql.NullTime{Time: result.CaptureDateTime, Valid: true},
 		UpdatedAt:       sql.NullTime{Time: result.CaptureDateTime, Valid: true},
+		Sha256:          nullString(sum),
+		Corrupt:         isCorrupt,
+		CorruptPacket:   corruptPacket,
+		CorruptError:    corruptError,
 	}
 
-	res, parseErr := capture.AnalyzeCaptureFile(cfg, organizedFilePath)
-	if parseErr != nil {
-		logger.Error("Failed to parse capture file", "path", organizedFilePath, "error", parseErr)
-		return
+	analysisParams, paramsErr := buildAnalysisParams(res)
+	if paramsErr != nil {
+		return 0, nil, paramsErr
+	}
+
+	captureID, insertErr := s.InsertCaptureWithStats(context.Background(), caputureParams, analysisParams, metadata, tags)
+	// The same content was stored since the caller looked its hash up.
+	if sum != "" && db.IsUniqueViolation(insertErr, "captures.sha256") {
+		existingID, err := s.GetCaptureIDBySHA256(context.Background(), nullString(sum))
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to look up capture hash: %w", err)
+		}
+		if cfg.DuplicatePolicy != config.DuplicatePolicyAllow {
+			return 0, nil, &duplicateError{CaptureID: existingID}
+		}
+		caputureParams.DuplicateOf = sql.NullInt64{Int64: existingID, Valid: true}
+		captureID, insertErr = s.InsertCaptureWithStats(context.Background(), caputureParams, analysisParams, metadata, tags)
+	}
+	if insertErr != nil {
+		return 0, nil, fmt.Errorf("failed to insert capture stats: %w", insertErr)
 	}
+	return captureID, corrupt, nil
+}
+
+// buildAnalysisParams converts analysis results into the rows stored per capture.
+func buildAnalysisParams(res capture.CaptureStats) (db.AnalysisParams, error) {
+	statParams, err := buildStatsParams(res)
+	if err != nil {
+		return db.AnalysisParams{}, err
+	}
+
+	persisters := make([]db.Persister, 0, len(res.Persisters))
+	for _, persister := range res.Persisters {
+		persisters = append(persisters, persister)
+	}
+
+	return db.AnalysisParams{
+		Stats:      statParams,
+		Persisters: persisters,
+	}, nil
+}
 
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// buildStatsParams converts analysis results into a capture_stats row.
+func buildStatsParams(res capture.CaptureStats) (sqlc.InsertCaptureStatsParams, error) {
 	protDist, marshallProtDistErr := json.Marshal(res.ProtocolDistribution)
 	if marshallProtDistErr != nil {
-		logger.Error("Failed to marshal protocol distribution", "error", marshallProtDistErr)
-		return
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal protocol distribution: %w", marshallProtDistErr)
 	}
 	topSrcIps, marshallTopSrcIpsErr := json.Marshal(res.TopSrcIPs)
 	if marshallTopSrcIpsErr != nil {
-		logger.Error("Failed to marshal top source IPs", "error", marshallTopSrcIpsErr)
-		return
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal top source IPs: %w", marshallTopSrcIpsErr)
 	}
 	topDstIps, marshallTopDstIpsErr := json.Marshal(res.TopDstIPs)
 	if marshallTopDstIpsErr != nil {
-		logger.Error("Failed to marshal top destination IPs", "error", marshallTopDstIpsErr)
-		return
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal top destination IPs: %w", marshallTopDstIpsErr)
 	}
 
 	topTcpSrcPorts, marshalTopTcpSrcErr := json.Marshal(res.TopTCPSrcPorts)
 	if marshalTopTcpSrcErr != nil {
-		logger.Error("Failed to marshal top TCP source ports", "error", marshalTopTcpSrcErr)
-		return
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal top TCP source ports: %w", marshalTopTcpSrcErr)
 	}
 	topTcpDstPorts, marshalTopTcpDstErr := json.Marshal(res.TopTCPDstPorts)
 	if marshalTopTcpDstErr != nil {
-		logger.Error("Failed to marshal top TCP destination ports", "error", marshalTopTcpDstErr)
-		return
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal top TCP destination ports: %w", marshalTopTcpDstErr)
 	}
 	topUdpSrcPorts, marshalTopUdpSrcErr := json.Marshal(res.TopUDPSrcPorts)
 	if marshalTopUdpSrcErr != nil {
-		logger.Error("Failed to marshal top UDP source ports", "error", marshalTopUdpSrcErr)
-		return
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal top UDP source ports: %w", marshalTopUdpSrcErr)
 	}
 	topUdpDstPorts, marshalTopUdpDstErr := json.Marshal(res.TopUDPDstPorts)
 	if marshalTopUdpDstErr != nil {
-		logger.Error("Failed to marshal top UDP destination ports", "error", marshalTopUdpDstErr)
-		return
-	}
-
-	// Assume you already have captureID available in scope.
-	statParams := sqlc.InsertCaptureStatsParams{
-		PacketCount:          sql.NullInt64{Int64: int64(res.TotalPackets), Valid: true},
-		ProtocolDistribution: sql.NullString{String: string(protDist), Valid: true},
-		TopSrcIps:            sql.NullString{String: string(topSrcIps), Valid: true},
-		TopDstIps:            sql.NullString{String: string(topDstIps), Valid: true},
-		TopTcpSrcPorts:       sql.NullString{String: string(topTcpSrcPorts), Valid: true},
-		TopTcpDstPorts:       sql.NullString{String: string(topTcpDstPorts), Valid: true},
-		TopUdpSrcPorts:       sql.NullString{String: string(topUdpSrcPorts), Valid: true},
-		TopUdpDstPorts:       sql.NullString{String: string(topUdpDstPorts), Valid: true},
-		PacketRate:           sql.NullFloat64{Float64: res.PacketRate, Valid: true},
-		AvgPacketSize:        sql.NullFloat64{Float64: res.AvgPacketSize, Valid: true},
-		DurationSeconds:      sql.NullInt64{Int64: res.DurationSeconds, Valid: true},
-		FirstPacketTime:      sql.NullTime{Time: res.FirstPacketTime, Valid: true},
-		LastPacketTime:       sql.NullTime{Time: res.LastPacketTime, Valid: true},
-	}
-
-	_, insertErr := s.InsertCaptureWithStats(context.Background(), caputureParams, statParams)
-	if insertErr != nil {
-		logger.Error("Failed to insert capture stats", "error", insertErr)
-		return
-	}
-
-	if cfg.LogLevel == "info" {
-		logger.Info("Successfully processed file", "path", organizedFilePath)
-	}
+		return sqlc.InsertCaptureStatsParams{}, fmt.Errorf("failed to marshal top UDP destination ports: %w", marshalTopUdpDstErr)
+	}
+
+	// The TCP health columns stay NULL when the analyzer is disabled.
+	health := res.TCPHealth
+	hasHealth := health != nil
+	if !hasHealth {
+		health = &capture.TCPHealth{}
+	}
+	hasRTT := hasHealth && health.HandshakesCompleted > 0
+
+	return sqlc.InsertCaptureStatsParams{
+		PacketCount:            sql.NullInt64{Int64: int64(res.TotalPackets), Valid: true},
+		ProtocolDistribution:   sql.NullString{String: string(protDist), Valid: true},
+		TopSrcIps:              sql.NullString{String: string(topSrcIps), Valid: true},
+		TopDstIps:              sql.NullString{String: string(topDstIps), Valid: true},
+		TopTcpSrcPorts:         sql.NullString{String: string(topTcpSrcPorts), Valid: true},
+		TopTcpDstPorts:         sql.NullString{String: string(topTcpDstPorts), Valid: true},
+		TopUdpSrcPorts:         sql.NullString{String: string(topUdpSrcPorts), Valid: true},
+		TopUdpDstPorts:         sql.NullString{String: string(topUdpDstPorts), Valid: true},
+		PacketRate:             sql.NullFloat64{Float64: res.PacketRate, Valid: true},
+		AvgPacketSize:          sql.NullFloat64{Float64: res.AvgPacketSize, Valid: true},
+		DurationSeconds:        sql.NullInt64{Int64: res.DurationSeconds, Valid: true},
+		FirstPacketTime:        sql.NullTime{Time: res.FirstPacketTime, Valid: true},
+		LastPacketTime:         sql.NullTime{Time: res.LastPacketTime, Valid: true},
+		TcpSyns:                sql.NullInt64{Int64: health.SYNs, Valid: hasHealth},
+		TcpSynAcks:             sql.NullInt64{Int64: health.SYNACKs, Valid: hasHealth},
+		TcpHandshakesCompleted: sql.NullInt64{Int64: health.HandshakesCompleted, Valid: hasHealth},
+		TcpResets:              sql.NullInt64{Int64: health.Resets, Valid: hasHealth},
+		TcpFins:                sql.NullInt64{Int64: health.FINs, Valid: hasHealth},
+		TcpDuplicateAcks:       sql.NullInt64{Int64: health.DuplicateACKs, Valid: hasHealth},
+		TcpRetransmissions:     sql.NullInt64{Int64: health.Retransmissions, Valid: hasHealth},
+		TcpOutOfOrder:          sql.NullInt64{Int64: health.OutOfOrder, Valid: hasHealth},
+		TcpAvgHandshakeRttMs:   sql.NullFloat64{Float64: health.AvgHandshakeRTTMs, Valid: hasRTT},
+		TcpMaxHandshakeRttMs:   sql.NullFloat64{Float64: health.MaxHandshakeRTTMs, Valid: hasRTT},
+		AnalyzerVersion:        capture.AnalyzerVersion,
+	}, nil
 }
@@ -97,6 +97,27 @@ func (s *Server) GetFileStatsHandler(w http.ResponseWriter, r *http.Request) {
 	if statsRow.DurationSeconds.Valid {
 		result.DurationSeconds = &statsRow.DurationSeconds.Int64
 	}
+	if statsRow.AnalyzerVersion.Valid {
+		result.AnalyzerVersion = &statsRow.AnalyzerVersion.Int64
+	}
+	if statsRow.TcpSyns.Valid {
+		result.TCPHealth = &TCPHealthResult{
+			SYNs:                statsRow.TcpSyns.Int64,
+			SYNACKs:             statsRow.TcpSynAcks.Int64,
+			HandshakesCompleted: statsRow.TcpHandshakesCompleted.Int64,
+			Resets:              statsRow.TcpResets.Int64,
+			FINs:                statsRow.TcpFins.Int64,
+			DuplicateACKs:       statsRow.TcpDuplicateAcks.Int64,
+			Retransmissions:     statsRow.TcpRetransmissions.Int64,
+			OutOfOrder:          statsRow.TcpOutOfOrder.Int64,
+		}
+		if statsRow.TcpAvgHandshakeRttMs.Valid {
+			result.TCPHealth.AvgHandshakeRTTMs = &statsRow.TcpAvgHandshakeRttMs.Float64
+		}
+		if statsRow.TcpMaxHandshakeRttMs.Valid {
+			result.TCPHealth.MaxHandshakeRTTMs = &statsRow.TcpMaxHandshakeRttMs.Float64
+		}
+	}
 	if statsRow.FirstPacketTime.Valid {
 		ft := statsRow.FirstPacketTime.Time.Format(time.RFC3339)
 		result.FirstPacketTime = &ft
@@ -163,6 +184,47 @@ func (s *Server) GetFileStatsHandler(w http.ResponseWriter, r *http.Request) {
 	result.TopUdpSrcPorts = limitStatMap(result.TopUdpSrcPorts, topLimit)
 	result.TopUdpDstPorts = limitStatMap(result.TopUdpDstPorts, topLimit)
 
+	tlsRows, err := store.GetCaptureTLS(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture TLS sessions", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	for _, row := range tlsRows {
+		session := TLSSessionResult{
+			ClientIP:      row.ClientIp,
+			ClientPort:    row.ClientPort,
+			ServerIP:      row.ServerIp,
+			ServerPort:    row.ServerPort,
+			SeenAt:        row.SeenAt.Format(time.RFC3339Nano),
+			SNI:           row.Sni.String,
+			ClientVersion: row.ClientVersion.String,
+			JA3:           row.Ja3.String,
+			JA3Hash:       row.Ja3Hash.String,
+			Version:       row.Version.String,
+			CipherSuite:   row.CipherSuite.String,
+			JA3S:          row.Ja3s.String,
+			JA3SHash:      row.Ja3sHash.String,
+		}
+		if row.CipherSuites.Valid && row.CipherSuites.String != "" {
+			var cipherSuites []string
+			if err := json.Unmarshal([]byte(row.CipherSuites.String), &cipherSuites); err == nil {
+				session.CipherSuites = cipherSuites
+			}
+		}
+		result.TLSSessions = append(result.TLSSessions, session)
+	}
+
+	findingRows, err := store.GetCaptureFindings(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture findings", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	for _, row := range findingRows {
+		result.Findings = append(result.Findings, findingResult(row.ID, row.CaptureID, row.Rule, row.Severity, row.Summary, row.Evidence, row.FirstSeen))
+	}
+
 	jsonResponse(w, http.StatusOK, result)
 }
 
@@ -0,0 +1,162 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+// validTag allows short labels like "graded" or "broken capture".
+var validTag = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9 _.:-]{0,63}$`)
+
+// lookupCapture resolves the {id} of a file route to an existing capture and
+// writes the error response if there is none.
+func (s *Server) lookupCapture(w http.ResponseWriter, r *http.Request) (*db.Store, sqlc.Capture, bool) {
+	idParam := chi.URLParam(r, "id")
+	captureID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return nil, sqlc.Capture{}, false
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return nil, sqlc.Capture{}, false
+	}
+
+	capture, err := store.GetCapture(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture", "error", err, "id", captureID)
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return nil, sqlc.Capture{}, false
+	}
+	return store, capture, true
+}
+
+// tagFromRequest returns the unescaped {tag} of a tag route.
+func tagFromRequest(r *http.Request) (string, bool) {
+	tag, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		return "", false
+	}
+	tag = strings.TrimSpace(tag)
+	return tag, validTag.MatchString(tag)
+}
+
+// captureTags returns the tags of all captures by capture ID.
+func captureTags(ctx context.Context, store *db.Store) (map[int64][]string, error) {
+	rows, err := store.GetAllCaptureTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[int64][]string)
+	for _, row := range rows {
+		tags[row.CaptureID] = append(tags[row.CaptureID], row.Tag)
+	}
+	return tags, nil
+}
+
+// hasTags reports whether tags contains all of wanted.
+func hasTags(tags []string, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTagHandler tags a capture. Adding a tag it already has is a no-op.
+func (s *Server) AddTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := tagFromRequest(r)
+	if !ok {
+		s.logger.Error("Invalid tag", "tag", chi.URLParam(r, "tag"))
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, capture, ok := s.lookupCapture(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	if err := store.AddCaptureTag(ctx, sqlc.AddCaptureTagParams{
+		CaptureID: capture.ID,
+		Tag:       tag,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		s.logger.Error("Failed to add tag", "error", err, "id", capture.ID, "tag", tag)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	s.writeTags(w, store, capture.ID)
+}
+
+// RemoveTagHandler removes a tag from a capture.
+func (s *Server) RemoveTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := tagFromRequest(r)
+	if !ok {
+		s.logger.Error("Invalid tag", "tag", chi.URLParam(r, "tag"))
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	store, capture, ok := s.lookupCapture(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := store.DeleteCaptureTag(context.Background(), sqlc.DeleteCaptureTagParams{
+		CaptureID: capture.ID,
+		Tag:       tag,
+	})
+	if err != nil {
+		s.logger.Error("Failed to remove tag", "error", err, "id", capture.ID, "tag", tag)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	if removed == 0 {
+		jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		return
+	}
+
+	s.writeTags(w, store, capture.ID)
+}
+
+func (s *Server) writeTags(w http.ResponseWriter, store *db.Store, captureID int64) {
+	tags, err := store.GetCaptureTags(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get tags", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	jsonResponse(w, http.StatusOK, TagsRes{CaptureID: captureID, Tags: tags})
+}
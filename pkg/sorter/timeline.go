@@ -0,0 +1,103 @@
+package sorter
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+)
+
+// maxTimelineBuckets keeps a long capture at a small bucket size from
+// producing a response nobody can plot.
+const maxTimelineBuckets = 100000
+
+func (s *Server) GetFileTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	captureID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	bucketSize := time.Second
+	if bucketParam := r.URL.Query().Get("bucket"); bucketParam != "" {
+		bucketSize, err = time.ParseDuration(bucketParam)
+		if err != nil || bucketSize < time.Second || bucketSize%time.Second != 0 {
+			s.logger.Error("Invalid bucket size", "error", err, "bucket", bucketParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+	}
+	bucketSeconds := int64(bucketSize / time.Second)
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	if _, err := store.GetCapture(context.Background(), captureID); err != nil {
+		if err == sql.ErrNoRows {
+			jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		} else {
+			s.logger.Error("Failed to get capture", "error", err, "id", captureID)
+			jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		}
+		return
+	}
+
+	rows, err := store.GetCaptureTimeline(context.Background(), captureID)
+	if err != nil {
+		s.logger.Error("Failed to get capture timeline", "error", err, "id", captureID)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	result := TimelineRes{
+		CaptureID:     captureID,
+		BucketSeconds: bucketSeconds,
+		Buckets:       []TimelineBucketResult{},
+	}
+	if len(rows) == 0 {
+		jsonResponse(w, http.StatusOK, result)
+		return
+	}
+
+	// Buckets are aligned to the first second of the capture and empty ones
+	// are filled in so the series can be plotted directly.
+	first := rows[0].BucketStart.Unix()
+	last := rows[len(rows)-1].BucketStart.Unix()
+	count := (last-first)/bucketSeconds + 1
+	if count > maxTimelineBuckets {
+		s.logger.Error("Too many timeline buckets", "buckets", count, "bucket", bucketSize.String())
+		jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+		return
+	}
+
+	buckets := make([]TimelineBucketResult, count)
+	for i := range buckets {
+		buckets[i].Start = time.Unix(first+int64(i)*bucketSeconds, 0).UTC().Format(time.RFC3339)
+		buckets[i].Protocols = map[string]TimelineCounts{}
+	}
+	for _, row := range rows {
+		bucket := &buckets[(row.BucketStart.Unix()-first)/bucketSeconds]
+		bucket.Packets += row.Packets
+		bucket.Bytes += row.Bytes
+
+		counts := bucket.Protocols[row.Protocol]
+		counts.Packets += row.Packets
+		counts.Bytes += row.Bytes
+		bucket.Protocols[row.Protocol] = counts
+	}
+
+	result.Start = buckets[0].Start
+	result.End = time.Unix(first+count*bucketSeconds, 0).UTC().Format(time.RFC3339)
+	result.Buckets = buckets
+	jsonResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,122 @@
+package sorter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/capture"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db"
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/db/sqlc"
+)
+
+// verifyCapture re-hashes a stored capture and returns what is wrong with it,
+// or nil if it still has the content it was ingested with.
+func verifyCapture(c sqlc.Capture) *VerifyProblem {
+	problem := &VerifyProblem{CaptureID: c.ID, FilePath: c.FilePath, Expected: c.Sha256.String}
+
+	sum, err := capture.ContentHash(c.FilePath)
+	// A corrupt capture that couldn't be hashed at ingest still can't be.
+	var corrupt *capture.CorruptError
+	if c.Corrupt.Bool && !c.Sha256.Valid && errors.As(err, &corrupt) {
+		return nil
+	}
+	if err != nil {
+		problem.Error = err.Error()
+		if errors.Is(err, os.ErrNotExist) {
+			problem.Status = "missing"
+		} else {
+			problem.Status = "unreadable"
+		}
+		return problem
+	}
+
+	if !c.Sha256.Valid {
+		problem.Status = "unhashed"
+		problem.Actual = sum
+		return problem
+	}
+	if sum != c.Sha256.String {
+		problem.Status = "mismatch"
+		problem.Actual = sum
+		return problem
+	}
+	return nil
+}
+
+// VerifyHandler re-hashes stored captures in the organized and archive dirs
+// and reports the ones that are missing, unreadable or whose content no
+// longer matches the hash taken at ingest. Captures stored before hashes were
+// taken get theirs now. Repeated id parameters limit it to those captures.
+func (s *Server) VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var ids map[int64]bool
+	for _, idParam := range r.URL.Query()["id"] {
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			s.logger.Error("Invalid capture ID", "error", err, "id", idParam)
+			jsonResponse(w, http.StatusBadRequest, StatusRes{Status: "error"})
+			return
+		}
+		if ids == nil {
+			ids = make(map[int64]bool)
+		}
+		ids[id] = true
+	}
+
+	store, err := db.InitIfNeeded()
+	if err != nil {
+		s.logger.Error("Failed to initialize database", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	captures, err := store.GetCaptures(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to get captures", "error", err)
+		jsonResponse(w, http.StatusInternalServerError, StatusRes{Status: "error"})
+		return
+	}
+
+	result := VerifyRes{Problems: []VerifyProblem{}}
+	for _, c := range captures {
+		if ids != nil && !ids[c.ID] {
+			continue
+		}
+		result.Checked++
+		problem := verifyCapture(c)
+		if problem == nil {
+			result.OK++
+			continue
+		}
+		if problem.Status == "unhashed" {
+			err := storeHash(context.Background(), store, c.ID, problem.Actual)
+			if err == nil {
+				result.Hashed++
+				continue
+			}
+			s.logger.Error("Failed to store capture hash", "id", c.ID, "error", err)
+			problem.Error = err.Error()
+		}
+		switch problem.Status {
+		case "missing":
+			result.Missing++
+		case "mismatch":
+			result.Mismatched++
+		case "unreadable":
+			result.Unreadable++
+		case "unhashed":
+			result.Unhashed++
+		}
+		s.logger.Warn("Capture failed verification", "id", c.ID, "path", c.FilePath, "status", problem.Status)
+		result.Problems = append(result.Problems, *problem)
+	}
+
+	if ids != nil && result.Checked < len(ids) {
+		jsonResponse(w, http.StatusNotFound, StatusRes{Status: "error"})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,123 @@
+package sorter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stefanistkuhl/i-would-never-extend-exercises-itsi-y4-ex2/pkg/config"
+)
+
+// allWatchDirs is watch_dir followed by the configured watch_dirs.
+func allWatchDirs(cfg config.Config) []config.WatchDir {
+	return append([]config.WatchDir{{Path: cfg.WatchDir}}, cfg.WatchDirs...)
+}
+
+// isWithin reports whether path is dir or inside it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// watchDirFor returns the watched directory a file in path belongs to. With
+// nested watch dirs the innermost one wins.
+func watchDirFor(cfg config.Config, path string) (config.WatchDir, bool) {
+	parent := filepath.Dir(filepath.Clean(path))
+	var found config.WatchDir
+	ok := false
+	for _, dir := range allWatchDirs(cfg) {
+		if dir.Recursive {
+			if !isWithin(dir.Path, parent) {
+				continue
+			}
+		} else if filepath.Clean(dir.Path) != parent {
+			continue
+		}
+		if !ok || len(filepath.Clean(dir.Path)) > len(filepath.Clean(found.Path)) {
+			found, ok = dir, true
+		}
+	}
+	return found, ok
+}
+
+// watchDirPattern is the catch-all filename pattern of the watch dir path
+// is in, tried after the configured ones. It only exists if the watch dir
+// gives the capture both a hostname and a scenario.
+func watchDirPattern(cfg config.Config, path string) (config.FilenamePattern, bool) {
+	dir, ok := watchDirFor(cfg, path)
+	if !ok {
+		return config.FilenamePattern{}, false
+	}
+
+	hostname := dir.Hostname
+	if dir.HostnameFromDir {
+		rel, err := filepath.Rel(filepath.Clean(dir.Path), filepath.Dir(filepath.Clean(path)))
+		if err == nil && rel != "." {
+			hostname = strings.Split(rel, string(filepath.Separator))[0]
+		}
+	}
+	if hostname == "" || dir.Scenario == "" {
+		return config.FilenamePattern{}, false
+	}
+
+	return config.FilenamePattern{
+		Name:     "watch_dir " + dir.Path,
+		Regex:    `^.+$`,
+		Hostname: hostname,
+		Scenario: dir.Scenario,
+	}, true
+}
+
+// filenamePatternsFor is the list of filename patterns for a capture at path.
+func filenamePatternsFor(cfg config.Config, path string) []config.FilenamePattern {
+	pattern, ok := watchDirPattern(cfg, path)
+	if !ok {
+		return cfg.FilenamePatterns
+	}
+	patterns := make([]config.FilenamePattern, 0, len(cfg.FilenamePatterns)+1)
+	patterns = append(patterns, cfg.FilenamePatterns...)
+	return append(patterns, pattern)
+}
+
+// ValidateWatchDirs reports watch_dirs that can't be used. A watch dir that
+// overlaps the organized or archive dir would pick up stored captures again.
+func ValidateWatchDirs(cfg config.Config) error {
+	for i, dir := range cfg.WatchDirs {
+		if dir.Path == "" {
+			return fmt.Errorf("watch_dirs %d has no path", i)
+		}
+		for _, stored := range []string{cfg.OrganizedDir, cfg.ArchiveDir} {
+			if stored != "" && (isWithin(dir.Path, stored) || isWithin(stored, dir.Path)) {
+				return fmt.Errorf("watch_dirs %d (%s) overlaps %s", i, dir.Path, stored)
+			}
+		}
+		if dir.Hostname != "" && !validNamePart.MatchString(dir.Hostname) {
+			return fmt.Errorf("watch_dirs %d (%s) has an invalid hostname %q", i, dir.Path, dir.Hostname)
+		}
+		if dir.Scenario != "" && !validNamePart.MatchString(dir.Scenario) {
+			return fmt.Errorf("watch_dirs %d (%s) has an invalid scenario %q", i, dir.Path, dir.Scenario)
+		}
+	}
+	return nil
+}
+
+// walkWatchDir calls fn for the directories and files of a watch dir, going
+// into subdirectories only if it is recursive. Hidden subdirectories are left
+// out like hidden files.
+func walkWatchDir(dir config.WatchDir, fn func(path string, isDir bool)) error {
+	root := filepath.Clean(dir.Path)
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path != root {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && path != root && (!dir.Recursive || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		fn(path, d.IsDir())
+		return nil
+	})
+}
@@ -1,6 +1,9 @@
 package sorter
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,8 +13,48 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func Watcher(cfg config.Config, logger logger.Logger) {
-	logger.Info("Watching for changes", "directory", cfg.WatchDir)
+const defaultQuietSeconds = 3
+
+// quietPeriod is how long a file must go without writes before it counts as
+// finished.
+func quietPeriod(cfg config.Config) time.Duration {
+	if cfg.QuietSeconds <= 0 {
+		return defaultQuietSeconds * time.Second
+	}
+	return time.Duration(cfg.QuietSeconds) * time.Second
+}
+
+// ignoreWatched reports files the watcher doesn't queue: hidden files are
+// in-flight uploads or editor temp files, sidecars are read with their
+// capture and marked files were already rejected.
+func ignoreWatched(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, ".") ||
+		isSidecar(name) ||
+		strings.HasSuffix(name, ".INCORRECT") ||
+		strings.HasSuffix(name, ".DUPLICATE")
+}
+
+// Watcher queues finished captures from the watch dirs, using filesystem
+// events, polling or both depending on watch_mode. It doesn't return.
+func Watcher(cfg config.Config, queue *IngestQueue, logger logger.Logger) {
+	mode := cfg.WatchMode
+	if mode == "" {
+		mode = config.WatchModeNotify
+	}
+	if mode != config.WatchModePoll {
+		watchEvents(cfg, queue, logger)
+	}
+	if mode != config.WatchModeNotify {
+		go pollWatchDirs(cfg, queue, logger)
+	}
+
+	<-make(chan struct{})
+}
+
+// watchEvents queues captures when fsnotify reports them written and then
+// quiet for quietPeriod.
+func watchEvents(cfg config.Config, queue *IngestQueue, logger logger.Logger) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		logger.Fatal("Failed to create file watcher", "error", err)
@@ -19,32 +62,69 @@ func Watcher(cfg config.Config, logger logger.Logger) {
 
 	fileTimers := make(map[string]*time.Timer)
 	mu := &sync.Mutex{}
-	quietTimeout := 3 * time.Second
+
+	schedule := func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer, exists := fileTimers[path]; exists {
+			timer.Stop()
+		}
+
+		fileTimers[path] = time.AfterFunc(
+			quietPeriod(cfg),
+			func() {
+				if cfg.LogLevel == "info" {
+					logger.Info("File finished writing", "file", path)
+				}
+				mu.Lock()
+				delete(fileTimers, path)
+				mu.Unlock()
+
+				if _, err := queue.Enqueue(path); err != nil {
+					logger.Error("Failed to queue file", "path", path, "error", err)
+				}
+			},
+		)
+	}
+
+	// addDir watches a directory and, for recursive watch dirs, everything
+	// below it. onFile gets the files already in there.
+	addDir := func(dir config.WatchDir, onFile func(string)) error {
+		return walkWatchDir(dir, func(path string, isDir bool) {
+			if !isDir {
+				if onFile != nil && !ignoreWatched(path) {
+					onFile(path)
+				}
+				return
+			}
+			if err := watcher.Add(path); err != nil {
+				logger.Error("Failed to add watch directory", "path", path, "error", err)
+			}
+		})
+	}
 
 	go func() {
 		for {
 			select {
 			case event := <-watcher.Events:
-				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-					mu.Lock()
-					if timer, exists := fileTimers[event.Name]; exists {
-						timer.Stop()
-					}
-
-					fileTimers[event.Name] = time.AfterFunc(
-						quietTimeout,
-						func() {
-							if cfg.LogLevel == "info" {
-								logger.Info("File finished writing", "file", event.Name)
+				if ignoreWatched(event.Name) {
+					continue
+				}
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						// Files can land in a new directory before it is
+						// watched, so they are picked up here as well.
+						if dir, ok := watchDirFor(cfg, event.Name); ok && dir.Recursive {
+							dir.Path = event.Name
+							if err := addDir(dir, schedule); err != nil {
+								logger.Error("Failed to watch new directory", "path", event.Name, "error", err)
 							}
-							mu.Lock()
-							delete(fileTimers, event.Name)
-							mu.Unlock()
-
-							go processFile(cfg, event.Name, logger)
-						},
-					)
-					mu.Unlock()
+						}
+						continue
+					}
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					schedule(event.Name)
 				}
 
 			case err, ok := <-watcher.Errors:
@@ -56,10 +136,10 @@ func Watcher(cfg config.Config, logger logger.Logger) {
 		}
 	}()
 
-	err = watcher.Add(cfg.WatchDir)
-	if err != nil {
-		logger.Fatal("Failed to add watch directory", "path", cfg.WatchDir, "error", err)
+	for _, dir := range allWatchDirs(cfg) {
+		logger.Info("Watching for changes", "directory", dir.Path, "recursive", dir.Recursive)
+		if err := addDir(dir, nil); err != nil {
+			logger.Fatal("Failed to add watch directory", "path", dir.Path, "error", err)
+		}
 	}
-
-	<-make(chan struct{})
 }